@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newLimitCmd() *cobra.Command {
+	var (
+		addr   string
+		method string
+		uid    int64
+		apikey string
+		symbol string
+		n      int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "limit",
+		Short: "explain limit rules applied to a request of running bgw",
+		Long:  `limit /v5/order/create -X POST [--uid 123 | --apikey xxx] [--symbol BTCUSDT] [-n 10] [--addr localhost:6480]`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("need path")
+			}
+
+			query := url.Values{}
+			query.Set("cmd", "limit_explain")
+			query.Set("path", args[0])
+			query.Set("method", method)
+			query.Set("n", strconv.Itoa(n))
+			if uid > 0 {
+				query.Set("uid", strconv.FormatInt(uid, 10))
+			}
+			if apikey != "" {
+				query.Set("apikey", apikey)
+			}
+			if symbol != "" {
+				query.Set("symbol", symbol)
+			}
+
+			data, err := getAdmin(addr, query)
+			if err != nil {
+				return err
+			}
+			var out bytes.Buffer
+			if err = json.Indent(&out, data, "", "  "); err != nil {
+				// not json, print as it is
+				fmt.Println(string(data))
+				return nil
+			}
+			fmt.Println(out.String())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "localhost:6480", "admin address of bgw")
+	cmd.Flags().StringVarP(&method, "method", "X", http.MethodGet, "http method")
+	cmd.Flags().Int64Var(&uid, "uid", 0, "user id")
+	cmd.Flags().StringVar(&apikey, "apikey", "", "api key, uid is resolved by it if uid is not set")
+	cmd.Flags().StringVar(&symbol, "symbol", "", "symbol of request")
+	cmd.Flags().IntVarP(&n, "next", "n", 10, "count of next requests to simulate")
+
+	return cmd
+}
+
+// getAdmin call admin api of bgw
+func getAdmin(addr string, query url.Values) ([]byte, error) {
+	u := url.URL{Scheme: "http", Host: addr, Path: "/admin", RawQuery: query.Encode()}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("admin error: %s, %s", resp.Status, string(data))
+	}
+	return data, nil
+}
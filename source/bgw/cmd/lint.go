@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"bgw/pkg/server"
+	"bgw/pkg/server/core"
+	"bgw/pkg/server/http"
+)
+
+const defaultConfDir = "conf/conf.d"
+
+func newLintCmd() *cobra.Command {
+	var skipFilters bool
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "check route configs offline",
+		Long:  `lint [conf.d | app_module.yaml ...] [--skip-filters]`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{defaultConfDir}
+			}
+			files, err := readRouteConfigs(args)
+			if err != nil {
+				return err
+			}
+
+			initFilters := !skipFilters
+			if initFilters {
+				if err = registerFilters(); err != nil {
+					fmt.Fprintf(os.Stderr, "register filters failed, filter args are not checked: %v\n", err)
+					initFilters = false
+				}
+			}
+
+			apps, issues := http.Lint(context.Background(), files, initFilters)
+			for _, issue := range issues {
+				fmt.Println(issue.String())
+			}
+			if len(issues) > 0 {
+				return fmt.Errorf("%d issues found in %d files", len(issues), len(files))
+			}
+			fmt.Printf("%d files, %d apps ok\n", len(files), len(apps))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&skipFilters, "skip-filters", false, "skip dry run of filter Init")
+
+	return cmd
+}
+
+func newDiffCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "semantic diff of route configs",
+		Long:  `diff old_conf.d new_conf.d [--json], file or directory of yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return fmt.Errorf("need old and new route configs")
+			}
+			olds, err := loadRouteConfigs(args[0])
+			if err != nil {
+				return err
+			}
+			news, err := loadRouteConfigs(args[1])
+			if err != nil {
+				return err
+			}
+
+			diffs := core.DiffAppConfigs(olds, news)
+			if asJSON {
+				data, err := json.MarshalIndent(diffs, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+			for _, d := range diffs {
+				fmt.Println(d.String())
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print diff as json")
+
+	return cmd
+}
+
+// registerFilters register filters for dry run, constructors of global filters may panic without remote services
+func registerFilters() (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%v", e)
+		}
+	}()
+	server.RegisterFilters()
+	return
+}
+
+// readRouteConfigs read yaml files, directory is expanded to *.yaml and *.yml in it
+func readRouteConfigs(paths []string) (map[string]string, error) {
+	names, err := routeConfigFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = string(data)
+	}
+	return files, nil
+}
+
+func loadRouteConfigs(path string) ([]*core.AppConfig, error) {
+	names, err := routeConfigFiles([]string{path})
+	if err != nil {
+		return nil, err
+	}
+
+	apps := make([]*core.AppConfig, 0, len(names))
+	for _, name := range names {
+		app, err := loadAppConfig(name)
+		if err != nil {
+			return nil, fmt.Errorf("load route config %s error: %w", name, err)
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+func routeConfigFiles(paths []string) ([]string, error) {
+	names := make([]string, 0, len(paths))
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			names = append(names, p)
+			continue
+		}
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(p, pattern))
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, matches...)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.bydev.io/fbu/gateway/gway.git/generic"
+	"github.com/spf13/cobra"
+
+	"bgw/pkg/server/core"
+)
+
+func newOpenAPICmd() *cobra.Command {
+	var (
+		configs     []string
+		descriptors []string
+		output      string
+		title       string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "openapi",
+		Short: "generate openapi 3 document from route configs and descriptors",
+		Long:  `openapi -c app_module.yaml -d app.module=descriptor.protoset [-o openapi.json]`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(configs) == 0 {
+				return fmt.Errorf("need route config")
+			}
+			doc, err := genOpenAPI(title, configs, descriptors)
+			if err != nil {
+				return err
+			}
+
+			data, err := json.MarshalIndent(doc, "", "  ")
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				_, err = fmt.Fprintln(os.Stdout, string(data))
+				return err
+			}
+			return os.WriteFile(output, data, 0644)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&configs, "config", "c", nil, "route config yaml files")
+	cmd.Flags().StringSliceVarP(&descriptors, "descriptor", "d", nil, "protoset of app, app.module=file")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output file, default stdout")
+	cmd.Flags().StringVarP(&title, "title", "t", "bgw", "title of document")
+
+	return cmd
+}
+
+// genOpenAPI load route configs and descriptors from local files
+func genOpenAPI(title string, configs, descriptors []string) (*core.OpenAPI, error) {
+	apps := make([]*core.AppConfig, 0, len(configs))
+	for _, file := range configs {
+		app, err := loadAppConfig(file)
+		if err != nil {
+			return nil, fmt.Errorf("load route config %s error: %w", file, err)
+		}
+		apps = append(apps, app)
+	}
+
+	ds := make(map[string]generic.Descriptor, len(descriptors))
+	for _, d := range descriptors {
+		namespace, file, ok := strings.Cut(d, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid descriptor %s, need app.module=file", d)
+		}
+		source, err := generic.DescriptorFromProtoSetFiles(file)
+		if err != nil {
+			return nil, fmt.Errorf("load descriptor %s error: %w", file, err)
+		}
+		ds[namespace] = source
+	}
+
+	return core.NewOpenAPI(title, apps, ds), nil
+}
+
+func loadAppConfig(file string) (*core.AppConfig, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	typ := strings.TrimPrefix(filepath.Ext(file), ".")
+	if typ == "" {
+		typ = "yaml"
+	}
+
+	app := &core.AppConfig{}
+	if err = app.Unmarshal(f, typ); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
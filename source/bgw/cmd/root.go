@@ -23,6 +23,10 @@ var root = &cobra.Command{
 
 func Execute() {
 	root.AddCommand(newServerCmd())
+	root.AddCommand(newOpenAPICmd())
+	root.AddCommand(newLintCmd())
+	root.AddCommand(newDiffCmd())
+	root.AddCommand(newLimitCmd())
 
 	if err := root.Execute(); err != nil {
 		log.Fatal(err)
@@ -16,7 +16,7 @@ require (
 	code.bydev.io/fbu/gateway/gway.git/gcompliance v0.0.0-20231026032004-8e70e9b96f0d
 	code.bydev.io/fbu/gateway/gway.git/gconfig v0.0.0-20230831082700-3eb14baafa5c
 	code.bydev.io/fbu/gateway/gway.git/gcore v0.0.0-20230828025047-9bf39f142e06
-	code.bydev.io/fbu/gateway/gway.git/generic v0.0.0-20230306021408-7a0c92c51f77
+	code.bydev.io/fbu/gateway/gway.git/generic v0.1.0
 	code.bydev.io/fbu/gateway/gway.git/geo v0.0.0-20230830102241-d4132958b45f
 	code.bydev.io/fbu/gateway/gway.git/getcd v0.0.0-20230303030441-be688745d971
 	code.bydev.io/fbu/gateway/gway.git/ggrpc v0.0.0-20230831082700-3eb14baafa5c
@@ -26,7 +26,7 @@ require (
 	code.bydev.io/fbu/gateway/gway.git/gmetric v0.0.0-20231121070751-d82add688d0c
 	code.bydev.io/fbu/gateway/gway.git/gnacos v0.0.0-20230522080054-c2a2f489e112
 	code.bydev.io/fbu/gateway/gway.git/gopeninterest v0.0.0-20230911075128-4789db4af785
-	code.bydev.io/fbu/gateway/gway.git/gredis v0.0.0-20230612094657-098e98c9f6c8
+	code.bydev.io/fbu/gateway/gway.git/gredis v0.1.0
 	code.bydev.io/fbu/gateway/gway.git/groute v0.0.0-20230519022656-988878b4d2f8
 	code.bydev.io/fbu/gateway/gway.git/gs3 v0.0.0-20230303030441-be688745d971
 	code.bydev.io/fbu/gateway/gway.git/gsechub v0.0.0-20230303030441-be688745d971
@@ -17,4 +17,5 @@ const (
 	MetasRoundRobin        = "SELECTOR_METAS_ROUND_RANDOM"
 	SelectorConsistentHash = "SELECTOR_CONSISTENT_HASH"
 	SelectorMultiRegistry  = "SELECTOR_MULTI_REGISTRY"
+	SelectorLeastRequest   = "SELECTOR_LEAST_REQUEST"
 )
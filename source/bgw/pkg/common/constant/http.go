@@ -25,4 +25,8 @@ const (
 	UserAgent       = "User-Agent"
 	GFingerprint    = "gdfp"
 	CtxInvokeResult = "invoke.result"
+	CtxInvokeReq    = "invoke.request" // upstream request of the winner attempt
+	CtxInvokePicker = "invoke.picker"
+	CtxInvokeStream = "invoke.stream" // response is streamed by invoker, response filter is skipped
+	CtxBidiHandler  = "invoke.bidi"   // core.BidiHandler, serve bidi stream of upstream
 )
@@ -56,6 +56,7 @@ const (
 	BgwSelectMetas         = "ctx.select.metas"                 // select meta data
 	BgwRateLimitInfo       = "ctx.limit.info"                   // rate limit info
 	RiskSignBin            = "risk-sign-bin"
+	BgwMirrorFlag          = "bgw-mirror" // shadow request duplicated by mirror
 
 	BgwUpstreamCost      = "upstream-cost-time"       // upstream invoke cost time(ms)
 	BgwUserAccountCost   = "user-account-cost-time"   // user account invoke cost time(ms)
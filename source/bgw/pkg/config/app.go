@@ -19,10 +19,15 @@ func initAppCfg() {
 }
 
 type AppConfig struct {
-	App    App
-	Server Server
-	Data   Data
-	Log    Log
+	App          App
+	Server       Server
+	Data         Data
+	Log          Log
+	Outlier      Outlier      `json:",optional"`
+	LoadShedding LoadShedding `json:",optional"`
+	Stream       Stream       `json:",optional"`
+	// LimiterFallback local fallback of redis limiter v2
+	LimiterFallback LimiterFallback `json:",optional"`
 }
 
 type App struct {
@@ -56,11 +61,46 @@ type CacheSize struct {
 	OpenapiCacheSize       int `json:",optional"`
 	BizLimitQuotaCacheSize int `json:",optional"`
 	BanCacheSize           int `json:",optional"`
+	ResponseCacheSize      int `json:",optional"`
+}
+
+// Outlier passive health check of upstream instances
+type Outlier struct {
+	Enable            bool  `json:",optional"`
+	ConsecutiveErrors int   `json:",default=5"`   // consecutive errors or slow calls to eject
+	SlowThreshold     int64 `json:",optional"`    // ms, 0 is disabled
+	BaseEjectTime     int   `json:",default=30"`  // s, multiplied by eject times
+	MaxEjectTime      int   `json:",default=300"` // s
+	MaxEjectPercent   int   `json:",default=50"`  // max percent of instances can be ejected
+	RecoveryTime      int   `json:",default=30"`  // s, traffic recovers gradually after ejection
+}
+
+// LoadShedding adaptive load shedding of low priority routes
+type LoadShedding struct {
+	Enable           bool    `json:",optional"`
+	CPUThreshold     int     `json:",default=80"`    // percent of cpu quota to start shedding
+	MaxGoroutines    int     `json:",default=50000"` // goroutine count to start shedding
+	LatencyTarget    int64   `json:",default=50"`    // ms, min latency target of route
+	LatencyTolerance float64 `json:",default=2"`     // route is congested when latency exceeds tolerance * baseline
+	Interval         int64   `json:",default=100"`   // ms, codel interval, congestion shorter than it is ignored
+}
+
+// Stream limits of grpc streaming routes served over sse and websocket
+type Stream struct {
+	MaxStreams  int   `json:",default=10000"` // max open streams of the pod, new streams are rejected when exceeded
+	MaxLifetime int64 `json:",default=3600"`  // s, stream is closed when exceeded, client should reconnect
+}
+
+// LimiterFallback thresholds of switching redis limiter v2 to per-pod local limiter
+type LimiterFallback struct {
+	SlowThreshold    int64 `json:",default=100"` // ms, redis call slower than this is counted as failure
+	FailureThreshold int   `json:",default=3"`   // consecutive failures or slow calls to switch to local limiter
 }
 
 type Log struct {
 	BgwLog    LogCfg
 	AccessLog LogCfg
+	MirrorLog LogCfg `json:",optional"`
 }
 
 type LogCfg struct {
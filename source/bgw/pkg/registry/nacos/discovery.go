@@ -330,6 +330,9 @@ const (
 	cluster        = "cluster"
 )
 
+// RegisterGroup group of gateway instances registered by BuildRegister
+const RegisterGroup = tgwGroup
+
 // BuildRegister build register
 func BuildRegister(serverPrefix string, port int, conf config.ServiceRegistry) (*bn.Register, error) {
 	if !conf.Enable {
@@ -103,3 +103,27 @@ func AllowM(ctx context.Context, l *gredis.Limiter, key string, limit gredis.Lim
 
 	return res, nil
 }
+
+// AllowMulti reports whether the events may happen at time now for all limits, they are counted only when all allowed.
+// keys of limits must be in the same hash slot, see gredis.KeySlot.
+func AllowMulti(ctx context.Context, l *gredis.Limiter, limits []gredis.MultiLimit) ([]*gredis.Result, error) {
+	span, _ := gtrace.Begin(ctx, fmt.Sprintf("redis-limiter-AllowMulti:%d", len(limits)))
+	defer gtrace.Finish(span)
+
+	if downgrade {
+		return nil, errors.New("redis limiter downgrade")
+	}
+
+	res, err := l.AllowMulti(ctx, limits)
+	if err != nil {
+		gmetric.IncDefaultError("redis", "allowMulti")
+		rateLog.Do(func() {
+			glog.Error(ctx, "redis.AllowMulti error", glog.Any("limits", limits), glog.String("err", err.Error()))
+		})
+		return nil, err
+	}
+
+	span.SetTag("limit-count", len(limits))
+
+	return res, nil
+}
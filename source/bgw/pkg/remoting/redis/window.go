@@ -0,0 +1,339 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"code.bydev.io/fbu/gateway/gway.git/gmetric"
+	"code.bydev.io/fbu/gateway/gway.git/gredis"
+	"code.bydev.io/fbu/gateway/gway.git/gtrace"
+	"code.bydev.io/frameworks/byone/core/stores/redis"
+	"github.com/rs/xid"
+)
+
+const (
+	slidingLogPrefix    = "BGW:rate_limit:sliding_log:"
+	slidingWindowPrefix = "BGW:rate_limit:sliding_window:"
+	concurrencyPrefix   = "BGW:rate_limit:concurrency:"
+
+	// concurrencyMinTTL lease of in-flight slot, protects from leaked slots when pod crashed before release
+	concurrencyMinTTL         = time.Minute
+	concurrencyReleaseQueue   = 4096
+	concurrencyReleaseWorkers = 4
+	scriptTimeout             = 3 * time.Second
+)
+
+// slidingLog keeps a sorted set of request timestamps of last period, which gives an exact count.
+// ARGV: limit, period(ms), n
+// return: {allowed, remaining, retry_after(ms), reset_after(ms)}
+var slidingLog = redis.NewScript(`
+redis.replicate_commands()
+
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - period)
+local count = redis.call("ZCARD", KEYS[1])
+
+if count + cost > limit then
+  local retry_after = period
+  local oldest = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+  if oldest[2] then
+    retry_after = tonumber(oldest[2]) + period - now
+  end
+  local remaining = limit - count
+  if remaining < 0 then
+    remaining = 0
+  end
+  return {0, remaining, retry_after, retry_after}
+end
+
+-- members in the same millisecond are distinguished by the increasing count
+for i = 1, cost do
+  redis.call("ZADD", KEYS[1], now, t[1] .. t[2] .. "-" .. (count + i))
+end
+redis.call("PEXPIRE", KEYS[1], period)
+
+return {cost, limit - count - cost, 0, period}
+`)
+
+// slidingWindow weights the counter of previous fixed window by its overlap with the sliding window,
+// both windows are fields of one hash so the script touches a single key.
+// ARGV: limit, period(ms), n
+// return: {allowed, remaining, retry_after(ms), reset_after(ms)}
+var slidingWindow = redis.NewScript(`
+redis.replicate_commands()
+
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local window = math.floor(now / period)
+local elapsed = now - window * period
+local cur = tonumber(redis.call("HGET", KEYS[1], tostring(window)) or "0")
+local prev = tonumber(redis.call("HGET", KEYS[1], tostring(window - 1)) or "0")
+local count = math.floor(prev * (period - elapsed) / period) + cur
+
+if count + cost > limit then
+  local remaining = limit - count
+  if remaining < 0 then
+    remaining = 0
+  end
+  return {0, remaining, period - elapsed, period - elapsed}
+end
+
+redis.call("HINCRBY", KEYS[1], tostring(window), cost)
+redis.call("HDEL", KEYS[1], tostring(window - 2))
+redis.call("PEXPIRE", KEYS[1], period * 2)
+
+return {cost, limit - count - cost, 0, period - elapsed}
+`)
+
+// concurrencyAcquire takes one in-flight slot, every slot has its own lease in a sorted set of slot -> expire time,
+// so a slot leaked by a crashed pod expires alone instead of the whole counter being refreshed by live requests.
+// ARGV: limit, ttl(ms), slot
+// return: {allowed, remaining}
+var concurrencyAcquire = redis.NewScript(`
+redis.replicate_commands()
+
+local limit = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[2])
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+local current = redis.call("ZCARD", KEYS[1])
+if current >= limit then
+  return {0, 0}
+end
+
+redis.call("ZADD", KEYS[1], now + ttl, ARGV[3])
+redis.call("PEXPIRE", KEYS[1], ttl)
+return {1, limit - current - 1}
+`)
+
+var concurrencyRelease = redis.NewScript(`
+return redis.call("ZREM", KEYS[1], ARGV[1])
+`)
+
+// concurrencyPeek counts the slots whose lease is not expired
+var concurrencyPeek = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+return redis.call("ZCOUNT", KEYS[1], "(" .. now, "+inf")
+`)
+
+// AllowSlidingLog reports whether n events may happen in the last period, counted exactly by a timestamp log.
+func AllowSlidingLog(ctx context.Context, key string, limit gredis.Limit, n int) (*gredis.Result, error) {
+	return allowWindow(ctx, "allowSlidingLog", slidingLog, slidingLogPrefix+key, limit, n)
+}
+
+// AllowSlidingWindow reports whether n events may happen in the last period, approximated by two fixed windows.
+func AllowSlidingWindow(ctx context.Context, key string, limit gredis.Limit, n int) (*gredis.Result, error) {
+	return allowWindow(ctx, "allowSlidingWindow", slidingWindow, slidingWindowPrefix+key, limit, n)
+}
+
+func allowWindow(ctx context.Context, name string, script *redis.Script, key string, limit gredis.Limit, n int) (*gredis.Result, error) {
+	span, _ := gtrace.Begin(ctx, fmt.Sprintf("redis-limiter-%s:%s", name, key))
+	defer gtrace.Finish(span)
+
+	rdb, err := scriptClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := withScriptTimeout(ctx)
+	defer cancel()
+
+	period := limit.Period
+	if period <= 0 {
+		period = time.Second
+	}
+	v, err := rdb.ScriptRunCtx(ctx, script, []string{key}, limit.Rate, period.Milliseconds(), n)
+	if err != nil {
+		onScriptError(ctx, name, key, limit, err)
+		return nil, err
+	}
+
+	values, err := parseInts(v, 4)
+	if err != nil {
+		onScriptError(ctx, name, key, limit, err)
+		return nil, err
+	}
+
+	span.SetTag("limit-rate", limit.Rate)
+	span.SetTag("limit-Period", limit.Period)
+	span.SetTag("limit-Allowed", n)
+
+	return &gredis.Result{
+		Limit:      limit,
+		Allowed:    int(values[0]),
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+// AcquireConcurrency takes one in-flight slot of key, limit.Rate is the max in-flight requests.
+// Callers must ReleaseConcurrency with the returned slot after the request is finished if Allowed > 0.
+func AcquireConcurrency(ctx context.Context, key string, limit gredis.Limit) (*gredis.Result, string, error) {
+	span, _ := gtrace.Begin(ctx, fmt.Sprintf("redis-limiter-acquireConcurrency:%s", key))
+	defer gtrace.Finish(span)
+
+	rdb, err := scriptClient()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := withScriptTimeout(ctx)
+	defer cancel()
+
+	ttl := limit.Period
+	if ttl < concurrencyMinTTL {
+		ttl = concurrencyMinTTL
+	}
+	slot := xid.New().String()
+	v, err := rdb.ScriptRunCtx(ctx, concurrencyAcquire, []string{concurrencyPrefix + key}, limit.Rate, ttl.Milliseconds(), slot)
+	if err != nil {
+		onScriptError(ctx, "acquireConcurrency", key, limit, err)
+		return nil, "", err
+	}
+
+	values, err := parseInts(v, 2)
+	if err != nil {
+		onScriptError(ctx, "acquireConcurrency", key, limit, err)
+		return nil, "", err
+	}
+
+	span.SetTag("limit-rate", limit.Rate)
+	span.SetTag("limit-Allowed", values[0])
+
+	return &gredis.Result{
+		Limit:     limit,
+		Allowed:   int(values[0]),
+		Remaining: int(values[1]),
+	}, slot, nil
+}
+
+type concurrencySlot struct {
+	key  string
+	slot string
+}
+
+var (
+	releaseOnce sync.Once
+	releaseCh   = make(chan concurrencySlot, concurrencyReleaseQueue)
+)
+
+// ReleaseConcurrency returns the in-flight slot taken by AcquireConcurrency asynchronously, so the response is not
+// delayed by redis. The slot is left to its lease when the queue is full.
+func ReleaseConcurrency(key, slot string) {
+	releaseOnce.Do(func() {
+		for i := 0; i < concurrencyReleaseWorkers; i++ {
+			go releaseLoop()
+		}
+	})
+
+	select {
+	case releaseCh <- concurrencySlot{key: key, slot: slot}:
+	default:
+		gmetric.IncDefaultError("redis", "releaseConcurrency_discard")
+	}
+}
+
+func releaseLoop() {
+	for s := range releaseCh {
+		_ = releaseConcurrency(context.Background(), s.key, s.slot)
+	}
+}
+
+func releaseConcurrency(ctx context.Context, key, slot string) error {
+	rdb, err := scriptClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withScriptTimeout(ctx)
+	defer cancel()
+
+	if _, err = rdb.ScriptRunCtx(ctx, concurrencyRelease, []string{concurrencyPrefix + key}, slot); err != nil {
+		onScriptError(ctx, "releaseConcurrency", key, gredis.Limit{}, err)
+		return err
+	}
+	return nil
+}
+
+// PeekConcurrency returns the in-flight count of key without taking a slot.
+func PeekConcurrency(ctx context.Context, key string) (int, error) {
+	rdb, err := scriptClient()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := withScriptTimeout(ctx)
+	defer cancel()
+
+	v, err := rdb.ScriptRunCtx(ctx, concurrencyPeek, []string{concurrencyPrefix + key})
+	if err != nil {
+		return 0, err
+	}
+	count, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected result type %T", v)
+	}
+	return int(count), nil
+}
+
+func scriptClient() (*redis.Redis, error) {
+	if downgrade {
+		return nil, errors.New("redis limiter downgrade")
+	}
+	rdb := NewClient()
+	if rdb == nil {
+		return nil, errors.New("redis client not initialized")
+	}
+	return rdb, nil
+}
+
+func withScriptTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, scriptTimeout)
+}
+
+func onScriptError(ctx context.Context, name, key string, limit gredis.Limit, err error) {
+	gmetric.IncDefaultError("redis", name)
+	rateLog.Do(func() {
+		glog.Error(ctx, "redis."+name+" error", glog.String("key", key), glog.Any("limit", limit), glog.String("err", err.Error()))
+	})
+}
+
+func parseInts(v interface{}, n int) ([]int64, error) {
+	values, ok := v.([]interface{})
+	if !ok || len(values) != n {
+		return nil, fmt.Errorf("unexpected result type %T", v)
+	}
+
+	res := make([]int64, n)
+	for i, value := range values {
+		iv, ok := value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("unexpected result value %T", value)
+		}
+		res[i] = iv
+	}
+	return res, nil
+}
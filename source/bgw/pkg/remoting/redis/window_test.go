@@ -0,0 +1,24 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseInts(t *testing.T) {
+	convey.Convey("TestParseInts", t, func() {
+		values, err := parseInts([]interface{}{int64(1), int64(9), int64(0), int64(1000)}, 4)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(values, convey.ShouldResemble, []int64{1, 9, 0, 1000})
+
+		_, err = parseInts([]interface{}{int64(1)}, 2)
+		convey.So(err, convey.ShouldNotBeNil)
+
+		_, err = parseInts([]interface{}{int64(1), "2"}, 2)
+		convey.So(err, convey.ShouldNotBeNil)
+
+		_, err = parseInts("1", 1)
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
@@ -13,6 +13,7 @@ import (
 
 	"bgw/pkg/server/cluster"
 	_ "bgw/pkg/server/cluster/selector/consistent_hash"
+	_ "bgw/pkg/server/cluster/selector/least_request"
 	_ "bgw/pkg/server/cluster/selector/metas_roundrobin"
 	_ "bgw/pkg/server/cluster/selector/multi_registry"
 	_ "bgw/pkg/server/cluster/selector/raft"
@@ -35,6 +36,7 @@ import (
 	"bgw/pkg/server/filter/biz_limiter"
 	_ "bgw/pkg/server/filter/biz_limiter"
 	"bgw/pkg/server/filter/bsp"
+	"bgw/pkg/server/filter/cache"
 	"bgw/pkg/server/filter/compliance"
 	_ "bgw/pkg/server/filter/compliance"
 	fcontext "bgw/pkg/server/filter/context"
@@ -61,6 +63,7 @@ import (
 	_ "bgw/pkg/server/filter/signature"
 	"bgw/pkg/server/filter/trace"
 	_ "bgw/pkg/server/filter/trace"
+	"bgw/pkg/server/filter/transform"
 
 	"bgw/pkg/config"
 	"bgw/pkg/server/http"
@@ -85,7 +88,7 @@ func run(name string) error {
 		service.InitLogger()
 		initTracing()
 		gmetric.Init("bgw")
-		registerFilters()
+		RegisterFilters()
 
 		service.InitGrpc()
 		logx.DisableStat()
@@ -119,7 +122,8 @@ func run(name string) error {
 	}
 }
 
-func registerFilters() {
+// RegisterFilters register all filters, it's also used by offline tools to validate filter args
+func RegisterFilters() {
 	cors.Init()
 	fcontext.Init()
 	accesslog.Init()
@@ -140,6 +144,8 @@ func registerFilters() {
 	limiter.Init()
 	ban.Init()
 	bsp.Init()
+	transform.Init()
+	cache.Init()
 }
 
 type server interface {
@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// latencyTau decay time of latency ewma, idle instance is probed again when latency decays
+	latencyTau = 10 * time.Second
+	// failPenalty latency sample of failed call, avoid fast failed instance attracting traffic
+	failPenalty = time.Second
+)
+
+// Outcome outcome of an upstream call
+type Outcome int
+
+const (
+	OutcomeSuccess Outcome = iota
+	OutcomeFailed
+	// OutcomeCanceled call is canceled by gateway, e.g. hedge loser, the instance is not judged
+	OutcomeCanceled
+)
+
+// loads in-flight requests and latency of upstream pods, keyed by host,
+// instance exposes different ports per protocol, so load is tracked per pod
+var loads sync.Map // host -> *Load
+
+// Load load of an upstream pod
+type Load struct {
+	sync.Mutex
+	inflight int64
+	latency  float64 // ms, ewma
+	updated  time.Time
+}
+
+// Inflight get in-flight requests
+func (l *Load) Inflight() int64 {
+	l.Lock()
+	defer l.Unlock()
+	return l.inflight
+}
+
+// Latency get ewma latency in ms, decayed by idle time
+func (l *Load) Latency(now time.Time) float64 {
+	l.Lock()
+	defer l.Unlock()
+	return l.decayed(now)
+}
+
+func (l *Load) decayed(now time.Time) float64 {
+	if l.updated.IsZero() {
+		return 0
+	}
+	idle := now.Sub(l.updated)
+	if idle <= 0 {
+		return l.latency
+	}
+	return l.latency * math.Exp(-float64(idle)/float64(latencyTau))
+}
+
+func (l *Load) observe(now time.Time, cost time.Duration) {
+	ms := float64(cost) / float64(time.Millisecond)
+	if l.updated.IsZero() {
+		l.latency = ms
+	} else {
+		w := math.Exp(-float64(now.Sub(l.updated)) / float64(latencyTau))
+		l.latency = l.latency*w + ms*(1-w)
+	}
+	l.updated = now
+}
+
+// GetLoad get load of upstream pod by host
+func GetLoad(host string) *Load {
+	v, _ := loads.LoadOrStore(host, &Load{})
+	return v.(*Load)
+}
+
+// InvokeStart mark an upstream call to addr is started, InvokeDone must be called when the call is done
+func InvokeStart(addr string) {
+	l := GetLoad(hostOf(addr))
+	l.Lock()
+	l.inflight++
+	l.Unlock()
+}
+
+// InvokeDone mark an upstream call to addr is done, load and outlier detector are updated by outcome
+func InvokeDone(addr string, cost time.Duration, outcome Outcome) {
+	now := time.Now()
+	l := GetLoad(hostOf(addr))
+	l.Lock()
+	if l.inflight > 0 {
+		// load may be removed and recreated by instance deregistration during the call
+		l.inflight--
+	}
+	switch outcome {
+	case OutcomeSuccess:
+		l.observe(now, cost)
+	case OutcomeFailed:
+		sample := cost
+		if sample < failPenalty {
+			sample = failPenalty
+		}
+		l.observe(now, sample)
+	}
+	l.Unlock()
+
+	if outcome != OutcomeCanceled {
+		getDetector().report(addr, cost, outcome == OutcomeFailed)
+	}
+}
+
+// OnInstanceRemove remove load of deregistered instances, busy pods are kept until next removal,
+// addresses of another service on the same pod are removed too, their load is rebuilt by next calls
+func OnInstanceRemove(_ string, addrs []string) error {
+	for _, addr := range addrs {
+		host := hostOf(addr)
+		v, ok := loads.Load(host)
+		if !ok {
+			continue
+		}
+		if v.(*Load).Inflight() == 0 {
+			loads.Delete(host)
+		}
+	}
+	return nil
+}
+
+func hostOf(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,32 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOnInstanceRemove(t *testing.T) {
+	Convey("test remove load of deregistered instance", t, func() {
+		InvokeStart("10.0.1.1:9090")
+		InvokeDone("10.0.1.1:9090", time.Millisecond, OutcomeCanceled)
+		InvokeStart("10.0.1.2:9090")
+
+		So(OnInstanceRemove("svc", []string{"10.0.1.1:9090", "10.0.1.2:9090"}), ShouldBeNil)
+		_, ok := loads.Load("10.0.1.1")
+		So(ok, ShouldBeFalse)
+		// busy pod is kept
+		_, ok = loads.Load("10.0.1.2")
+		So(ok, ShouldBeTrue)
+
+		InvokeDone("10.0.1.2:9090", time.Millisecond, OutcomeCanceled)
+		So(OnInstanceRemove("svc", []string{"10.0.1.2:9090"}), ShouldBeNil)
+		_, ok = loads.Load("10.0.1.2")
+		So(ok, ShouldBeFalse)
+
+		// done after removed, inflight never goes negative
+		InvokeDone("10.0.1.3:9090", time.Millisecond, OutcomeCanceled)
+		So(GetLoad("10.0.1.3").Inflight(), ShouldEqual, 0)
+	})
+}
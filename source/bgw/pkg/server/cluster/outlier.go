@@ -0,0 +1,317 @@
+package cluster
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"code.bydev.io/fbu/gateway/gway.git/gmetric"
+
+	"bgw/pkg/config"
+	"bgw/pkg/registry"
+)
+
+const (
+	outlierMetricType = "outlier"
+
+	defaultConsecutiveErrors = 5
+	defaultBaseEjectTime     = 30 * time.Second
+	defaultMaxEjectTime      = 300 * time.Second
+	defaultMaxEjectPercent   = 50
+	defaultRecoveryTime      = 30 * time.Second
+
+	outlierExpire      = 10 * time.Minute
+	outlierPurgePeriod = time.Minute
+	latencyDecay       = 0.3
+)
+
+// outlier states
+const (
+	OutlierHealthy    = "healthy"
+	OutlierEjected    = "ejected"
+	OutlierRecovering = "recovering"
+)
+
+var (
+	detectorOnce sync.Once
+	detector     *outlierDetector
+)
+
+// OutlierStatus ejection state of upstream instance
+type OutlierStatus struct {
+	Addr         string    `json:"addr"`
+	State        string    `json:"state"`
+	Failures     int       `json:"failures"`    // consecutive errors or slow calls
+	Latency      int64     `json:"latency"`     // ms, ewma
+	EjectTimes   int       `json:"eject_times"` // continuous eject times, reset after recovered
+	EjectUntil   time.Time `json:"eject_until,omitempty"`
+	RecoverUntil time.Time `json:"recover_until,omitempty"`
+}
+
+type outlierStat struct {
+	sync.Mutex
+	failures     int
+	latency      float64 // ms
+	ejectTimes   int
+	ejectUntil   time.Time
+	recoverUntil time.Time
+	updated      time.Time
+}
+
+// state get state of instance at now
+func (s *outlierStat) state(now time.Time) string {
+	switch {
+	case now.Before(s.ejectUntil):
+		return OutlierEjected
+	case now.Before(s.recoverUntil):
+		return OutlierRecovering
+	default:
+		return OutlierHealthy
+	}
+}
+
+type outlierDetector struct {
+	enable            bool
+	consecutiveErrors int
+	slowThreshold     time.Duration
+	baseEjectTime     time.Duration
+	maxEjectTime      time.Duration
+	maxEjectPercent   int
+	recoveryTime      time.Duration
+
+	stats     sync.Map // addr -> *outlierStat
+	purgeLock sync.Mutex
+	lastPurge time.Time
+}
+
+func newOutlierDetector(cfg config.Outlier) *outlierDetector {
+	d := &outlierDetector{
+		enable:            cfg.Enable,
+		consecutiveErrors: cfg.ConsecutiveErrors,
+		slowThreshold:     time.Duration(cfg.SlowThreshold) * time.Millisecond,
+		baseEjectTime:     time.Duration(cfg.BaseEjectTime) * time.Second,
+		maxEjectTime:      time.Duration(cfg.MaxEjectTime) * time.Second,
+		maxEjectPercent:   cfg.MaxEjectPercent,
+		recoveryTime:      time.Duration(cfg.RecoveryTime) * time.Second,
+		lastPurge:         time.Now(),
+	}
+
+	if d.consecutiveErrors <= 0 {
+		d.consecutiveErrors = defaultConsecutiveErrors
+	}
+	if d.baseEjectTime <= 0 {
+		d.baseEjectTime = defaultBaseEjectTime
+	}
+	if d.maxEjectTime < d.baseEjectTime {
+		d.maxEjectTime = defaultMaxEjectTime
+		if d.maxEjectTime < d.baseEjectTime {
+			d.maxEjectTime = d.baseEjectTime
+		}
+	}
+	if d.maxEjectPercent <= 0 || d.maxEjectPercent > 100 {
+		d.maxEjectPercent = defaultMaxEjectPercent
+	}
+	if d.recoveryTime < 0 {
+		d.recoveryTime = defaultRecoveryTime
+	}
+
+	return d
+}
+
+func getDetector() *outlierDetector {
+	detectorOnce.Do(func() {
+		detector = newOutlierDetector(config.Global.Outlier)
+	})
+	return detector
+}
+
+// EjectOutliers remove ejected instances from the list handed to selector,
+// recovering instances are kept by a probability which increases linearly in recovery time
+func EjectOutliers(ins []registry.ServiceInstance, protocol string) []registry.ServiceInstance {
+	return getDetector().filter(ins, protocol, time.Now())
+}
+
+// OutlierSnapshot get ejection state of all tracked instances
+func OutlierSnapshot() []*OutlierStatus {
+	return getDetector().snapshot(time.Now())
+}
+
+func (d *outlierDetector) report(addr string, cost time.Duration, failed bool) {
+	if !d.enable || addr == "" {
+		return
+	}
+
+	now := time.Now()
+	v, _ := d.stats.LoadOrStore(addr, &outlierStat{})
+	s := v.(*outlierStat)
+
+	s.Lock()
+	s.updated = now
+	ms := float64(cost) / float64(time.Millisecond)
+	if s.latency == 0 {
+		s.latency = ms
+	} else {
+		s.latency = s.latency*(1-latencyDecay) + ms*latencyDecay
+	}
+
+	if d.slowThreshold > 0 && cost > d.slowThreshold {
+		failed = true
+	}
+
+	state := s.state(now)
+	switch {
+	case !failed:
+		s.failures = 0
+		if state == OutlierHealthy {
+			s.ejectTimes = 0
+		}
+	case state == OutlierEjected:
+		// late response of request sent before ejection
+	case state == OutlierRecovering:
+		// failed again while recovering, eject at once
+		d.eject(addr, s, now)
+	default:
+		s.failures++
+		if s.failures >= d.consecutiveErrors {
+			d.eject(addr, s, now)
+		}
+	}
+	s.Unlock()
+
+	d.purge(now)
+}
+
+// eject must be called with lock held
+func (d *outlierDetector) eject(addr string, s *outlierStat, now time.Time) {
+	s.ejectTimes++
+	duration := d.baseEjectTime * time.Duration(s.ejectTimes)
+	if duration > d.maxEjectTime {
+		duration = d.maxEjectTime
+	}
+
+	s.failures = 0
+	s.ejectUntil = now.Add(duration)
+	s.recoverUntil = s.ejectUntil.Add(d.recoveryTime)
+
+	gmetric.IncDefaultCounter(outlierMetricType, "eject")
+	glog.Info(context.Background(), "outlier instance ejected", glog.String("addr", addr),
+		glog.Int64("times", int64(s.ejectTimes)), glog.Duration("duration", duration))
+}
+
+func (d *outlierDetector) filter(ins []registry.ServiceInstance, protocol string, now time.Time) []registry.ServiceInstance {
+	if !d.enable || len(ins) == 0 {
+		return ins
+	}
+
+	// never eject more than max percent of instances, at least one is kept
+	quota := len(ins) * d.maxEjectPercent / 100
+	if quota >= len(ins) {
+		quota = len(ins) - 1
+	}
+	if quota <= 0 {
+		return ins
+	}
+
+	var res []registry.ServiceInstance
+	for i, in := range ins {
+		if !d.ejected(in.GetAddress(protocol), now) {
+			if res != nil {
+				res = append(res, in)
+			}
+			continue
+		}
+
+		if quota == 0 {
+			if res != nil {
+				res = append(res, in)
+			}
+			continue
+		}
+		quota--
+		if res == nil {
+			res = make([]registry.ServiceInstance, i, len(ins))
+			copy(res, ins[:i])
+		}
+	}
+
+	if res == nil {
+		return ins
+	}
+	return res
+}
+
+// ejected check if the instance should be skipped
+func (d *outlierDetector) ejected(addr string, now time.Time) bool {
+	v, ok := d.stats.Load(addr)
+	if !ok {
+		return false
+	}
+	s := v.(*outlierStat)
+
+	s.Lock()
+	defer s.Unlock()
+	switch s.state(now) {
+	case OutlierEjected:
+		return true
+	case OutlierRecovering:
+		// traffic ratio of recovering instance grows from 0 to 1
+		elapsed := now.Sub(s.ejectUntil)
+		return rand.Float64() >= float64(elapsed)/float64(d.recoveryTime)
+	default:
+		return false
+	}
+}
+
+func (d *outlierDetector) snapshot(now time.Time) []*OutlierStatus {
+	res := make([]*OutlierStatus, 0)
+	d.stats.Range(func(key, value interface{}) bool {
+		s := value.(*outlierStat)
+		s.Lock()
+		st := &OutlierStatus{
+			Addr:       key.(string),
+			State:      s.state(now),
+			Failures:   s.failures,
+			Latency:    int64(s.latency),
+			EjectTimes: s.ejectTimes,
+		}
+		if st.State != OutlierHealthy {
+			st.EjectUntil = s.ejectUntil
+			st.RecoverUntil = s.recoverUntil
+		}
+		s.Unlock()
+		res = append(res, st)
+		return true
+	})
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Addr < res[j].Addr
+	})
+	return res
+}
+
+// purge remove instances not invoked for a long time
+func (d *outlierDetector) purge(now time.Time) {
+	if !d.purgeLock.TryLock() {
+		return
+	}
+	defer d.purgeLock.Unlock()
+
+	if now.Sub(d.lastPurge) < outlierPurgePeriod {
+		return
+	}
+	d.lastPurge = now
+
+	d.stats.Range(func(key, value interface{}) bool {
+		s := value.(*outlierStat)
+		s.Lock()
+		expired := now.Sub(s.updated) > outlierExpire && s.state(now) == OutlierHealthy
+		s.Unlock()
+		if expired {
+			d.stats.Delete(key)
+		}
+		return true
+	})
+}
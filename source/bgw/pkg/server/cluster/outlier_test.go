@@ -0,0 +1,71 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"bgw/pkg/config"
+	"bgw/pkg/registry"
+)
+
+func TestOutlierDetector(t *testing.T) {
+	Convey("test outlier detector", t, func() {
+		d := newOutlierDetector(config.Outlier{
+			Enable:            true,
+			ConsecutiveErrors: 2,
+			SlowThreshold:     100,
+			BaseEjectTime:     1,
+			MaxEjectPercent:   50,
+			RecoveryTime:      1,
+		})
+		So(d.maxEjectTime, ShouldEqual, defaultMaxEjectTime)
+
+		ins := []registry.ServiceInstance{
+			&registry.DefaultServiceInstance{Host: "127.0.0.1", Port: 9001},
+			&registry.DefaultServiceInstance{Host: "127.0.0.1", Port: 9002},
+			&registry.DefaultServiceInstance{Host: "127.0.0.1", Port: 9003},
+			&registry.DefaultServiceInstance{Host: "127.0.0.1", Port: 9004},
+		}
+		addr := func(i int) string { return ins[i].GetAddress("grpc") }
+
+		// success resets consecutive errors
+		d.report(addr(0), time.Millisecond, true)
+		d.report(addr(0), time.Millisecond, false)
+		d.report(addr(0), time.Millisecond, true)
+		So(len(d.filter(ins, "grpc", time.Now())), ShouldEqual, 4)
+
+		// consecutive errors and slow calls
+		d.report(addr(0), time.Millisecond, true)
+		d.report(addr(1), time.Second, false)
+		d.report(addr(1), time.Second, false)
+		d.report(addr(2), time.Millisecond, true)
+		d.report(addr(2), time.Millisecond, true)
+
+		now := time.Now()
+		res := d.filter(ins, "grpc", now)
+		So(len(res), ShouldEqual, 2) // max eject percent
+		So(res[0], ShouldEqual, ins[2])
+		So(res[1], ShouldEqual, ins[3])
+
+		st := d.snapshot(now)
+		So(len(st), ShouldEqual, 3)
+		So(st[0].State, ShouldEqual, OutlierEjected)
+		So(st[0].EjectTimes, ShouldEqual, 1)
+		So(st[1].Latency, ShouldEqual, 1000)
+
+		// recovering instance receives part of traffic and is kept after recovery time
+		So(len(d.filter(ins, "grpc", now.Add(1500*time.Millisecond))), ShouldBeBetweenOrEqual, 2, 4)
+		So(len(d.filter(ins, "grpc", now.Add(3*time.Second))), ShouldEqual, 4)
+
+		// single instance is never ejected
+		So(len(d.filter(ins[:1], "grpc", now)), ShouldEqual, 1)
+
+		// disabled
+		d = newOutlierDetector(config.Outlier{})
+		d.report(addr(0), time.Millisecond, true)
+		So(len(d.snapshot(now)), ShouldEqual, 0)
+		So(len(d.filter(ins, "grpc", now)), ShouldEqual, 4)
+	})
+}
@@ -0,0 +1,68 @@
+package least_request
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/registry"
+	"bgw/pkg/server/cluster"
+)
+
+func init() {
+	cluster.Register(constant.SelectorLeastRequest, New())
+}
+
+// defaultLatency latency of pod without any sample, ms
+const defaultLatency = 1.0
+
+type leastRequestLoadBalance struct{}
+
+// New returns a least request load balance.
+// Power of two choices, two instances are picked randomly and the one with lower load wins,
+// load is ewma latency * (in-flight requests + 1) / weight.
+func New() cluster.Selector {
+	return &leastRequestLoadBalance{}
+}
+
+// Select gets instance based on p2c load balancing strategy
+func (lb *leastRequestLoadBalance) Select(ctx context.Context, ins []registry.ServiceInstance) (registry.ServiceInstance, error) {
+	ins = cluster.LocalAware(cluster.SwimLaneSelector(ctx, ins))
+
+	length := len(ins)
+	if length == 0 {
+		return nil, cluster.ErrServiceNotFound
+	}
+	if length == 1 {
+		return ins[0], nil
+	}
+
+	i := rand.Intn(length)
+	j := rand.Intn(length - 1)
+	if j >= i {
+		j++
+	}
+
+	now := time.Now()
+	if score(ins[j], now) < score(ins[i], now) {
+		return ins[j], nil
+	}
+	return ins[i], nil
+}
+
+// score load of instance, lower is better
+func score(in registry.ServiceInstance, now time.Time) float64 {
+	load := cluster.GetLoad(in.GetHost())
+	latency := load.Latency(now)
+	if latency < defaultLatency {
+		latency = defaultLatency
+	}
+
+	weight := in.GetWeight()
+	if weight <= 0 {
+		weight = 1
+	}
+
+	return latency * float64(load.Inflight()+1) / float64(weight)
+}
@@ -0,0 +1,51 @@
+package least_request
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"bgw/pkg/registry"
+	"bgw/pkg/server/cluster"
+)
+
+func TestLeastRequestLoadBalance_Select(t *testing.T) {
+	convey.Convey("TestLeastRequestLoadBalance_Select", t, func() {
+		lb := New()
+		ctx := context.Background()
+
+		_, err := lb.Select(ctx, nil)
+		convey.So(err, convey.ShouldNotBeNil)
+
+		ins := []registry.ServiceInstance{
+			&registry.DefaultServiceInstance{ID: "1", Host: "10.0.0.1", Port: 9090},
+		}
+		res, err := lb.Select(ctx, ins)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(res.GetID(), convey.ShouldEqual, "1")
+
+		// busy and slow pod loses
+		ins = append(ins, &registry.DefaultServiceInstance{ID: "2", Host: "10.0.0.2", Port: 9090})
+		cluster.InvokeStart("10.0.0.1:9090")
+		cluster.InvokeStart("10.0.0.1:9090")
+		cluster.InvokeDone("10.0.0.1:9090", 100*time.Millisecond, cluster.OutcomeSuccess)
+		cluster.InvokeStart("10.0.0.2:9090")
+		cluster.InvokeDone("10.0.0.2:9090", 10*time.Millisecond, cluster.OutcomeSuccess)
+		for i := 0; i < 10; i++ {
+			res, err = lb.Select(ctx, ins)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res.GetID(), convey.ShouldEqual, "2")
+		}
+
+		// weight
+		ins[0].(*registry.DefaultServiceInstance).Weight = 100
+		for i := 0; i < 10; i++ {
+			res, err = lb.Select(ctx, ins)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res.GetID(), convey.ShouldEqual, "1")
+		}
+		cluster.InvokeDone("10.0.0.1:9090", 0, cluster.OutcomeCanceled)
+	})
+}
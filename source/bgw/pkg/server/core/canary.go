@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+
+	"bgw/pkg/server/metadata"
+)
+
+// canaryBuckets hash buckets of uid, weights are relative
+const canaryBuckets = 10000
+
+var (
+	// canaryRoutes route -> *MethodConfig, routes with backends
+	canaryRoutes sync.Map
+	// canaryOverrides route -> []*Backend, weights changed at runtime by admin
+	canaryOverrides sync.Map
+)
+
+// Backend weighted registry group of method, traffic is split by uid hash.
+// List the stable group first and canary groups later, so that raising canary weight keeps assigned users sticky.
+type Backend struct {
+	Group  string `json:"group" yaml:"group"`
+	Weight int    `json:"weight" yaml:"weight"`
+}
+
+// CanaryState weights of canary route
+type CanaryState struct {
+	Route      string     `json:"route"`
+	Configured []*Backend `json:"configured"`
+	Override   []*Backend `json:"override,omitempty"`
+}
+
+// canaryKey route key of canary, app.module.service.registry.method
+func (m *MethodConfig) canaryKey() string {
+	return m.RouteKey().AsMethod()
+}
+
+// getBackends get weighted backends, runtime override first
+func (m *MethodConfig) getBackends() []*Backend {
+	if len(m.Backends) == 0 {
+		return nil
+	}
+	if v, ok := canaryOverrides.Load(m.canaryKey()); ok {
+		return v.([]*Backend)
+	}
+	return m.Backends
+}
+
+// registerCanary register route which can be changed at runtime
+func registerCanary(mc *MethodConfig) {
+	if len(mc.Backends) == 0 {
+		return
+	}
+	key := mc.canaryKey()
+	canaryRoutes.Store(key, mc)
+
+	// drop the override if the groups are not configured any more
+	if v, ok := canaryOverrides.Load(key); ok {
+		if err := checkBackends(mc, v.([]*Backend)); err != nil {
+			canaryOverrides.Delete(key)
+			glog.Info(context.Background(), "canary override dropped", glog.String("route", key), glog.String("error", err.Error()))
+		}
+	}
+}
+
+// canaryGroup pick registry group by uid hash, sticky for the same uid of the same service
+func canaryGroup(backends []*Backend, registry string, md *metadata.Metadata) string {
+	total := 0
+	for _, b := range backends {
+		total += b.Weight
+	}
+	if total <= 0 {
+		return ""
+	}
+
+	key := md.Extension.RemoteIP
+	if md.UID > 0 {
+		key = strconv.FormatInt(md.UID, 10)
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(registry))
+	_, _ = h.Write([]byte(key))
+	bucket := int(h.Sum32()%canaryBuckets) * total / canaryBuckets
+
+	for _, b := range backends {
+		if bucket < b.Weight {
+			return b.Group
+		}
+		bucket -= b.Weight
+	}
+	return backends[len(backends)-1].Group
+}
+
+func checkBackends(mc *MethodConfig, backends []*Backend) error {
+	groups := make(map[string]struct{}, len(mc.Backends))
+	for _, b := range mc.Backends {
+		groups[b.Group] = struct{}{}
+	}
+
+	total := 0
+	for _, b := range backends {
+		if _, ok := groups[b.Group]; !ok {
+			return fmt.Errorf("group not configured: %s", b.Group)
+		}
+		if b.Weight < 0 {
+			return fmt.Errorf("invalid weight of group %s: %d", b.Group, b.Weight)
+		}
+		total += b.Weight
+	}
+	if total <= 0 {
+		return fmt.Errorf("total weight must be positive")
+	}
+	return nil
+}
+
+// SetCanaryWeights change weights of route at runtime, groups must be configured in backends
+func SetCanaryWeights(route string, backends []*Backend) error {
+	v, ok := canaryRoutes.Load(route)
+	if !ok {
+		return fmt.Errorf("canary route not found: %s", route)
+	}
+	if err := checkBackends(v.(*MethodConfig), backends); err != nil {
+		return err
+	}
+
+	canaryOverrides.Store(route, backends)
+	glog.Info(context.Background(), "canary weights changed", glog.String("route", route), glog.Any("backends", backends))
+	return nil
+}
+
+// ClearCanaryWeights restore configured weights of route
+func ClearCanaryWeights(route string) {
+	canaryOverrides.Delete(route)
+}
+
+// GetCanaryStates get weights of all canary routes
+func GetCanaryStates() []*CanaryState {
+	res := make([]*CanaryState, 0)
+	canaryRoutes.Range(func(key, value interface{}) bool {
+		st := &CanaryState{
+			Route:      key.(string),
+			Configured: value.(*MethodConfig).Backends,
+		}
+		if v, ok := canaryOverrides.Load(key); ok {
+			st.Override = v.([]*Backend)
+		}
+		res = append(res, st)
+		return true
+	})
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Route < res[j].Route
+	})
+	return res
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"bgw/pkg/common/types"
+	"bgw/pkg/server/metadata"
+)
+
+func TestCanaryGroup(t *testing.T) {
+	Convey("test canary group", t, func() {
+		backends := []*Backend{{Group: "A", Weight: 95}, {Group: "B", Weight: 5}}
+
+		md := metadata.MDFromContext(&types.Ctx{})
+		counts := map[string]int{}
+		for uid := int64(1); uid <= 10000; uid++ {
+			md.UID = uid
+			g := canaryGroup(backends, "reg", md)
+			So(g, ShouldEqual, canaryGroup(backends, "reg", md))
+			counts[g]++
+		}
+		So(counts["B"], ShouldBeBetween, 300, 700)
+
+		// users of canary keep sticky when weight is raised
+		raised := []*Backend{{Group: "A", Weight: 80}, {Group: "B", Weight: 20}}
+		for uid := int64(1); uid <= 1000; uid++ {
+			md.UID = uid
+			if canaryGroup(backends, "reg", md) == "B" {
+				So(canaryGroup(raised, "reg", md), ShouldEqual, "B")
+			}
+		}
+
+		So(canaryGroup([]*Backend{{Group: "A"}}, "reg", md), ShouldEqual, "")
+	})
+}
+
+func TestCanaryWeights(t *testing.T) {
+	Convey("test canary weights", t, func() {
+		mc := &MethodConfig{
+			Name:     "Hello",
+			Backends: []*Backend{{Group: "A", Weight: 100}, {Group: "B", Weight: 0}},
+			service: &ServiceConfig{
+				Registry: "reg",
+				Name:     "svc",
+				Group:    "A",
+				App:      &AppConfig{App: "app", Module: "mod"},
+			},
+		}
+		registerCanary(mc)
+		key := mc.canaryKey()
+		So(key, ShouldEqual, "app.mod.svc.reg.Hello")
+
+		So(SetCanaryWeights("xxx", mc.Backends), ShouldNotBeNil)
+		So(SetCanaryWeights(key, []*Backend{{Group: "C", Weight: 1}}), ShouldNotBeNil)
+		So(SetCanaryWeights(key, []*Backend{{Group: "A", Weight: -1}}), ShouldNotBeNil)
+		So(SetCanaryWeights(key, []*Backend{{Group: "A", Weight: 0}}), ShouldNotBeNil)
+
+		err := SetCanaryWeights(key, []*Backend{{Group: "A", Weight: 0}, {Group: "B", Weight: 100}})
+		So(err, ShouldBeNil)
+		So(mc.getBackends()[1].Weight, ShouldEqual, 100)
+
+		ctr := &controller{}
+		md := metadata.MDFromContext(&types.Ctx{})
+		md.UID = 1
+		So(ctr.getCanaryGroup(mc, "A", md), ShouldEqual, "B")
+		So(ctr.getCanaryGroup(mc, demoAccountGroup, md), ShouldEqual, demoAccountGroup)
+
+		states := GetCanaryStates()
+		So(len(states), ShouldEqual, 1)
+		So(states[0].Override, ShouldNotBeNil)
+
+		ClearCanaryWeights(key)
+		So(ctr.getCanaryGroup(mc, "A", md), ShouldEqual, "A")
+		So(mc.getBackends()[1].Weight, ShouldEqual, 0)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"bgw/pkg/common"
@@ -58,6 +59,7 @@ type ServiceConfig struct {
 	AllowWSS        bool     `json:"allow_wss,omitempty" xml:"allow_wss" yaml:"allow_wss,omitempty"`
 	LoadBalanceMeta string   `json:"loadBalanceMeta,omitempty" yaml:"loadBalanceMeta,omitempty"`
 	Category        string   `json:"category,omitempty" yaml:"category,omitempty"`
+	Priority        string   `json:"priority,omitempty" yaml:"priority,omitempty"`
 
 	registries map[string]*common.URL // group -> url
 	once       sync.Once
@@ -100,6 +102,21 @@ func (s *ServiceConfig) GetRegistry(group string) *common.URL {
 			common.WithNamespace(s.Namespace),
 		)
 		s.registries[demoAccountGroup] = durl
+
+		// weighted backends of methods
+		for _, m := range s.Methods {
+			for _, b := range m.Backends {
+				if _, ok := s.registries[b.Group]; ok {
+					continue
+				}
+				burl, _ := common.NewURL(s.Registry,
+					common.WithProtocol(constant.NacosProtocol),
+					common.WithGroup(b.Group),
+					common.WithNamespace(s.Namespace),
+				)
+				s.registries[b.Group] = burl
+			}
+		}
 	})
 
 	return s.registries[group]
@@ -150,6 +167,14 @@ type MethodConfig struct {
 	Category        string         `json:"category,omitempty" yaml:"category,omitempty"`
 	GroupRouteMode  string         `json:"groupRouteMode,omitempty" yaml:"groupRouteMode,omitempty"`
 	Breaker         bool           `json:"breaker,omitempty" yaml:"breaker,omitempty"`
+	Idempotent      bool           `json:"idempotent,omitempty" yaml:"idempotent,omitempty"`
+	Retry           *RetryPolicy   `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Backends        []*Backend     `json:"backends,omitempty" yaml:"backends,omitempty"`
+	Mirror          *MirrorPolicy  `json:"mirror,omitempty" yaml:"mirror,omitempty"`
+	Priority        string         `json:"priority,omitempty" yaml:"priority,omitempty"` // load shedding priority, trading,account,market,misc
+
+	streamType int64        // cached streaming type of grpc method, see invoker.streamType
+	filters    atomic.Value // map[string]filter.Filter, route filters of the latest route chain
 }
 
 type ACL struct {
@@ -306,6 +331,14 @@ func (m *MethodConfig) GetAllowWSS() bool {
 	return allowWSS
 }
 
+// GetRetryPolicy get retry policy, only idempotent method can be retried
+func (m *MethodConfig) GetRetryPolicy() *RetryPolicy {
+	if !m.Idempotent || m.Retry == nil || !m.Retry.enabled() {
+		return nil
+	}
+	return m.Retry
+}
+
 // GetCategory get category
 func (m *MethodConfig) GetCategory() string {
 	c := m.Service().Category
@@ -315,6 +348,26 @@ func (m *MethodConfig) GetCategory() string {
 	return c
 }
 
+// GetRouteFilter get the live route filter instance by name, which is created by the latest route chain,
+// nil if the route chain is not created or the filter is not on the route
+func (m *MethodConfig) GetRouteFilter(name string) filter.Filter {
+	filters, _ := m.filters.Load().(map[string]filter.Filter)
+	return filters[name]
+}
+
+// GetPriority get load shedding priority, default is empty which is never shed,
+// so a route is shed only after it's labeled explicitly
+func (m *MethodConfig) GetPriority() string {
+	p := m.Service().Priority
+	if m.Priority != "" {
+		p = m.Priority
+	}
+	if !metadata.IsValidPriority(p) {
+		return ""
+	}
+	return p
+}
+
 // RouteKey key for method, including service name, method name and method httpVerb
 // indicate primary route key
 func (m *MethodConfig) RouteKey() metadata.RouteKey {
@@ -329,6 +382,7 @@ func (m *MethodConfig) RouteKey() metadata.RouteKey {
 		Group:       m.Group,
 		ACL:         m.ACL,
 		Category:    m.GetCategory(),
+		Priority:    m.GetPriority(),
 		AppCfg:      m.Service().App.AppCfg,
 		AllApp:      m.Service().App.AppCfg.Mapping,
 	}
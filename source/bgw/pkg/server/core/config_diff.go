@@ -0,0 +1,175 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bgw/pkg/common/util"
+)
+
+// route diff actions
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// RouteDiff is the semantic change of one route between two config versions
+type RouteDiff struct {
+	Route  string   `json:"route"`
+	Action string   `json:"action"`
+	Fields []string `json:"fields,omitempty"` // changed fields, "name: old -> new"
+}
+
+// String format diff as one line per route and field
+func (d *RouteDiff) String() string {
+	var sign string
+	switch d.Action {
+	case DiffAdded:
+		sign = "+"
+	case DiffRemoved:
+		sign = "-"
+	default:
+		sign = "~"
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(sign + " " + d.Route)
+	for _, f := range d.Fields {
+		sb.WriteString("\n    " + f)
+	}
+	return sb.String()
+}
+
+// DiffAppConfigs compare the enabled routes of two config versions,
+// a route is identified by app key, http method, path and category,
+// inherited service settings are resolved so only effective changes are reported
+func DiffAppConfigs(from, to []*AppConfig) []*RouteDiff {
+	olds := flattenRoutes(from)
+	news := flattenRoutes(to)
+
+	keys := make([]string, 0, len(olds)+len(news))
+	for k := range olds {
+		keys = append(keys, k)
+	}
+	for k := range news {
+		if _, ok := olds[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	diffs := make([]*RouteDiff, 0)
+	for _, k := range keys {
+		o, ok1 := olds[k]
+		n, ok2 := news[k]
+		switch {
+		case !ok1:
+			diffs = append(diffs, &RouteDiff{Route: k, Action: DiffAdded})
+		case !ok2:
+			diffs = append(diffs, &RouteDiff{Route: k, Action: DiffRemoved})
+		default:
+			if fields := diffFields(o, n); len(fields) > 0 {
+				diffs = append(diffs, &RouteDiff{Route: k, Action: DiffChanged, Fields: fields})
+			}
+		}
+	}
+
+	return diffs
+}
+
+// flattenRoutes expand methods by http method and path, value is the effective settings of the route
+func flattenRoutes(apps []*AppConfig) map[string]map[string]string {
+	routes := make(map[string]map[string]string)
+	for _, ac := range apps {
+		for _, sc := range ac.Services {
+			for _, mc := range sc.Methods {
+				mc.SetService(sc)
+				if mc.Disable {
+					continue
+				}
+
+				fields := routeFields(mc)
+				for _, method := range mc.GetMethod() {
+					method = strings.TrimPrefix(method, "HTTP_METHOD_")
+					for _, path := range mc.GetPath() {
+						key := ac.Key() + " " + method + " " + path
+						if c := mc.GetCategory(); c != "" {
+							key += " category=" + c
+						}
+						routes[key] = fields
+					}
+				}
+			}
+		}
+	}
+	return routes
+}
+
+func routeFields(mc *MethodConfig) map[string]string {
+	sc := mc.Service()
+	fields := map[string]string{
+		"registry":        sc.Registry,
+		"protocol":        sc.Protocol,
+		"interface":       sc.Name,
+		"method":          mc.Name,
+		"timeout":         mc.GetTimeout().String(),
+		"selector":        mc.GetSelector(),
+		"selectorMeta":    mc.GetSelectorMeta(),
+		"loadBalanceMeta": mc.GetLBMeta(),
+		"allowWSS":        strconv.FormatBool(mc.GetAllowWSS()),
+		"breaker":         strconv.FormatBool(mc.Breaker),
+		"idempotent":      strconv.FormatBool(mc.Idempotent),
+		"priority":        mc.GetPriority(),
+	}
+	if mc.ACL.Group != "" || mc.ACL.Permission != "" || mc.ACL.AllGroup || len(mc.ACL.Groups) > 0 {
+		fields["acl"] = util.ToJSONString(mc.ACL)
+	}
+	if mc.Retry != nil {
+		fields["retry"] = util.ToJSONString(mc.Retry)
+	}
+	if len(mc.Backends) > 0 {
+		fields["backends"] = util.ToJSONString(mc.Backends)
+	}
+	if mc.Mirror != nil {
+		fields["mirror"] = util.ToJSONString(mc.Mirror)
+	}
+
+	names := make([]string, 0, 4)
+	for _, f := range mc.GetFilters() {
+		names = append(names, f.Name)
+		fields["filter "+f.Name] = strings.Join(f.GetArgs(), " ")
+	}
+	fields["filters"] = strings.Join(names, ",")
+	return fields
+}
+
+func diffFields(o, n map[string]string) []string {
+	names := make([]string, 0, len(o))
+	for k := range o {
+		names = append(names, k)
+	}
+	for k := range n {
+		if _, ok := o[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	fields := make([]string, 0)
+	for _, name := range names {
+		ov, ok1 := o[name]
+		nv, ok2 := n[name]
+		switch {
+		case !ok1:
+			fields = append(fields, fmt.Sprintf("%s: + %q", name, nv))
+		case !ok2:
+			fields = append(fields, fmt.Sprintf("%s: - %q", name, ov))
+		case ov != nv:
+			fields = append(fields, fmt.Sprintf("%s: %q -> %q", name, ov, nv))
+		}
+	}
+	return fields
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/server/filter"
+)
+
+func TestDiffAppConfigs(t *testing.T) {
+	Convey("test diff app configs", t, func() {
+		newApp := func(timeout int32, args string, methods ...*MethodConfig) *AppConfig {
+			return &AppConfig{
+				App:    "demo",
+				Module: "order-http",
+				Services: []*ServiceConfig{
+					{
+						Registry: "demo-http",
+						Protocol: constant.HttpProtocol,
+						Timeout:  timeout,
+						Filters:  []Filter{{Name: filter.AuthFilterKey, Args: args}},
+						Methods:  methods,
+					},
+				},
+			}
+		}
+
+		from := newApp(3, "--allowGuest",
+			&MethodConfig{Path: "/v5/order/list", HttpMethod: "HTTP_METHOD_GET"},
+			&MethodConfig{Path: "/v5/order/cancel", HttpMethod: "HTTP_METHOD_POST"},
+		)
+		to := newApp(3, "--allowGuest",
+			&MethodConfig{Path: "/v5/order/list", HttpMethod: "HTTP_METHOD_GET"},
+			&MethodConfig{Path: "/v5/order/create", HttpMethod: "HTTP_METHOD_POST"},
+			&MethodConfig{Path: "/v5/order/cancel", HttpMethod: "HTTP_METHOD_POST", Disable: true},
+		)
+		diffs := DiffAppConfigs([]*AppConfig{from}, []*AppConfig{to})
+		So(len(diffs), ShouldEqual, 2)
+		So(diffs[0].Route, ShouldEqual, "demo.order-http POST /v5/order/cancel")
+		So(diffs[0].Action, ShouldEqual, DiffRemoved)
+		So(diffs[1].Route, ShouldEqual, "demo.order-http POST /v5/order/create")
+		So(diffs[1].Action, ShouldEqual, DiffAdded)
+
+		// inherited service settings are compared as effective route settings
+		to = newApp(5, "", &MethodConfig{Path: "/v5/order/list", HttpMethod: "HTTP_METHOD_GET"})
+		from = newApp(3, "--allowGuest", &MethodConfig{Path: "/v5/order/list", HttpMethod: "HTTP_METHOD_GET"})
+		diffs = DiffAppConfigs([]*AppConfig{from}, []*AppConfig{to})
+		So(len(diffs), ShouldEqual, 1)
+		So(diffs[0].Action, ShouldEqual, DiffChanged)
+		So(diffs[0].Fields, ShouldResemble, []string{
+			`filter FILTER_AUTH: "--allowGuest" -> ""`,
+			`timeout: "3s" -> "5s"`,
+		})
+
+		So(DiffAppConfigs([]*AppConfig{from}, []*AppConfig{from}), ShouldBeEmpty)
+	})
+}
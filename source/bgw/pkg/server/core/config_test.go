@@ -9,6 +9,9 @@ import (
 	"github.com/tj/assert"
 
 	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/metadata"
 )
 
 // func TestToURL(t *testing.T) {
@@ -167,8 +170,15 @@ func TestMethodConfig_GetCategory(t *testing.T) {
 		c := mc.GetCategory()
 		So(c, ShouldEqual, "1")
 
+		So(mc.GetPriority(), ShouldEqual, "")
+		mc.Priority = metadata.PriorityTrading
+		So(mc.GetPriority(), ShouldEqual, metadata.PriorityTrading)
+		mc.Priority = "xxx"
+		So(mc.GetPriority(), ShouldEqual, "")
+
 		rk := mc.RouteKey()
 		So(rk, ShouldNotBeNil)
+		So(rk.Priority, ShouldEqual, "")
 
 		mc.Selector = "s"
 		s := mc.GetSelector()
@@ -183,3 +193,20 @@ func TestMethodConfig_GetCategory(t *testing.T) {
 		So(s, ShouldEqual, "s")
 	})
 }
+
+func TestMethodConfig_GetRouteFilter(t *testing.T) {
+	Convey("test MethodConfig GetRouteFilter", t, func() {
+		mc := &MethodConfig{}
+		So(mc.GetRouteFilter("f"), ShouldBeNil)
+
+		mc.filters.Store(map[string]filter.Filter{"f": &mockRouteFilter{}})
+		So(mc.GetRouteFilter("f"), ShouldNotBeNil)
+		So(mc.GetRouteFilter("g"), ShouldBeNil)
+	})
+}
+
+type mockRouteFilter struct{}
+
+func (m *mockRouteFilter) Do(next types.Handler) types.Handler { return next }
+
+func (m *mockRouteFilter) GetName() string { return "f" }
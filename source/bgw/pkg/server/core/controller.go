@@ -13,6 +13,7 @@ import (
 	"go.uber.org/atomic"
 
 	"bgw/pkg/common/berror"
+	"bgw/pkg/common/constant"
 	"bgw/pkg/common/types"
 	"bgw/pkg/common/util"
 	"bgw/pkg/discovery"
@@ -75,6 +76,7 @@ func NewContoller(ctx context.Context) Controller {
 	}
 
 	c.serviceRegistry.AddInsListener(breakerMgr.OnInstanceRemove)
+	c.serviceRegistry.AddInsListener(cluster.OnInstanceRemove)
 	c.routeManager = newRouteManager(c.getRouteChain)
 	return c
 }
@@ -186,6 +188,25 @@ func (c *controller) OnEvent(event observer.Event) (err error) {
 			galert.Error(c.ctx, logRegistryWatchErrMsg, galert.WithField("service", sc.Registry), galert.WithField("group", sc.Group), galert.WithField("err", err))
 		}
 
+		watched := map[string]struct{}{sc.Group: {}}
+		for _, method := range sc.Methods {
+			if mp := method.GetMirror(); mp != nil {
+				if err = c.serviceRegistry.Watch(c.ctx, mp.getRegistry(sc)); err != nil {
+					glog.Error(c.ctx, logRegistryWatchErrMsg, glog.String("service", mp.Registry), glog.String("group", mp.Group), glog.NamedError("err", err))
+				}
+			}
+			for _, b := range method.Backends {
+				if _, ok := watched[b.Group]; ok {
+					continue
+				}
+				watched[b.Group] = struct{}{}
+				if err = c.serviceRegistry.Watch(c.ctx, sc.GetRegistry(b.Group)); err != nil {
+					glog.Error(c.ctx, logRegistryWatchErrMsg, glog.String("service", sc.Registry), glog.String("group", b.Group), glog.NamedError("err", err))
+					galert.Error(c.ctx, logRegistryWatchErrMsg, galert.WithField("service", sc.Registry), galert.WithField("group", b.Group), galert.WithField("err", err))
+				}
+			}
+		}
+
 		for _, method := range sc.Methods {
 			if method.GroupRouteMode != defaultOnly && method.GroupRouteMode != allToDefault {
 				if err = c.serviceRegistry.Watch(c.ctx, sc.GetRegistry(demoAccountGroup)); err != nil {
@@ -223,8 +244,14 @@ func (c *controller) getRouteChain(mc *MethodConfig) (types.Handler, error) {
 		return nil, err
 	}
 
+	// add load shedder, it needs route priority so can't be in global chain
+	if f, err := filter.GetFilter(c.ctx, filter.LoadShedderFilterKey); err == nil {
+		chain.Append(f)
+	}
+
 	// construct filter chain of handler
 	filters := mc.GetFilters()
+	routeFilters := make(map[string]filter.Filter, len(filters))
 	// glog.Info(c.ctx, "filter list", glog.String("route", mc.RouteKey().String()), glog.String("path", mc.Path), glog.Any("filters", filters))
 	for _, ff := range filters {
 		// add route key as first arg
@@ -234,6 +261,7 @@ func (c *controller) getRouteChain(mc *MethodConfig) (types.Handler, error) {
 			return nil, fmt.Errorf("GetFilter error: %s -> %s -> %w", ff.Name, ff.Args, err)
 		}
 		chain.Append(f)
+		routeFilters[ff.Name] = f
 	}
 
 	// construct upstream invoker
@@ -242,6 +270,8 @@ func (c *controller) getRouteChain(mc *MethodConfig) (types.Handler, error) {
 		return nil, fmt.Errorf("getInvoker error: %w", err)
 	}
 
+	// keep the live filters for admin explain, which must not create and init filters again
+	mc.filters.Store(routeFilters)
 	return chain.Finally(invoke), nil
 }
 
@@ -279,6 +309,8 @@ func (c *controller) getInvoker(mc *MethodConfig) (types.Handler, error) {
 		st.SetDiscovery(c.serviceRegistry.GetInstances)
 	}
 
+	registerCanary(mc)
+
 	isAllInOneTrading := tradingroute.IsRoutingService(mc.Service().Registry)
 	return func(ctx *types.Ctx) error {
 		md := metadata.MDFromContext(ctx)
@@ -299,13 +331,15 @@ func (c *controller) getInvoker(mc *MethodConfig) (types.Handler, error) {
 		}
 
 		// select instance from registry
-		group := c.getGroup(mc, md.IsDemoUID)
+		group := c.getCanaryGroup(mc, c.getGroup(mc, md.IsDemoUID), md)
 		md.InvokeNamespace = mc.Service().Namespace
 		md.InvokeGroup = group
 		server := mc.Service().GetRegistry(group)
 		instances := c.serviceRegistry.GetInstances(server)
 
-		instance, err := selector.Select(ctx, cluster.SwimLaneSelector(ctx, instances))
+		instances = cluster.SwimLaneSelector(ctx, instances)
+		instances = cluster.EjectOutliers(instances, mc.Service().Protocol)
+		instance, err := selector.Select(ctx, instances)
 		if instance == nil || err != nil {
 			err = fmt.Errorf("instance not found: %w", err)
 			glog.Error(ctx, "instance not found", glog.Any("registry", server), glog.String("group", group),
@@ -319,11 +353,38 @@ func (c *controller) getInvoker(mc *MethodConfig) (types.Handler, error) {
 		md.InvokeAddr = instance.GetAddress(mc.Service().Protocol)
 		md.WithContext(ctx)
 
+		if mc.GetRetryPolicy() != nil {
+			ctx.SetUserValue(constant.CtxInvokePicker, c.addrPicker(ctx, selector, instances, mc.Service().Protocol))
+		}
+
 		// invoke upstream
-		return c.invoker.invoke(ctx, mc, md)
+		err = c.invoker.invoke(ctx, mc, md)
+		c.mirror(ctx, mc, md, err)
+		return err
 	}, nil
 }
 
+// addrPicker select another instance for retry and hedge
+func (c *controller) addrPicker(ctx *types.Ctx, selector cluster.Selector, instances []registry.ServiceInstance, protocol string) addrPicker {
+	return func(used map[string]struct{}) string {
+		candidates := make([]registry.ServiceInstance, 0, len(instances))
+		for _, ins := range instances {
+			if _, ok := used[ins.GetAddress(protocol)]; !ok {
+				candidates = append(candidates, ins)
+			}
+		}
+		if len(candidates) == 0 {
+			return ""
+		}
+
+		instance, err := selector.Select(ctx, candidates)
+		if instance == nil || err != nil {
+			return ""
+		}
+		return instance.GetAddress(protocol)
+	}
+}
+
 func (c *controller) getGroup(mc *MethodConfig, isDemoUID bool) string {
 	group := mc.Service().Group
 	switch mc.GroupRouteMode {
@@ -339,6 +400,19 @@ func (c *controller) getGroup(mc *MethodConfig, isDemoUID bool) string {
 	return group
 }
 
+// getCanaryGroup split traffic of default group to weighted backends
+func (c *controller) getCanaryGroup(mc *MethodConfig, group string, md *metadata.Metadata) string {
+	if group != mc.Service().Group {
+		return group
+	}
+	if backends := mc.getBackends(); len(backends) > 0 {
+		if g := canaryGroup(backends, mc.Service().Registry, md); g != "" {
+			return g
+		}
+	}
+	return group
+}
+
 func (c *controller) tradingInvoke(ctx *types.Ctx, router tradingroute.Routing, mc *MethodConfig, md *metadata.Metadata) error {
 	req := &tradingroute.GetRouteRequest{
 		UserId: md.UID,
@@ -8,6 +8,7 @@ import (
 	// nolint
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/valyala/bytebufferpool"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/runtime/protoiface"
 )
@@ -20,6 +21,7 @@ var (
 // RPCResult is default RPC result.
 type RPCResult struct {
 	status  int
+	code    codes.Code
 	message protoiface.MessageV1
 	data    *bytebufferpool.ByteBuffer
 	md      metadata.MD
@@ -43,6 +45,19 @@ func (r *RPCResult) GetStatus() int {
 	return r.status
 }
 
+// SetGrpcCode set grpc status code.
+func (r *RPCResult) SetGrpcCode(code codes.Code) {
+	r.code = code
+}
+
+// GetGrpcCode get grpc status code.
+func (r *RPCResult) GetGrpcCode() codes.Code {
+	if r == nil {
+		return codes.OK
+	}
+	return r.code
+}
+
 // Metadata gets invoker metadata.
 func (r *RPCResult) Metadata() metadata.MD {
 	if r == nil {
@@ -98,6 +113,7 @@ func (r *RPCResult) Close() error {
 	}
 
 	r.status = 0
+	r.code = codes.OK
 	r.message = nil
 	return nil
 }
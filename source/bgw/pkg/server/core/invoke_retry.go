@@ -0,0 +1,262 @@
+package core
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/gcore/backoff"
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"code.bydev.io/fbu/gateway/gway.git/gmetric"
+	"google.golang.org/grpc/codes"
+
+	"bgw/pkg/common/berror"
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	gmetadata "bgw/pkg/server/metadata"
+)
+
+const (
+	retryMetricType  = "invoke_retry"
+	maxRetryAttempts = 5 // upper bound of MaxAttempts, protects upstream from retry storm
+)
+
+var (
+	defaultRetryGrpcCodes    = []codes.Code{codes.Unavailable}
+	defaultRetryHttpStatuses = []int{502, 503}
+)
+
+// RetryPolicy retry and hedging policy of upstream invoke, only works with idempotent method
+type RetryPolicy struct {
+	MaxAttempts   int      `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"`     // total attempts, including the first one, at most 5
+	GrpcCodes     []string `json:"grpcCodes,omitempty" yaml:"grpcCodes,omitempty"`         // retryable grpc codes, default UNAVAILABLE
+	HttpStatuses  []int    `json:"httpStatuses,omitempty" yaml:"httpStatuses,omitempty"`   // retryable http statuses, default 502,503
+	BackoffMin    int64    `json:"backoffMin,omitempty" yaml:"backoffMin,omitempty"`       // ms
+	BackoffMax    int64    `json:"backoffMax,omitempty" yaml:"backoffMax,omitempty"`       // ms
+	BackoffFactor float64  `json:"backoffFactor,omitempty" yaml:"backoffFactor,omitempty"` //
+	Jitter        bool     `json:"jitter,omitempty" yaml:"jitter,omitempty"`               //
+	HedgeDelay    int64    `json:"hedgeDelay,omitempty" yaml:"hedgeDelay,omitempty"`       // ms, fire a hedged request to another instance after delay, 0 is disabled
+
+	once     sync.Once
+	codes    map[codes.Code]struct{}
+	statuses map[int]struct{}
+}
+
+func (p *RetryPolicy) enabled() bool {
+	return p.MaxAttempts > 1 || p.HedgeDelay > 0
+}
+
+func (p *RetryPolicy) init() {
+	p.once.Do(func() {
+		p.codes = make(map[codes.Code]struct{})
+		for _, s := range p.GrpcCodes {
+			var c codes.Code
+			if err := c.UnmarshalJSON([]byte(strconv.Quote(s))); err != nil {
+				glog.Info(context.Background(), "invalid retry grpc code", glog.String("code", s))
+				continue
+			}
+			p.codes[c] = struct{}{}
+		}
+		if len(p.codes) == 0 {
+			for _, c := range defaultRetryGrpcCodes {
+				p.codes[c] = struct{}{}
+			}
+		}
+
+		p.statuses = make(map[int]struct{})
+		statuses := p.HttpStatuses
+		if len(statuses) == 0 {
+			statuses = defaultRetryHttpStatuses
+		}
+		for _, s := range statuses {
+			p.statuses[s] = struct{}{}
+		}
+	})
+}
+
+func (p *RetryPolicy) attempts() int {
+	switch {
+	case p.MaxAttempts < 1:
+		return 1
+	case p.MaxAttempts > maxRetryAttempts:
+		return maxRetryAttempts
+	default:
+		return p.MaxAttempts
+	}
+}
+
+func (p *RetryPolicy) newBackoff() backoff.BackOff {
+	return backoff.NewExponential(
+		backoff.WithMin(time.Duration(p.BackoffMin)*time.Millisecond),
+		backoff.WithMax(time.Duration(p.BackoffMax)*time.Millisecond),
+		backoff.WithFactor(p.BackoffFactor),
+		backoff.WithJitter(p.Jitter),
+	)
+}
+
+// retryable check if the attempt can be retried
+func (p *RetryPolicy) retryable(a *attempt) bool {
+	if a.err == nil {
+		_, ok := p.statuses[a.call.result.GetStatus()]
+		return ok
+	}
+
+	switch berror.GetErrCode(a.err) {
+	case berror.UpstreamErrInstanceConnFailed:
+		// request is not sent
+		return true
+	case berror.UpstreamErrInvokerBreaker, berror.UpstreamErrInvokerFailed:
+		if gc, ok := a.call.result.(grpcCoder); ok {
+			_, ok = p.codes[gc.GetGrpcCode()]
+			return ok
+		}
+		// http transport error
+		return true
+	}
+
+	return false
+}
+
+// addrPicker pick another upstream address for retry and hedge, used addresses are excluded
+type addrPicker func(used map[string]struct{}) string
+
+type grpcCoder interface {
+	GetGrpcCode() codes.Code
+}
+
+// invokeCall is the invoker, request and result of one upstream call
+type invokeCall struct {
+	invoke  Invoker
+	request Request
+	result  Result
+}
+
+type callFactory func() *invokeCall
+
+// attempt is the outcome of one upstream call
+type attempt struct {
+	addr string
+	call *invokeCall
+	err  error
+}
+
+// close release the pooled result of discarded attempt
+func (a *attempt) close() {
+	if a == nil || a.call == nil || a.call.result == nil {
+		return
+	}
+	_ = a.call.result.Close()
+}
+
+// invokeWithPolicy invoke upstream with retry and hedge, the winner address is set into md.InvokeAddr
+func (iv *invoker) invokeWithPolicy(ctx *types.Ctx, tc context.Context, policy *RetryPolicy, md *gmetadata.Metadata, first *invokeCall, factory callFactory) (*invokeCall, error) {
+	policy.init()
+
+	picker, _ := ctx.UserValue(constant.CtxInvokePicker).(addrPicker)
+	used := map[string]struct{}{md.InvokeAddr: {}}
+	pick := func() string {
+		if picker == nil {
+			return ""
+		}
+		addr := picker(used)
+		if addr != "" {
+			used[addr] = struct{}{}
+		}
+		return addr
+	}
+
+	var (
+		bo   = policy.newBackoff()
+		addr = md.InvokeAddr
+		call = first
+		res  *attempt
+	)
+
+	for i := 0; i < policy.attempts(); i++ {
+		if i > 0 {
+			d := bo.Next()
+			if d == backoff.Stop {
+				break
+			}
+			timer := time.NewTimer(d)
+			select {
+			case <-tc.Done():
+				timer.Stop()
+				return res.call, res.err
+			case <-timer.C:
+			}
+
+			// retry on another instance if possible, otherwise the same one
+			if next := pick(); next != "" {
+				addr = next
+			}
+			res.close()
+			call = factory()
+			gmetric.IncDefaultCounter(retryMetricType, "retry")
+		}
+
+		res = iv.hedgedInvoke(tc, policy, addr, call, factory, pick)
+		md.InvokeAddr = res.addr
+		if !policy.retryable(res) || tc.Err() != nil {
+			break
+		}
+		glog.Debug(ctx, "invoke attempt failed", glog.Int64("attempt", int64(i+1)), glog.String("addr", res.addr))
+	}
+
+	return res.call, res.err
+}
+
+// hedgedInvoke invoke upstream, fire a hedged call to another instance if no response after hedge delay,
+// the first not retryable outcome wins, the other one is canceled
+func (iv *invoker) hedgedInvoke(tc context.Context, policy *RetryPolicy, addr string, call *invokeCall, factory callFactory, pick func() string) *attempt {
+	if policy.HedgeDelay <= 0 {
+		return &attempt{addr: addr, call: call, err: call.invoke(tc, addr, call.request, call.result)}
+	}
+
+	hc, cancel := context.WithCancel(tc)
+	ch := make(chan *attempt, 2)
+	pending := 0
+	fire := func(addr string, call *invokeCall) {
+		pending++
+		go func() {
+			ch <- &attempt{addr: addr, call: call, err: call.invoke(hc, addr, call.request, call.result)}
+		}()
+	}
+
+	// !NOTE: wait for the canceled call, request reads from fasthttp ctx which is reused after return
+	defer func() {
+		cancel()
+		for ; pending > 0; pending-- {
+			(<-ch).close()
+		}
+	}()
+
+	fire(addr, call)
+	timer := time.NewTimer(time.Duration(policy.HedgeDelay) * time.Millisecond)
+	defer timer.Stop()
+
+	var last *attempt
+	for pending > 0 {
+		select {
+		case a := <-ch:
+			pending--
+			if !policy.retryable(a) {
+				if a.addr != addr {
+					gmetric.IncDefaultCounter(retryMetricType, "hedge_win")
+				}
+				last.close()
+				return a
+			}
+			last.close()
+			last = a
+		case <-timer.C:
+			if next := pick(); next != "" {
+				gmetric.IncDefaultCounter(retryMetricType, "hedge")
+				fire(next, factory())
+			}
+		}
+	}
+
+	return last
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc/codes"
+
+	"bgw/pkg/common/berror"
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/server/core/grpc"
+	"bgw/pkg/server/core/http"
+	"bgw/pkg/server/metadata"
+)
+
+func TestMethodConfig_GetRetryPolicy(t *testing.T) {
+	Convey("test get retry policy", t, func() {
+		mc := &MethodConfig{}
+		So(mc.GetRetryPolicy(), ShouldBeNil)
+
+		mc.Retry = &RetryPolicy{MaxAttempts: 3}
+		So(mc.GetRetryPolicy(), ShouldBeNil)
+
+		mc.Idempotent = true
+		So(mc.GetRetryPolicy(), ShouldNotBeNil)
+
+		mc.Retry = &RetryPolicy{MaxAttempts: 1}
+		So(mc.GetRetryPolicy(), ShouldBeNil)
+
+		mc.Retry = &RetryPolicy{HedgeDelay: 10}
+		So(mc.GetRetryPolicy(), ShouldNotBeNil)
+
+		So((&RetryPolicy{}).attempts(), ShouldEqual, 1)
+		So((&RetryPolicy{MaxAttempts: 100}).attempts(), ShouldEqual, maxRetryAttempts)
+	})
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	Convey("test retry policy retryable", t, func() {
+		p := &RetryPolicy{GrpcCodes: []string{"UNAVAILABLE", "DEADLINE_EXCEEDED", "xxx"}}
+		p.init()
+		So(len(p.codes), ShouldEqual, 2)
+		So(len(p.statuses), ShouldEqual, 2)
+
+		hr := http.NewResult()
+		So(p.retryable(&attempt{call: &invokeCall{result: hr}}), ShouldBeFalse)
+		So(p.retryable(&attempt{call: &invokeCall{result: hr}, err: berror.NewUpStreamErr(berror.UpstreamErrInstanceConnFailed)}), ShouldBeTrue)
+		So(p.retryable(&attempt{call: &invokeCall{result: hr}, err: berror.NewUpStreamErr(berror.UpstreamErrInvokerFailed)}), ShouldBeTrue)
+		So(p.retryable(&attempt{call: &invokeCall{result: hr}, err: berror.ErrTimeout}), ShouldBeFalse)
+
+		gr := grpc.NewResult()
+		gr.SetGrpcCode(codes.Unavailable)
+		So(p.retryable(&attempt{call: &invokeCall{result: gr}, err: berror.NewUpStreamErr(berror.UpstreamErrInvokerFailed)}), ShouldBeTrue)
+		gr.SetGrpcCode(codes.Internal)
+		So(p.retryable(&attempt{call: &invokeCall{result: gr}, err: berror.NewUpStreamErr(berror.UpstreamErrInvokerFailed)}), ShouldBeFalse)
+	})
+}
+
+func TestInvoker_InvokeWithPolicy(t *testing.T) {
+	Convey("test invoke with policy", t, func() {
+		iv := &invoker{}
+		ctx := &types.Ctx{}
+		md := metadata.MDFromContext(ctx)
+		md.InvokeAddr = "a"
+
+		var (
+			mu     sync.Mutex
+			addrs  []string
+			closed int
+		)
+		factory := func(fail map[string]bool, delay time.Duration) callFactory {
+			return func() *invokeCall {
+				return &invokeCall{
+					invoke: func(c context.Context, addr string, request Request, result Result) error {
+						mu.Lock()
+						addrs = append(addrs, addr)
+						mu.Unlock()
+						if fail[addr] {
+							return berror.NewUpStreamErr(berror.UpstreamErrInstanceConnFailed)
+						}
+						select {
+						case <-time.After(delay):
+						case <-c.Done():
+							return c.Err()
+						}
+						return nil
+					},
+					result: &closeCounter{Result: http.NewResult(), closed: &closed, mu: &mu},
+				}
+			}
+		}
+		var picker addrPicker = func(used map[string]struct{}) string {
+			for _, addr := range []string{"a", "b", "c"} {
+				if _, ok := used[addr]; !ok {
+					return addr
+				}
+			}
+			return ""
+		}
+		ctx.SetUserValue(constant.CtxInvokePicker, picker)
+
+		// retry on another instance
+		f := factory(map[string]bool{"a": true, "b": true}, 0)
+		p := &RetryPolicy{MaxAttempts: 3, BackoffMin: 1, BackoffMax: 2}
+		_, err := iv.invokeWithPolicy(ctx, context.Background(), p, md, f(), f)
+		So(err, ShouldBeNil)
+		So(addrs, ShouldResemble, []string{"a", "b", "c"})
+		So(md.InvokeAddr, ShouldEqual, "c")
+		So(closed, ShouldEqual, 2)
+
+		// attempts exhausted
+		addrs = nil
+		md.InvokeAddr = "a"
+		f = factory(map[string]bool{"a": true, "b": true, "c": true}, 0)
+		p = &RetryPolicy{MaxAttempts: 2, BackoffMin: 1, BackoffMax: 2}
+		_, err = iv.invokeWithPolicy(ctx, context.Background(), p, md, f(), f)
+		So(err, ShouldNotBeNil)
+		So(len(addrs), ShouldEqual, 2)
+
+		// hedged call wins, the canceled one is closed
+		addrs = nil
+		closed = 0
+		md.InvokeAddr = "a"
+		slow := factory(nil, time.Second)
+		fast := factory(nil, 0)
+		p = &RetryPolicy{HedgeDelay: 10}
+		_, err = iv.invokeWithPolicy(ctx, context.Background(), p, md, slow(), fast)
+		So(err, ShouldBeNil)
+		So(md.InvokeAddr, ShouldEqual, "b")
+		So(len(addrs), ShouldEqual, 2)
+		So(closed, ShouldEqual, 1)
+	})
+}
+
+// closeCounter count Close of discarded results
+type closeCounter struct {
+	Result
+	mu     *sync.Mutex
+	closed *int
+}
+
+func (c *closeCounter) Close() error {
+	c.mu.Lock()
+	*c.closed++
+	c.mu.Unlock()
+	return c.Result.Close()
+}
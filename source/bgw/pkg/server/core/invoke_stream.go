@@ -0,0 +1,302 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/generic"
+	"code.bydev.io/fbu/gateway/gway.git/ggrpc/pool"
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"code.bydev.io/fbu/gateway/gway.git/gmetric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"bgw/pkg/common/berror"
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/common/util"
+	"bgw/pkg/config"
+	pgrpc "bgw/pkg/server/core/grpc"
+	gmetadata "bgw/pkg/server/metadata"
+)
+
+const (
+	streamMetricType = "stream"
+
+	contentTypeEventStream = "text/event-stream"
+)
+
+// BidiStream bidi streaming call of upstream, messages are json
+type BidiStream interface {
+	// Send send json payload to upstream, not safe to call concurrently
+	Send(payload io.Reader) error
+	// CloseSend half-close, upstream is notified that no more message will be sent
+	CloseSend() error
+	// Recv receive next message as json into w, io.EOF is returned when upstream finished
+	Recv(w io.Writer) error
+	// Close cancel the call and release the connection, must be called when done
+	Close()
+}
+
+// BidiHandler serve bidi stream of upstream, provided by websocket server via ctx user value constant.CtxBidiHandler.
+// Handler takes over the stream and must not block, the stream outlives the request ctx.
+type BidiHandler func(stream BidiStream) error
+
+// StreamError error event sent to client when stream is broken
+type StreamError struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewStreamError convert error of stream into error event, business code of upstream is kept
+func NewStreamError(err error) *StreamError {
+	if stat, ok := status.FromError(err); ok && stat.Code() > codes.Unauthenticated {
+		return &StreamError{Code: int64(stat.Code()), Message: stat.Message()}
+	}
+	e := streamErr("", err)
+	return &StreamError{Code: berror.GetErrCode(e), Message: e.Error()}
+}
+
+// streamErr map error of opening stream to bgw error
+func streamErr(addr string, err error) error {
+	if errors.Is(err, generic.ErrReqUnmarshalFailed) {
+		return berror.ErrInvalidRequest
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return berror.ErrTimeout
+	}
+	if stat, ok := status.FromError(err); ok {
+		switch stat.Code() {
+		case codes.DeadlineExceeded:
+			return berror.ErrTimeout
+		case codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unimplemented, codes.ResourceExhausted:
+			return berror.NewUpStreamErr(berror.UpstreamErrInvokerBreaker, addr, stat.Message())
+		}
+	}
+	return berror.NewUpStreamErr(berror.UpstreamErrInvokerFailed, addr, err.Error())
+}
+
+// openStreams streams opened by the pod, limited by config.Stream.MaxStreams
+var openStreams atomic.Int64
+
+// upstreamStream connection and cancel of a streaming call, released only once
+type upstreamStream struct {
+	conn     io.Closer
+	cancel   context.CancelFunc
+	lifetime *time.Timer
+	once     sync.Once
+}
+
+func (u *upstreamStream) release() {
+	u.once.Do(func() {
+		if u.lifetime != nil {
+			u.lifetime.Stop()
+		}
+		u.cancel()
+		_ = u.conn.Close()
+		openStreams.Add(-1)
+		gmetric.IncDefaultCounter(streamMetricType, "close")
+	})
+}
+
+// acquireStream take a slot of open streams, false if the pod is full
+func acquireStream() bool {
+	limit := int64(config.Global.Stream.MaxStreams)
+	if n := openStreams.Add(1); limit > 0 && n > limit {
+		openStreams.Add(-1)
+		gmetric.IncDefaultError(streamMetricType, "full")
+		return false
+	}
+	return true
+}
+
+type bidiStream struct {
+	*generic.BidiStream
+	*upstreamStream
+}
+
+// Close cancel the call and release the connection
+func (b *bidiStream) Close() {
+	b.release()
+}
+
+// streamType get streaming type of grpc route, unary if descriptor is not found and unary invoke reports the error.
+// type is cached in route with the descriptor generation, lookup is only done after descriptors updated.
+func (iv *invoker) streamType(route *MethodConfig) generic.MethodType {
+	if route.Service().Protocol == constant.HttpProtocol || iv.grpcEngine == nil {
+		return generic.MethodUnary
+	}
+
+	gen := atomic.LoadInt64(&iv.descGen)
+	if v := atomic.LoadInt64(&route.streamType); v != 0 && v>>8 == gen {
+		return generic.MethodType(v&0xff - 1)
+	}
+
+	mt, err := iv.grpcEngine.GetMethodType(route.Service().Key(), route.Service().GetFullQulifiedName(), route.Name)
+	if err != nil {
+		// not cached, descriptor may be loaded later
+		return generic.MethodUnary
+	}
+	atomic.StoreInt64(&route.streamType, gen<<8|int64(mt+1))
+	return mt
+}
+
+// openStream dial upstream and open stream by open, the stream is not bound to request ctx,
+// route timeout only limits the opening.
+func (iv *invoker) openStream(ctx *types.Ctx, route *MethodConfig, md *gmetadata.Metadata,
+	open func(c context.Context, conn pool.Conn, request Request) error) (*upstreamStream, error) {
+	if !acquireStream() {
+		ctx.SetStatusCode(http.StatusTooManyRequests)
+		return nil, berror.ErrVisitsLimit
+	}
+	opened := false
+	defer func() {
+		if !opened {
+			openStreams.Add(-1)
+		}
+	}()
+
+	request := pgrpc.NewRPCRequest(ctx, route.Service().Key(), route.Service().GetFullQulifiedName(), route.Name)
+	sc, cancel := context.WithCancel(metadata.NewOutgoingContext(context.Background(), request.GetMetadata().Copy()))
+
+	timer := time.AfterFunc(route.GetTimeout(), cancel)
+	defer timer.Stop()
+
+	conn, err := pool.GetConn(sc, md.InvokeAddr)
+	if err != nil {
+		cancel()
+		return nil, berror.NewUpStreamErr(berror.UpstreamErrInstanceConnFailed, "openStream error", md.InvokeAddr, err.Error())
+	}
+
+	if err = open(sc, conn, request); err != nil {
+		cancel()
+		_ = conn.Close()
+		if sc.Err() != nil {
+			return nil, berror.ErrTimeout
+		}
+		return nil, streamErr(md.InvokeAddr, err)
+	}
+
+	if !timer.Stop() {
+		cancel()
+		_ = conn.Close()
+		return nil, berror.ErrTimeout
+	}
+
+	opened = true
+	gmetric.IncDefaultCounter(streamMetricType, "open")
+	us := &upstreamStream{conn: conn, cancel: cancel}
+	if lifetime := time.Duration(config.Global.Stream.MaxLifetime) * time.Second; lifetime > 0 {
+		// Recv fails with canceled, client is notified by error event or close frame
+		us.lifetime = time.AfterFunc(lifetime, func() {
+			gmetric.IncDefaultCounter(streamMetricType, "expire")
+			us.release()
+		})
+	}
+	return us, nil
+}
+
+// invokeStream invoke streaming method, server stream is written to client as server-sent events,
+// bidi stream is handed to the BidiHandler of websocket server.
+func (iv *invoker) invokeStream(ctx *types.Ctx, route *MethodConfig, md *gmetadata.Metadata, mt generic.MethodType) (err error) {
+	md.ReqTime = time.Now()
+	defer func() {
+		md.ReqCost = time.Since(md.ReqTime)
+		if err != nil {
+			glog.Info(ctx, "invoke stream error", glog.String("error", err.Error()),
+				glog.String("addr", md.InvokeAddr), glog.String("type", mt.String()))
+		}
+	}()
+
+	switch mt {
+	case generic.MethodServerStream:
+		return iv.invokeServerStream(ctx, route, md)
+	case generic.MethodBidiStream:
+		return iv.invokeBidiStream(ctx, route, md)
+	default:
+		return berror.NewInterErr("streaming type not supported", mt.String())
+	}
+}
+
+func (iv *invoker) invokeServerStream(ctx *types.Ctx, route *MethodConfig, md *gmetadata.Metadata) error {
+	var stream *generic.ServerStream
+	us, err := iv.openStream(ctx, route, md, func(c context.Context, conn pool.Conn, request Request) (e error) {
+		stream, e = iv.grpcEngine.InvokeServerStream(c, conn.Client(), request)
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx.SetUserValue(constant.CtxInvokeStream, true)
+	ctx.Response.Header.SetContentType(contentTypeEventStream)
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.Response.Header.Set("X-Accel-Buffering", "no")
+
+	// body is written after handler returns, ctx must not be used in writer
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer us.release()
+		writeEvents(w, stream.Recv)
+	})
+	return nil
+}
+
+// writeEvents write messages as server-sent events until upstream finished or client gone,
+// an error event is sent if upstream is broken
+func writeEvents(w *bufio.Writer, recv func(w io.Writer) error) {
+	buf := &bytes.Buffer{}
+	for {
+		buf.Reset()
+		err := recv(buf)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			_, _ = w.WriteString("event: error\ndata: ")
+			_, _ = w.WriteString(util.ToJSONString(NewStreamError(err)))
+			_, _ = w.WriteString("\n\n")
+			_ = w.Flush()
+			return
+		}
+
+		_, _ = w.WriteString("data: ")
+		_, _ = w.Write(buf.Bytes())
+		_, _ = w.WriteString("\n\n")
+		if w.Flush() != nil {
+			// client gone
+			return
+		}
+	}
+}
+
+func (iv *invoker) invokeBidiStream(ctx *types.Ctx, route *MethodConfig, md *gmetadata.Metadata) error {
+	handler, ok := ctx.UserValue(constant.CtxBidiHandler).(BidiHandler)
+	if !ok || handler == nil {
+		return berror.NewBizErr(berror.ErrCodeInvalidRequest, "bidi streaming is only served over websocket")
+	}
+
+	var stream *generic.BidiStream
+	us, err := iv.openStream(ctx, route, md, func(c context.Context, conn pool.Conn, request Request) (e error) {
+		stream, e = iv.grpcEngine.InvokeBidiStream(c, conn.Client(), request)
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx.SetUserValue(constant.CtxInvokeStream, true)
+	bs := &bidiStream{BidiStream: stream, upstreamStream: us}
+	if err = handler(bs); err != nil {
+		bs.Close()
+		return err
+	}
+	return nil
+}
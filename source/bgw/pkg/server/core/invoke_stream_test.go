@@ -0,0 +1,105 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"code.bydev.io/fbu/gateway/gway.git/generic"
+	"github.com/agiledragon/gomonkey/v2"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"bgw/pkg/common/berror"
+	"bgw/pkg/config"
+)
+
+func TestNewStreamError(t *testing.T) {
+	Convey("test new stream error", t, func() {
+		e := NewStreamError(status.Error(codes.Code(110001), "order not exists"))
+		So(e.Code, ShouldEqual, 110001)
+		So(e.Message, ShouldEqual, "order not exists")
+
+		e = NewStreamError(status.Error(codes.DeadlineExceeded, "deadline"))
+		So(e.Code, ShouldEqual, berror.TimeoutErr)
+
+		e = NewStreamError(status.Error(codes.Unavailable, "unavailable"))
+		So(e.Code, ShouldEqual, berror.UpstreamErrInvokerBreaker)
+
+		e = NewStreamError(errors.New("xxx"))
+		So(e.Code, ShouldEqual, berror.UpstreamErrInvokerFailed)
+	})
+}
+
+func TestWriteEvents(t *testing.T) {
+	Convey("test write events", t, func() {
+		recv := func(msgs []string, last error) func(w io.Writer) error {
+			return func(w io.Writer) error {
+				if len(msgs) == 0 {
+					return last
+				}
+				_, _ = fmt.Fprint(w, msgs[0])
+				msgs = msgs[1:]
+				return nil
+			}
+		}
+
+		buf := &bytes.Buffer{}
+		w := bufio.NewWriter(buf)
+		writeEvents(w, recv([]string{`{"a":1}`, `{"a":2}`}, io.EOF))
+		So(buf.String(), ShouldEqual, "data: {\"a\":1}\n\ndata: {\"a\":2}\n\n")
+
+		buf.Reset()
+		writeEvents(w, recv([]string{`{"a":1}`}, status.Error(codes.Code(110001), "broken")))
+		So(buf.String(), ShouldEqual, "data: {\"a\":1}\n\nevent: error\ndata: {\"code\":110001,\"message\":\"broken\"}\n\n")
+	})
+}
+
+func TestStreamType(t *testing.T) {
+	Convey("test stream type cached by descriptor generation", t, func() {
+		iv := &invoker{grpcEngine: generic.NewEngine()}
+		mc := &MethodConfig{Name: "Watch", service: &ServiceConfig{Protocol: "grpc", App: &AppConfig{App: "app", Module: "module"}}}
+
+		calls := 0
+		mt := generic.MethodServerStream
+		p := gomonkey.ApplyMethod(reflect.TypeOf(iv.grpcEngine), "GetMethodType", func(_ *generic.Engine, namespace, service, method string) (generic.MethodType, error) {
+			calls++
+			return mt, nil
+		})
+		defer p.Reset()
+
+		So(iv.streamType(mc), ShouldEqual, generic.MethodServerStream)
+		So(iv.streamType(mc), ShouldEqual, generic.MethodServerStream)
+		So(calls, ShouldEqual, 1)
+
+		// descriptor updated
+		mt = generic.MethodUnary
+		iv.descGen++
+		So(iv.streamType(mc), ShouldEqual, generic.MethodUnary)
+		So(iv.streamType(mc), ShouldEqual, generic.MethodUnary)
+		So(calls, ShouldEqual, 2)
+	})
+}
+
+func TestAcquireStream(t *testing.T) {
+	Convey("test acquire stream", t, func() {
+		old := config.Global.Stream
+		defer func() { config.Global.Stream = old }()
+
+		config.Global.Stream.MaxStreams = 1
+		So(acquireStream(), ShouldBeTrue)
+		So(acquireStream(), ShouldBeFalse)
+
+		us := &upstreamStream{conn: io.NopCloser(nil), cancel: func() {}}
+		us.release()
+		us.release()
+		So(openStreams.Load(), ShouldEqual, 0)
+		So(acquireStream(), ShouldBeTrue)
+		openStreams.Add(-1)
+	})
+}
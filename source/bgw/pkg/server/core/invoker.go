@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"code.bydev.io/fbu/gateway/gway.git/galert"
@@ -23,6 +24,7 @@ import (
 	"code.bydev.io/fbu/gateway/gway.git/gs3"
 	"code.bydev.io/fbu/gateway/gway.git/gsechub"
 	"code.bydev.io/fbu/gateway/gway.git/gtrace"
+	zbreaker "code.bydev.io/frameworks/byone/core/breaker"
 	"github.com/opentracing/opentracing-go"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -32,6 +34,7 @@ import (
 	"bgw/pkg/common/constant"
 	"bgw/pkg/common/types"
 	"bgw/pkg/config"
+	"bgw/pkg/server/cluster"
 	pgrpc "bgw/pkg/server/core/grpc"
 	phttp "bgw/pkg/server/core/http"
 	gmetadata "bgw/pkg/server/metadata"
@@ -84,6 +87,9 @@ type invoker struct {
 	midwares []invokeMidware
 
 	invoke invokeFunc
+
+	// descGen generation of descriptors, increased when any descriptor is updated
+	descGen int64
 }
 
 // init s3 session, grpc engine and descriptor
@@ -244,6 +250,8 @@ func (iv *invoker) OnEvent(event observer.Event) (err error) {
 
 	// save local cache
 	if !cached {
+		// method types of descriptor may be changed
+		atomic.AddInt64(&iv.descGen, 1)
 		iv.entryCh <- &entry{
 			Key:  namespace,
 			Data: data,
@@ -265,37 +273,22 @@ func (iv *invoker) GetPriority() int {
 
 // getInvoker get invoker by protocol
 func (iv *invoker) baseInvoke(ctx *types.Ctx, route *MethodConfig, md *gmetadata.Metadata) (err error) {
-	var (
-		invoke  Invoker
-		request Request
-		result  Result
-	)
-
-	switch route.Service().Protocol {
-	case constant.HttpProtocol:
-		request = phttp.NewRequest(ctx)
-		invoke = iv.invokeHTTP
-		result = phttp.NewResult()
-	default:
-		request = pgrpc.NewRPCRequest(
-			ctx,
-			route.Service().Key(),
-			route.Service().GetFullQulifiedName(),
-			route.Name,
-		)
-		invoke = iv.invokeGRPC
-		result = pgrpc.NewResult()
+	if mt := iv.streamType(route); mt != generic.MethodUnary {
+		return iv.invokeStream(ctx, route, md, mt)
 	}
 
+	factory := iv.newCallFactory(ctx, route)
+	call := factory()
+
 	tc, cancel := context.WithTimeout(service.GetContext(ctx), route.GetTimeout())
 	defer cancel()
 
 	md.ReqTime = time.Now()
-	service.DynamicLog(ctx, "invoke request", glog.Any("request", request), glog.Duration("timeout", route.GetTimeout()))
+	service.DynamicLog(ctx, "invoke request", glog.Any("request", call.request), glog.Duration("timeout", route.GetTimeout()))
 
 	defer func() {
 		md.ReqCost = time.Since(md.ReqTime)
-		service.DynamicLog(ctx, "invoke response", glog.Any("response", result))
+		service.DynamicLog(ctx, "invoke response", glog.Any("response", call.result))
 		if err != nil {
 			fields := []glog.Field{glog.String("error", err.Error()),
 				glog.String("addr", md.InvokeAddr),
@@ -311,15 +304,89 @@ func (iv *invoker) baseInvoke(ctx *types.Ctx, route *MethodConfig, md *gmetadata
 		}
 	}()
 
-	if err = invoke(tc, md.InvokeAddr, request, result); err != nil {
+	if policy := route.GetRetryPolicy(); policy != nil {
+		call, err = iv.invokeWithPolicy(ctx, tc, policy, md, call, factory)
+	} else {
+		err = call.invoke(tc, md.InvokeAddr, call.request, call.result)
+	}
+	if err != nil {
 		return
 	}
 
 	// set invoke result into context
-	ctx.SetUserValue(constant.CtxInvokeResult, result)
+	ctx.SetUserValue(constant.CtxInvokeResult, call.result)
+	ctx.SetUserValue(constant.CtxInvokeReq, call.request)
 	return
 }
 
+// newCallFactory create invoker, request and result by protocol, each upstream attempt use a new one,
+// load and outcome of calls are reported to cluster
+func (iv *invoker) newCallFactory(ctx *types.Ctx, route *MethodConfig) callFactory {
+	return func() *invokeCall {
+		call := iv.newCall(ctx, route)
+		call.invoke = observe(call.invoke)
+		return call
+	}
+}
+
+// newCall create invoker, request and result by protocol, the call is not observed
+func (iv *invoker) newCall(ctx *types.Ctx, route *MethodConfig) *invokeCall {
+	switch route.Service().Protocol {
+	case constant.HttpProtocol:
+		return &invokeCall{
+			invoke:  iv.invokeHTTP,
+			request: phttp.NewRequest(ctx),
+			result:  phttp.NewResult(),
+		}
+	default:
+		return &invokeCall{
+			invoke: iv.invokeGRPC,
+			request: pgrpc.NewRPCRequest(
+				ctx,
+				route.Service().Key(),
+				route.Service().GetFullQulifiedName(),
+				route.Name,
+			),
+			result: pgrpc.NewResult(),
+		}
+	}
+}
+
+// observe report load and outcome of each upstream call to cluster
+func observe(invoke Invoker) Invoker {
+	return func(ctx context.Context, addr string, request Request, result Result) error {
+		start := time.Now()
+		cluster.InvokeStart(addr)
+		err := invoke(ctx, addr, request, result)
+
+		outcome := cluster.OutcomeSuccess
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			// canceled hedge call, outcome of instance is unknown
+			outcome = cluster.OutcomeCanceled
+		case outlierFailed(err, result):
+			outcome = cluster.OutcomeFailed
+		}
+		cluster.InvokeDone(addr, time.Since(start), outcome)
+		return err
+	}
+}
+
+// outlierFailed check if the outcome is caused by upstream instance
+func outlierFailed(err error, result Result) bool {
+	if err == nil {
+		return result.GetStatus() >= 500
+	}
+	if errors.Is(err, berror.ErrTimeout) {
+		return true
+	}
+	switch berror.GetErrCode(err) {
+	case berror.UpstreamErrInstanceConnFailed, berror.UpstreamErrInvokerFailed, berror.UpstreamErrInvokerBreaker:
+		return true
+	}
+	return false
+}
+
 // invokeGRPC invoke grpc service by grpc engine
 func (iv *invoker) invokeGRPC(c context.Context, addr string, request Request, result Result) (err error) {
 	conn, err := pool.GetConn(c, addr)
@@ -345,9 +412,20 @@ func (iv *invoker) invokeGRPC(c context.Context, addr string, request Request, r
 			return berror.ErrInvalidRequest
 		}
 
+		if errors.Is(err, zbreaker.ErrServiceUnavailable) {
+			// breaker of grpc client is open, request is not sent
+			if gc, ok := result.(grpcCodeSetter); ok {
+				gc.SetGrpcCode(codes.Unavailable)
+			}
+			return berror.NewUpStreamErr(berror.UpstreamErrInvokerBreaker, addr, err.Error())
+		}
+
 		stat, _ := status.FromError(err)
 		if stat != nil {
 			code := stat.Code()
+			if gc, ok := result.(grpcCodeSetter); ok {
+				gc.SetGrpcCode(code)
+			}
 			if code > codes.Unauthenticated {
 				gmd.Set(constant.BgwAPIResponseCodes, cast.Int64toa(int64(stat.Code())))
 				gmd.Set(constant.BgwAPIResponseMessages, stat.Message())
@@ -366,6 +444,10 @@ func (iv *invoker) invokeGRPC(c context.Context, addr string, request Request, r
 	return
 }
 
+type grpcCodeSetter interface {
+	SetGrpcCode(codes.Code)
+}
+
 // invokeHTTP invoke http request by http client
 func (iv *invoker) invokeHTTP(ctx context.Context, addr string, request Request, result Result) (err error) {
 	span, _ := gtrace.Begin(
@@ -0,0 +1,349 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/gcore/filesystem"
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"code.bydev.io/fbu/gateway/gway.git/gmetric"
+	// nolint
+	"github.com/golang/protobuf/jsonpb"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/runtime/protoiface"
+
+	"bgw/pkg/common"
+	"bgw/pkg/common/berror"
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/common/util"
+	"bgw/pkg/config"
+	"bgw/pkg/server/cluster"
+	gmetadata "bgw/pkg/server/metadata"
+)
+
+const (
+	mirrorMetricType = "mirror"
+	// mirrorConcurrency max in-flight shadow calls, exceeded ones are dropped
+	mirrorConcurrency = 256
+)
+
+var (
+	mirrorOnce   sync.Once
+	mirrorLogger glog.Logger
+	mirrorSem    = make(chan struct{}, mirrorConcurrency)
+	// nolint
+	mirrorMarshaler = jsonpb.Marshaler{EmitDefaults: true}
+)
+
+// MirrorPolicy shadow traffic of method, a sample of requests is duplicated to the shadow registry asynchronously,
+// shadow responses are discarded, only the comparison with primary response is recorded to mirror log
+type MirrorPolicy struct {
+	Registry string  `json:"registry" yaml:"registry"`                 // shadow registry
+	Group    string  `json:"group,omitempty" yaml:"group,omitempty"`   // default is the group of service
+	Sample   float64 `json:"sample,omitempty" yaml:"sample,omitempty"` // percent of requests, 0~100
+	Timeout  int64   `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	once sync.Once
+	url  *common.URL
+}
+
+// GetMirror get mirror policy
+func (m *MethodConfig) GetMirror() *MirrorPolicy {
+	if m.Mirror == nil || m.Mirror.Registry == "" || m.Mirror.Sample <= 0 {
+		return nil
+	}
+	return m.Mirror
+}
+
+// getRegistry get shadow registry, namespace is the same as service
+func (p *MirrorPolicy) getRegistry(s *ServiceConfig) *common.URL {
+	p.once.Do(func() {
+		group := p.Group
+		if group == "" {
+			group = s.Group
+		}
+		p.url, _ = common.NewURL(p.Registry,
+			common.WithProtocol(constant.NacosProtocol),
+			common.WithGroup(group),
+			common.WithNamespace(s.Namespace),
+		)
+	})
+	return p.url
+}
+
+func (p *MirrorPolicy) sampled() bool {
+	return p.Sample >= 100 || rand.Float64()*100 < p.Sample
+}
+
+func (p *MirrorPolicy) timeout(m *MethodConfig) time.Duration {
+	if p.Timeout > 0 {
+		return time.Duration(p.Timeout) * time.Millisecond
+	}
+	return m.GetTimeout()
+}
+
+func getMirrorLogger() glog.Logger {
+	mirrorOnce.Do(func() {
+		lc := &glog.Config{
+			File:          "data/logs/bgw/mirror.log",
+			Type:          "lumberjack",
+			MaxSize:       100,
+			MaxBackups:    3,
+			MaxAge:        7,
+			Level:         glog.InfoLevel,
+			DisableCaller: true,
+		}
+
+		cfg := config.Global.Log.MirrorLog
+		if cfg.Type != "" {
+			lc.Type = cfg.Type
+		}
+		if cfg.Format != "" {
+			lc.Format = cfg.Format
+		}
+		if cfg.File != "" {
+			lc.File = cfg.File
+		}
+		if cfg.MaxSize != 0 {
+			lc.MaxSize = cfg.MaxSize
+		}
+		if cfg.MaxAge != 0 {
+			lc.MaxAge = cfg.MaxAge
+		}
+		if cfg.MaxBackups != 0 {
+			lc.MaxBackups = cfg.MaxBackups
+		}
+		if err := filesystem.MkdirAll(filepath.Dir(lc.File)); err != nil {
+			glog.Error(context.Background(), "can't make directories for mirror logfile", glog.String("file", lc.File), glog.String("error", err.Error()))
+		}
+
+		mirrorLogger = glog.New(lc).Named(constant.GetAppName())
+	})
+	return mirrorLogger
+}
+
+// mirrorRequest shadow request, md is copied from the primary request, as metadata extensions read from
+// the primary ctx which is reused after handler returns
+type mirrorRequest struct {
+	namespace string
+	service   string
+	method    string
+	query     []byte
+	payload   []byte
+	md        metadata.MD
+}
+
+func newMirrorRequest(req Request, md metadata.MD) *mirrorRequest {
+	r := &mirrorRequest{
+		namespace: req.GetNamespace(),
+		service:   req.GetService(),
+		method:    req.GetMethod(),
+		query:     req.QueryString(),
+		md:        md,
+	}
+	if p := req.PayLoad(); p != nil {
+		r.payload, _ = io.ReadAll(p)
+	}
+	r.md.Set(constant.BgwMirrorFlag, "1")
+	return r
+}
+
+// GetNamespace get namespace
+func (r *mirrorRequest) GetNamespace() string { return r.namespace }
+
+// GetService get service
+func (r *mirrorRequest) GetService() string { return r.service }
+
+// GetMethod get method
+func (r *mirrorRequest) GetMethod() string { return r.method }
+
+// QueryString get query string
+func (r *mirrorRequest) QueryString() []byte { return r.query }
+
+// PayLoad get payload
+func (r *mirrorRequest) PayLoad() io.Reader { return bytes.NewReader(r.payload) }
+
+// GetMetadata get metadata
+func (r *mirrorRequest) GetMetadata() metadata.MD { return r.md }
+
+// SetMetadata set metadata
+func (r *mirrorRequest) SetMetadata(key, value string) { r.md.Set(key, value) }
+
+// String to string
+func (r *mirrorRequest) String() string {
+	return util.ToJSONString(map[string]interface{}{
+		"service":  r.service,
+		"method":   r.method,
+		"metadata": r.md,
+		"query":    string(r.query),
+	})
+}
+
+// mirrorOutcome comparable outcome of an upstream call
+type mirrorOutcome struct {
+	status int
+	code   string
+	cost   time.Duration
+	hash   uint64
+	err    string
+
+	// body to hash, proto message is kept as it is not pooled, encoding is deferred to digest
+	message protoiface.MessageV1
+	data    []byte
+}
+
+type messageGetter interface {
+	GetMessage() protoiface.MessageV1
+}
+
+// newMirrorOutcome snapshot outcome of result, digest must be called before comparing
+func newMirrorOutcome(result Result, cost time.Duration, err error) *mirrorOutcome {
+	o := &mirrorOutcome{cost: cost}
+	if err != nil {
+		o.code = strconv.FormatInt(berror.GetErrCode(err), 10)
+		o.err = err.Error()
+		return o
+	}
+	if result == nil {
+		return o
+	}
+
+	o.status = result.GetStatus()
+	o.code = "0"
+	if codes := result.Metadata().Get(constant.BgwAPIResponseCodes); len(codes) > 0 && codes[0] != "" {
+		o.code = codes[0]
+	}
+	if mg, ok := result.(messageGetter); ok {
+		o.message = mg.GetMessage()
+		return o
+	}
+	if data, e := result.GetData(); e == nil {
+		o.data = append([]byte(nil), data...)
+	}
+	return o
+}
+
+// digest hash the body of outcome
+func (o *mirrorOutcome) digest() {
+	data := o.data
+	if o.message != nil {
+		s, err := mirrorMarshaler.MarshalToString(o.message)
+		if err != nil {
+			return
+		}
+		data = []byte(s)
+	}
+	if data == nil {
+		return
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	o.hash = h.Sum64()
+	o.message, o.data = nil, nil
+}
+
+func (o *mirrorOutcome) fields(prefix string) []glog.Field {
+	fields := []glog.Field{
+		glog.Int64(prefix+"_status", int64(o.status)),
+		glog.String(prefix+"_code", o.code),
+		glog.Duration(prefix+"_cost", o.cost),
+		glog.String(prefix+"_hash", strconv.FormatUint(o.hash, 16)),
+	}
+	if o.err != "" {
+		fields = append(fields, glog.String(prefix+"_error", o.err))
+	}
+	return fields
+}
+
+// mirror duplicate the request to shadow registry, never blocks or fails the primary call,
+// only the request and primary outcome are copied synchronously, shadow calls are not observed by cluster
+func (c *controller) mirror(ctx *types.Ctx, mc *MethodConfig, md *gmetadata.Metadata, err error) {
+	mp := mc.GetMirror()
+	if mp == nil || !mp.sampled() {
+		return
+	}
+	// streams are not mirrored
+	if ctx.UserValue(constant.CtxInvokeStream) != nil {
+		return
+	}
+	primaryReq, ok := ctx.UserValue(constant.CtxInvokeReq).(Request)
+	if !ok {
+		return
+	}
+
+	select {
+	case mirrorSem <- struct{}{}:
+	default:
+		gmetric.IncDefaultCounter(mirrorMetricType, "drop")
+		return
+	}
+
+	// request and primary result are released after handler returns
+	var result Result
+	if err == nil {
+		result, _ = ctx.UserValue(constant.CtxInvokeResult).(Result)
+	}
+	primary := newMirrorOutcome(result, md.ReqCost, err)
+	reqMD := primaryReq.GetMetadata().Copy()
+	shadowCtx := &types.Ctx{}
+	ctx.Request.CopyTo(&shadowCtx.Request)
+	if body, ok := gmetadata.RequestHandledBodyFromContext(ctx); ok {
+		gmetadata.ContextWithRequestHandledBody(shadowCtx, append([]byte(nil), body...))
+	}
+	route := mc.RouteKey().String()
+	primaryAddr := md.InvokeAddr
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				glog.Error(context.Background(), "mirror panic", glog.Any("err", r), glog.String("route", route))
+			}
+			<-mirrorSem
+		}()
+
+		instances := c.serviceRegistry.GetInstances(mp.getRegistry(mc.Service()))
+		instance, e := cluster.GetSelector(c.ctx, constant.SelectorRandom).Select(context.Background(), instances)
+		if instance == nil || e != nil {
+			gmetric.IncDefaultError(mirrorMetricType, "instance_not_found")
+			return
+		}
+		addr := instance.GetAddress(mc.Service().Protocol)
+
+		call := c.invoker.newCall(shadowCtx, mc)
+		req := newMirrorRequest(call.request, reqMD)
+		gmetric.IncDefaultCounter(mirrorMetricType, "send")
+
+		tc, cancel := context.WithTimeout(context.Background(), mp.timeout(mc))
+		defer cancel()
+
+		start := time.Now()
+		err := call.invoke(tc, addr, req, call.result)
+		shadow := newMirrorOutcome(call.result, time.Since(start), err)
+		shadow.digest()
+		_ = call.result.Close()
+		primary.digest()
+
+		match := primary.status == shadow.status && primary.code == shadow.code && primary.hash == shadow.hash
+		if !match {
+			gmetric.IncDefaultCounter(mirrorMetricType, "mismatch")
+		}
+
+		fields := []glog.Field{
+			glog.String("route", route),
+			glog.String("primary_addr", primaryAddr),
+			glog.String("shadow_addr", addr),
+			glog.Bool("match", match),
+		}
+		fields = append(fields, primary.fields("primary")...)
+		fields = append(fields, shadow.fields("shadow")...)
+		getMirrorLogger().Info(tc, "mirror", fields...)
+	}()
+}
@@ -0,0 +1,77 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc/metadata"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/server/core/http"
+	"bgw/pkg/test"
+)
+
+func TestMirrorPolicy(t *testing.T) {
+	Convey("test mirror policy", t, func() {
+		mc := &MethodConfig{Timeout: 3, service: &ServiceConfig{Group: "A", Namespace: "ns"}}
+		So(mc.GetMirror(), ShouldBeNil)
+
+		mc.Mirror = &MirrorPolicy{Registry: "shadow"}
+		So(mc.GetMirror(), ShouldBeNil)
+
+		mc.Mirror.Sample = 100
+		mp := mc.GetMirror()
+		So(mp, ShouldNotBeNil)
+		So(mp.sampled(), ShouldBeTrue)
+		So(mp.timeout(mc), ShouldEqual, mc.GetTimeout())
+		mp.Timeout = 200
+		So(mp.timeout(mc), ShouldEqual, 200*time.Millisecond)
+		So(mp.getRegistry(mc.Service()), ShouldNotBeNil)
+	})
+}
+
+func TestMirrorRequest(t *testing.T) {
+	Convey("test mirror request", t, func() {
+		rctx, _ := test.NewReqCtx()
+		rctx.Request.SetRequestURI("/v5/order/list?symbol=BTCUSDT")
+		rctx.Request.SetBody([]byte(`{"a":1}`))
+
+		req := newMirrorRequest(http.NewRequest(rctx), metadata.MD{})
+
+		So(req.GetService(), ShouldEqual, "/v5/order/list")
+		So(string(req.QueryString()), ShouldEqual, "symbol=BTCUSDT")
+		data, _ := io.ReadAll(req.PayLoad())
+		So(string(data), ShouldEqual, `{"a":1}`)
+		So(req.GetMetadata().Get(constant.BgwMirrorFlag), ShouldResemble, []string{"1"})
+		So(req.String(), ShouldNotBeEmpty)
+	})
+}
+
+func TestMirrorOutcome(t *testing.T) {
+	Convey("test mirror outcome", t, func() {
+		r1 := http.NewResult()
+		r1.SetStatus(200)
+		_ = r1.SetData(bytes.NewReader([]byte(`{"a":1}`)))
+		r2 := http.NewResult()
+		r2.SetStatus(200)
+		_ = r2.SetData(bytes.NewReader([]byte(`{"a":1}`)))
+
+		o1 := newMirrorOutcome(r1, time.Millisecond, nil)
+		o2 := newMirrorOutcome(r2, time.Second, nil)
+		// snapshot is kept after result is released
+		_ = r1.Close()
+		o1.digest()
+		o2.digest()
+		So(o1.code, ShouldEqual, "0")
+		So(o1.hash, ShouldNotEqual, 0)
+		So(o1.hash, ShouldEqual, o2.hash)
+
+		o3 := newMirrorOutcome(nil, time.Second, errors.New("mock err"))
+		So(o3.code, ShouldEqual, "5000")
+		So(len(o3.fields("shadow")), ShouldEqual, 5)
+	})
+}
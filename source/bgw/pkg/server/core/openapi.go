@@ -0,0 +1,498 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"code.bydev.io/fbu/gateway/gway.git/generic"
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/filter/response/version"
+)
+
+const (
+	openapiVersion   = "3.0.3"
+	openapiSchemaRef = "#/components/schemas/"
+
+	// security schemes
+	securityUserToken    = "userToken"
+	securityAPIKey       = "apiKey"
+	securityAPISign      = "apiSign"
+	securityAPITimestamp = "apiTimestamp"
+)
+
+// Schema openapi schema object
+type Schema = map[string]interface{}
+
+// OpenAPI openapi 3 document of gateway routes
+type OpenAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components OpenAPIComponents                `json:"components"`
+}
+
+// OpenAPIInfo info of document
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPIComponents reusable schemas and security schemes
+type OpenAPIComponents struct {
+	Schemas         map[string]Schema `json:"schemas"`
+	SecuritySchemes map[string]Schema `json:"securitySchemes"`
+}
+
+// Operation operation of path and http method
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Routes      []string              `json:"x-bgw-routes,omitempty"` // all route keys of path and method, the first one is documented
+}
+
+// Parameter path or query parameter
+type Parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required,omitempty"`
+	Schema   Schema `json:"schema"`
+}
+
+// RequestBody request body
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response response of status
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType content of media type
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// BuildOpenAPI build openapi document of the routes loaded by controller, apps are filtered by match of app.module
+func BuildOpenAPI(ctx context.Context, title string, match func(app string) bool) (*OpenAPI, error) {
+	c, ok := GetController(ctx).(*controller)
+	if !ok || !c.inited.Load() {
+		return nil, errors.New("controller not inited")
+	}
+
+	apps := make([]*AppConfig, 0)
+	for _, app := range c.configManager.Values() {
+		if match == nil || match(app.Key()) {
+			apps = append(apps, app)
+		}
+	}
+
+	// descriptors are parsed from the local protoset cache of invoker, which is the same data loaded into engine
+	descriptors := make(map[string]generic.Descriptor)
+	for _, version := range c.versionController.Values() {
+		if match != nil && !match(version.Key()) {
+			continue
+		}
+		data := c.invoker.getLocalDescriptor(version)
+		if data == nil {
+			continue
+		}
+		d, err := generic.DescriptorFromProtoSetReader(bytes.NewReader(data))
+		if err != nil {
+			glog.Error(ctx, "openapi parse descriptor error", glog.String("namespace", version.Key()), glog.String("error", err.Error()))
+			continue
+		}
+		descriptors[version.Key()] = d
+	}
+	return NewOpenAPI(title, apps, descriptors), nil
+}
+
+// NewOpenAPI generate openapi document from route configs, request and response schemas of grpc routes
+// are derived from descriptors, key of descriptors is namespace app.module
+func NewOpenAPI(title string, apps []*AppConfig, descriptors map[string]generic.Descriptor) *OpenAPI {
+	b := &openapiBuilder{
+		descriptors: descriptors,
+		doc: &OpenAPI{
+			OpenAPI: openapiVersion,
+			Info:    OpenAPIInfo{Title: title, Version: constant.Version},
+			Paths:   make(map[string]map[string]*Operation),
+			Components: OpenAPIComponents{
+				Schemas:         make(map[string]Schema),
+				SecuritySchemes: securitySchemes(),
+			},
+		},
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].Key() < apps[j].Key()
+	})
+	for _, app := range apps {
+		app.Integrate()
+		for _, sc := range app.Services {
+			for _, mc := range sc.Methods {
+				if !mc.Disable {
+					b.addMethod(mc)
+				}
+			}
+		}
+	}
+
+	return b.doc
+}
+
+func securitySchemes() map[string]Schema {
+	header := func(name string) Schema {
+		return Schema{"type": "apiKey", "in": "header", "name": name}
+	}
+	return map[string]Schema{
+		securityUserToken:    header(constant.UserToken),
+		securityAPIKey:       header(constant.HeaderAPIKey),
+		securityAPISign:      header(constant.HeaderAPISign),
+		securityAPITimestamp: header(constant.HeaderAPITimeStamp),
+	}
+}
+
+type openapiBuilder struct {
+	descriptors map[string]generic.Descriptor
+	doc         *OpenAPI
+}
+
+func (b *openapiBuilder) addMethod(mc *MethodConfig) {
+	var (
+		filters = mc.GetFilters()
+		md      = b.methodDescriptor(mc)
+		route   = mc.RouteKey().AsMethod()
+	)
+
+	for _, method := range mc.GetMethod() {
+		method = strings.ToLower(strings.TrimPrefix(method, "HTTP_METHOD_"))
+		if method == "" {
+			continue
+		}
+
+		for _, p := range mc.GetPath() {
+			if p == "" {
+				continue
+			}
+			path, params := openapiPath(p)
+
+			ops, ok := b.doc.Paths[path]
+			if !ok {
+				ops = make(map[string]*Operation)
+				b.doc.Paths[path] = ops
+			}
+			// routes split by category or account share the path, only the first one is documented
+			if op, ok := ops[method]; ok {
+				op.Routes = append(op.Routes, route)
+				continue
+			}
+
+			op := &Operation{
+				OperationID: route + "." + method,
+				Tags:        []string{mc.Service().App.Key()},
+				Parameters:  params,
+				Responses:   make(map[string]*Response),
+				Security:    openapiSecurity(filters),
+				Routes:      []string{route},
+			}
+			b.fillOperation(op, mc, md, method, filters)
+			ops[method] = op
+		}
+	}
+}
+
+// methodDescriptor descriptor of grpc method, nil for http routes or descriptor not loaded
+func (b *openapiBuilder) methodDescriptor(mc *MethodConfig) *desc.MethodDescriptor {
+	if mc.Service().Protocol == constant.HttpProtocol {
+		return nil
+	}
+	d, ok := b.descriptors[mc.Service().Key()]
+	if !ok || d == nil {
+		return nil
+	}
+	sym, err := d.FindSymbol(mc.Service().GetFullQulifiedName())
+	if err != nil {
+		return nil
+	}
+	sd, ok := sym.(*desc.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+	return sd.FindMethodByName(mc.Name)
+}
+
+func (b *openapiBuilder) fillOperation(op *Operation, mc *MethodConfig, md *desc.MethodDescriptor, method string, filters []Filter) {
+	if md == nil {
+		op.Summary = mc.Service().Registry
+		if mc.Service().Protocol != constant.HttpProtocol {
+			op.Description = "descriptor not loaded: " + mc.Service().GetFullQulifiedName() + "/" + mc.Name
+		}
+		b.setBody(op, method, nil)
+		op.Responses["200"] = &Response{
+			Description: "OK",
+			Content:     jsonContent(envelope(filters, Schema{"type": "object"})),
+		}
+		return
+	}
+
+	op.Summary = md.GetFullyQualifiedName()
+	if txt, err := generic.GetDescriptorText(md, nil); err == nil {
+		op.Description = "```proto\n" + txt + "\n```"
+	}
+
+	switch {
+	case md.IsClientStreaming() && md.IsServerStreaming():
+		op.Description = "bidi streaming, served over websocket, each text frame is a request message.\n\n" + op.Description
+		op.Responses["101"] = &Response{
+			Description: "Switching Protocols, each text frame is a response message",
+			Content:     jsonContent(b.messageRef(md.GetOutputType())),
+		}
+	case md.IsServerStreaming():
+		b.setBody(op, method, md.GetInputType())
+		op.Responses["200"] = &Response{
+			Description: "server-sent events, data of each event is a response message",
+			Content: map[string]*MediaType{
+				contentTypeEventStream: {Schema: b.messageRef(md.GetOutputType())},
+			},
+		}
+	default:
+		b.setBody(op, method, md.GetInputType())
+		op.Responses["200"] = &Response{
+			Description: "OK",
+			Content:     jsonContent(envelope(filters, b.messageRef(md.GetOutputType()))),
+		}
+	}
+}
+
+// setBody set query parameters of GET or json body of other methods
+func (b *openapiBuilder) setBody(op *Operation, method string, input *desc.MessageDescriptor) {
+	if method == strings.ToLower(http.MethodGet) {
+		if input == nil {
+			return
+		}
+		for _, f := range input.GetFields() {
+			if f.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE || f.IsMap() {
+				continue
+			}
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:   f.GetJSONName(),
+				In:     "query",
+				Schema: b.fieldSchema(f),
+			})
+		}
+		return
+	}
+
+	schema := Schema{"type": "object"}
+	if input != nil {
+		schema = b.messageRef(input)
+	}
+	op.RequestBody = &RequestBody{Content: jsonContent(schema)}
+}
+
+// messageRef reference of message schema, well known types are inlined
+func (b *openapiBuilder) messageRef(md *desc.MessageDescriptor) Schema {
+	name := md.GetFullyQualifiedName()
+	if s, ok := wellKnownSchema(name); ok {
+		return s
+	}
+
+	if _, ok := b.doc.Components.Schemas[name]; !ok {
+		// placeholder first, message may be recursive
+		b.doc.Components.Schemas[name] = Schema{}
+		props := make(map[string]interface{}, len(md.GetFields()))
+		for _, f := range md.GetFields() {
+			props[f.GetJSONName()] = b.fieldSchema(f)
+		}
+		b.doc.Components.Schemas[name] = Schema{"type": "object", "properties": props}
+	}
+	return Schema{"$ref": openapiSchemaRef + name}
+}
+
+func (b *openapiBuilder) fieldSchema(f *desc.FieldDescriptor) Schema {
+	if f.IsMap() {
+		return Schema{"type": "object", "additionalProperties": b.valueSchema(f.GetMapValueType())}
+	}
+	s := b.valueSchema(f)
+	if f.IsRepeated() {
+		return Schema{"type": "array", "items": s}
+	}
+	return s
+}
+
+// valueSchema schema of field value, follows jsonpb encoding
+func (b *openapiBuilder) valueSchema(f *desc.FieldDescriptor) Schema {
+	switch f.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return b.messageRef(f.GetMessageType())
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		values := make([]string, 0, len(f.GetEnumType().GetValues()))
+		for _, v := range f.GetEnumType().GetValues() {
+			values = append(values, v.GetName())
+		}
+		return Schema{"type": "string", "enum": values}
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		return Schema{"type": "boolean"}
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING:
+		return Schema{"type": "string"}
+	case descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return Schema{"type": "string", "format": "byte"}
+	case descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		return Schema{"type": "number", "format": "double"}
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT:
+		return Schema{"type": "number", "format": "float"}
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+		descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+		return Schema{"type": "integer", "format": "int32"}
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT32, descriptorpb.FieldDescriptorProto_TYPE_FIXED32:
+		return Schema{"type": "integer", "format": "int64"}
+	default:
+		// 64 bits integers are encoded as string
+		return Schema{"type": "string", "format": "int64"}
+	}
+}
+
+func wellKnownSchema(name string) (Schema, bool) {
+	switch name {
+	case "google.protobuf.Timestamp":
+		return Schema{"type": "string", "format": "date-time"}, true
+	case "google.protobuf.Duration", "google.protobuf.FieldMask", "google.protobuf.StringValue":
+		return Schema{"type": "string"}, true
+	case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return Schema{"type": "string", "format": "int64"}, true
+	case "google.protobuf.Int32Value", "google.protobuf.UInt32Value":
+		return Schema{"type": "integer"}, true
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue":
+		return Schema{"type": "number"}, true
+	case "google.protobuf.BoolValue":
+		return Schema{"type": "boolean"}, true
+	case "google.protobuf.BytesValue":
+		return Schema{"type": "string", "format": "byte"}, true
+	case "google.protobuf.ListValue":
+		return Schema{"type": "array", "items": Schema{}}, true
+	case "google.protobuf.Value":
+		return Schema{}, true
+	case "google.protobuf.Struct", "google.protobuf.Any", "google.protobuf.Empty":
+		return Schema{"type": "object"}, true
+	default:
+		return nil, false
+	}
+}
+
+// openapiPath convert route params :name into {name}
+func openapiPath(p string) (string, []*Parameter) {
+	var params []*Parameter
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") && len(part) > 1 {
+			parts[i] = "{" + part[1:] + "}"
+			params = append(params, &Parameter{
+				Name:     part[1:],
+				In:       "path",
+				Required: true,
+				Schema:   Schema{"type": "string"},
+			})
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+// openapiSecurity auth requirements of route, guest allowed routes can be called without credentials
+func openapiSecurity(filters []Filter) []map[string][]string {
+	var res []map[string][]string
+	guest := false
+	for _, f := range filters {
+		switch f.Name {
+		case filter.AuthFilterKey:
+			res = append(res, map[string][]string{securityUserToken: {}})
+		case filter.OpenAPIFilterKey:
+			res = append(res, map[string][]string{securityAPIKey: {}, securityAPISign: {}, securityAPITimestamp: {}})
+		default:
+			continue
+		}
+		if _, ok := filterFlag(f.GetArgs(), "allowGuest"); ok {
+			guest = true
+		}
+	}
+	if guest {
+		res = append(res, map[string][]string{})
+	}
+	return res
+}
+
+// envelope wrap result schema by response filter version
+func envelope(filters []Filter, result Schema) Schema {
+	var args []string
+	found := false
+	for _, f := range filters {
+		if f.Name == filter.ResponseFilterKey {
+			args, found = f.GetArgs(), true
+			break
+		}
+	}
+	if !found {
+		return result
+	}
+	if _, ok := filterFlag(args, "passthrough"); ok {
+		return result
+	}
+
+	v, _ := filterFlag(args, "version")
+	switch v {
+	case version.VersionPassthrough:
+		return result
+	case version.VersionV1:
+		return Schema{"type": "object", "properties": map[string]interface{}{
+			"ret_code": Schema{"type": "integer"},
+			"ret_msg":  Schema{"type": "string"},
+			"result":   result,
+			"ext_code": Schema{"type": "string"},
+			"ext_info": Schema{},
+			"time_now": Schema{"type": "string"},
+		}}
+	default:
+		return Schema{"type": "object", "properties": map[string]interface{}{
+			"retCode":    Schema{"type": "integer"},
+			"retMsg":     Schema{"type": "string"},
+			"result":     result,
+			"retExtInfo": Schema{"type": "object"},
+			"time":       Schema{"type": "integer", "format": "int64"},
+		}}
+	}
+}
+
+// filterFlag get value of flag --name or --name=value in filter args
+func filterFlag(args []string, name string) (string, bool) {
+	for _, arg := range args {
+		arg = strings.TrimLeft(strings.TrimSpace(arg), "-")
+		k, v, ok := strings.Cut(arg, "=")
+		if !ok {
+			k, v, _ = strings.Cut(arg, " ")
+		}
+		if k == name {
+			return strings.TrimSpace(v), v != "false"
+		}
+	}
+	return "", false
+}
+
+func jsonContent(s Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: s}}
+}
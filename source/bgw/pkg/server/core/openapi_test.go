@@ -0,0 +1,121 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/server/filter"
+)
+
+const openapiTestProto = `
+syntax = "proto3";
+package demo;
+
+import "google/protobuf/timestamp.proto";
+
+enum Side {
+  BUY = 0;
+  SELL = 1;
+}
+
+message Order {
+  string order_id = 1;
+  int64 qty = 2;
+  Side side = 3;
+  repeated Order children = 4;
+  map<string, double> fees = 5;
+  google.protobuf.Timestamp created = 6;
+}
+`
+
+func TestNewOpenAPI(t *testing.T) {
+	Convey("test new openapi", t, func() {
+		app := &AppConfig{
+			App:    "demo",
+			Module: "order",
+			Services: []*ServiceConfig{
+				{
+					Registry: "demo-http",
+					Protocol: constant.HttpProtocol,
+					Filters: []Filter{
+						{Name: filter.ResponseFilterKey, Args: "--version=v1"},
+						{Name: filter.AuthFilterKey, Args: "--allowGuest"},
+					},
+					Methods: []*MethodConfig{
+						{Path: "/v5/order/:id", HttpMethod: "HTTP_METHOD_GET"},
+						{Path: "/v5/order/create", HttpMethods: []string{"HTTP_METHOD_POST"}, Filters: []Filter{{Name: filter.OpenAPIFilterKey}}},
+						{Path: "/v5/order/create", HttpMethods: []string{"HTTP_METHOD_POST"}, Category: "spot"},
+						{Path: "/v5/order/cancel", HttpMethod: "HTTP_METHOD_POST", Disable: true},
+					},
+				},
+			},
+		}
+
+		doc := NewOpenAPI("demo", []*AppConfig{app}, nil)
+		So(doc.OpenAPI, ShouldEqual, openapiVersion)
+		So(len(doc.Paths), ShouldEqual, 2)
+
+		get := doc.Paths["/v5/order/{id}"]["get"]
+		So(get, ShouldNotBeNil)
+		So(get.Parameters[0].Name, ShouldEqual, "id")
+		So(get.Parameters[0].In, ShouldEqual, "path")
+		So(get.Security, ShouldResemble, []map[string][]string{{securityUserToken: {}}, {}})
+		props := get.Responses["200"].Content["application/json"].Schema["properties"].(map[string]interface{})
+		So(props, ShouldContainKey, "ret_code")
+
+		post := doc.Paths["/v5/order/create"]["post"]
+		So(post, ShouldNotBeNil)
+		So(post.RequestBody, ShouldNotBeNil)
+		So(len(post.Routes), ShouldEqual, 2)
+		So(len(post.Security), ShouldEqual, 3)
+	})
+}
+
+func TestOpenAPIBuilder_MessageRef(t *testing.T) {
+	Convey("test openapi message schema", t, func() {
+		p := protoparse.Parser{
+			Accessor: protoparse.FileContentsFromMap(map[string]string{"demo.proto": openapiTestProto}),
+		}
+		fds, err := p.ParseFiles("demo.proto")
+		So(err, ShouldBeNil)
+		order := fds[0].FindMessage("demo.Order")
+		So(order, ShouldNotBeNil)
+
+		b := &openapiBuilder{doc: &OpenAPI{Components: OpenAPIComponents{Schemas: make(map[string]Schema)}}}
+		ref := b.messageRef(order)
+		So(ref["$ref"], ShouldEqual, openapiSchemaRef+"demo.Order")
+
+		props := b.doc.Components.Schemas["demo.Order"]["properties"].(map[string]interface{})
+		So(props["orderId"], ShouldResemble, Schema{"type": "string"})
+		So(props["qty"], ShouldResemble, Schema{"type": "string", "format": "int64"})
+		So(props["side"], ShouldResemble, Schema{"type": "string", "enum": []string{"BUY", "SELL"}})
+		So(props["children"], ShouldResemble, Schema{"type": "array", "items": ref})
+		So(props["fees"], ShouldResemble, Schema{"type": "object", "additionalProperties": Schema{"type": "number", "format": "double"}})
+		So(props["created"], ShouldResemble, Schema{"type": "string", "format": "date-time"})
+	})
+}
+
+func TestFilterFlag(t *testing.T) {
+	Convey("test filter flag", t, func() {
+		args := Filter{Args: "--version=v1 --allowGuest --bizType 2 --passthrough=false"}.GetArgs()
+		v, ok := filterFlag(args, "version")
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, "v1")
+		_, ok = filterFlag(args, "allowGuest")
+		So(ok, ShouldBeTrue)
+		v, _ = filterFlag(args, "bizType")
+		So(v, ShouldEqual, "2")
+		_, ok = filterFlag(args, "passthrough")
+		So(ok, ShouldBeFalse)
+		_, ok = filterFlag(args, "any")
+		So(ok, ShouldBeFalse)
+
+		p, params := openapiPath("/v5/:category/order")
+		So(p, ShouldEqual, "/v5/{category}/order")
+		So(strings.Join([]string{params[0].Name, params[0].In}, ","), ShouldEqual, "category,path")
+	})
+}
@@ -59,6 +59,8 @@ type RouteManager interface {
 	Load(appConf *AppConfig) error
 	// Routes 获取所有路由信息
 	Routes() []*Route
+	// GetMethodConfig 获取route对应的method配置,route需要是FindRoute/FindRoutes返回的结果
+	GetMethodConfig(route *Route) *MethodConfig
 }
 
 func parseRouteTypeBySelectorMeta(s *SelectorMeta) groute.RouteType {
@@ -80,6 +82,7 @@ func newRouteManager(fn handlerCreateFunc) RouteManager {
 	return &routeManager{
 		creator: fn,
 		mgr:     groute.NewManager(),
+		configs: make(map[string]map[*Route]*MethodConfig),
 	}
 }
 
@@ -107,9 +110,34 @@ func (u *userinfo) Fill(p RouteDataProvider) error {
 }
 
 type routeManager struct {
-	creator handlerCreateFunc // 创建handler回调函数
-	mgr     groute.Manager    //
-	mux     sync.Mutex        //
+	creator handlerCreateFunc                   // 创建handler回调函数
+	mgr     groute.Manager                      //
+	mux     sync.Mutex                          //
+	configs map[string]map[*Route]*MethodConfig // appkey -> route -> method config
+	cfgMux  sync.RWMutex                        //
+}
+
+// GetMethodConfig 获取route对应的method配置
+func (rm *routeManager) GetMethodConfig(route *Route) *MethodConfig {
+	if route == nil {
+		return nil
+	}
+
+	rm.cfgMux.RLock()
+	defer rm.cfgMux.RUnlock()
+	configs := rm.configs[route.AppKey]
+	if mc, ok := configs[route]; ok {
+		return mc
+	}
+
+	// route被复制时按照path等信息匹配
+	for r, mc := range configs {
+		if r.Method == route.Method && r.Path == route.Path && r.ServerName == route.ServerName && r.Account == route.Account {
+			return mc
+		}
+	}
+
+	return nil
 }
 
 // Routes 根据path聚合routes并返回
@@ -283,6 +311,7 @@ func (rm *routeManager) Load(appConf *AppConfig) error {
 	defer rm.mux.Unlock()
 
 	routes := make([]*Route, 0)
+	configs := make(map[*Route]*MethodConfig)
 
 	appKey := appConf.Key()
 	now := time.Now()
@@ -331,16 +360,43 @@ func (rm *routeManager) Load(appConf *AppConfig) error {
 							return err
 						} else {
 							routes = append(routes, x...)
+							for _, r := range x {
+								configs[r] = mc
+							}
 						}
 					} else {
 						routes = append(routes, route)
+						configs[route] = mc
 					}
 				}
 			}
 		}
 	}
 
-	return rm.mgr.Replace(appKey, routes)
+	if err := rm.mgr.Replace(appKey, routes); err != nil {
+		return err
+	}
+
+	rm.cfgMux.Lock()
+	if rm.configs == nil {
+		rm.configs = make(map[string]map[*Route]*MethodConfig)
+	}
+	rm.configs[appKey] = configs
+	rm.cfgMux.Unlock()
+	return nil
+}
+
+// CheckRoutes load apps into an empty route manager without creating handlers,
+// conflicts between apps (duplicate all-in-one, default or category routes) are returned
+func CheckRoutes(apps []*AppConfig) map[string]error {
+	rm := newRouteManager(func(mc *MethodConfig) (types.Handler, error) { return nil, nil })
+	errs := make(map[string]error)
+	for _, ac := range apps {
+		if err := rm.Load(ac); err != nil {
+			errs[ac.Key()] = err
+		}
+	}
+	return errs
 }
 
 func (rm *routeManager) buildGroups(route *Route, meta *SelectorMeta) ([]*groute.Route, error) {
@@ -340,6 +340,34 @@ func newMockProvider(method string, path string, category string, account consta
 	}
 }
 
+func TestGetMethodConfig(t *testing.T) {
+	rm := &routeManager{
+		creator: func(mc *MethodConfig) (types.Handler, error) { return nil, nil },
+		mgr:     &mockMgr{},
+	}
+	assert.Nil(t, rm.GetMethodConfig(nil))
+
+	mc := &MethodConfig{Path: "/v5/order/amend", HttpMethod: "HTTP_METHOD_POST", AllowWSS: true}
+	app := &AppConfig{
+		App:    "demo",
+		Module: "order",
+		Services: []*ServiceConfig{
+			{Registry: "demo-order", Methods: []*MethodConfig{mc}},
+		},
+	}
+	assert.NoError(t, rm.Load(app))
+	assert.Equal(t, 1, len(rm.configs[app.Key()]))
+
+	for route := range rm.configs[app.Key()] {
+		assert.Equal(t, mc, rm.GetMethodConfig(route))
+		copied := *route
+		assert.Equal(t, mc, rm.GetMethodConfig(&copied))
+		assert.True(t, rm.GetMethodConfig(&copied).GetAllowWSS())
+	}
+
+	assert.Nil(t, rm.GetMethodConfig(&Route{AppKey: app.Key(), Method: "GET", Path: "/v5/order/amend"}))
+}
+
 type mockProvider struct {
 	method  string
 	path    string
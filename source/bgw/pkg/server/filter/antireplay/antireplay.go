@@ -95,7 +95,7 @@ func (a *antiReplay) Do(next types.Handler) types.Handler {
 
 // Init will init the anti replay filter
 func (a *antiReplay) Init(ctx context.Context, args ...string) error {
-	if len(args) == 0 {
+	if len(args) == 0 || filter.IsDryRun(ctx) {
 		return nil
 	}
 	if err := a.antiReplayMgr.Init(ctx); err != nil {
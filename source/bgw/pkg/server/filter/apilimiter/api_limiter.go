@@ -91,6 +91,9 @@ func (a *apiLimiter) Do(next types.Handler) types.Handler {
 // Init implement filter.Initializer
 // no need args
 func (a *apiLimiter) Init(ctx context.Context, args ...string) error {
+	if filter.IsDryRun(ctx) {
+		return nil
+	}
 	a.discovery = discovery.NewServiceRegistry(ctx)
 	return a.discovery.Watch(ctx, a.openAPIURL)
 }
@@ -442,6 +442,9 @@ func (a *auth) Init(ctx context.Context, args ...string) error {
 	if err != nil {
 		return err
 	}
+	if filter.IsDryRun(ctx) {
+		return nil
+	}
 	a.rules.Store(args[0], &rule)
 
 	_, err = dynconfig.GetBrokerIdLoader(ctx)
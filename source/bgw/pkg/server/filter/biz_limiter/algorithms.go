@@ -0,0 +1,78 @@
+package biz_limiter
+
+import (
+	"code.bydev.io/fbu/gateway/gway.git/gredis"
+
+	"bgw/pkg/common/types"
+	"bgw/pkg/remoting/redis"
+	"bgw/pkg/service"
+)
+
+const (
+	limitGCRA          = "gcra"           // token bucket, default
+	limitSlidingLog    = "sliding_log"    // exact count of requests in the last period
+	limitSlidingWindow = "sliding_window" // weighted count of current and previous fixed window
+	limitConcurrency   = "concurrency"    // in-flight requests, rate is the max in-flight count
+
+	concurrencyUserKey = "bizLimiterConcurrency"
+)
+
+// isLimitType reports whether the limit type is supported, empty means gcra
+func isLimitType(limitType string) bool {
+	switch limitType {
+	case "", limitGCRA, limitCounter, limitSlidingLog, limitSlidingWindow, limitConcurrency:
+		return true
+	default:
+		return false
+	}
+}
+
+// isWindowType reports whether the limit type is served by allowWindow
+func isWindowType(limitType string) bool {
+	switch limitType {
+	case limitSlidingLog, limitSlidingWindow, limitConcurrency:
+		return true
+	default:
+		return false
+	}
+}
+
+// concurrencySlot in-flight slot acquired by the request
+type concurrencySlot struct {
+	key  string
+	slot string
+}
+
+// allowWindow limits with sliding window or concurrency algorithm,
+// the acquired concurrency slot is recorded in ctx and returned by releaseConcurrency.
+func allowWindow(ctx *types.Ctx, key, limitType string, step int, limit gredis.Limit) (*gredis.Result, error) {
+	c := service.GetContext(ctx)
+	switch limitType {
+	case limitSlidingLog:
+		return redis.AllowSlidingLog(c, key, limit, step)
+	case limitSlidingWindow:
+		return redis.AllowSlidingWindow(c, key, limit, step)
+	default:
+		result, slot, err := redis.AcquireConcurrency(c, key, limit)
+		if err == nil && result.Allowed > 0 {
+			slots, _ := ctx.UserValue(concurrencyUserKey).([]concurrencySlot)
+			ctx.SetUserValue(concurrencyUserKey, append(slots, concurrencySlot{key: key, slot: slot}))
+		}
+		return result, err
+	}
+}
+
+// releaseConcurrency returns all concurrency slots acquired by the request,
+// it's called after the request is finished, including rejected by a later rule or filter.
+// slots are released asynchronously, the response does not wait for redis.
+func releaseConcurrency(ctx *types.Ctx) {
+	slots, _ := ctx.UserValue(concurrencyUserKey).([]concurrencySlot)
+	if len(slots) == 0 {
+		return
+	}
+	ctx.SetUserValue(concurrencyUserKey, nil)
+
+	for _, s := range slots {
+		redis.ReleaseConcurrency(s.key, s.slot)
+	}
+}
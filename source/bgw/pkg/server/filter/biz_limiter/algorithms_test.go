@@ -0,0 +1,110 @@
+package biz_limiter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/gredis"
+	"github.com/agiledragon/gomonkey/v2"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/valyala/fasthttp"
+
+	"bgw/pkg/common/types"
+	"bgw/pkg/remoting/redis"
+	"bgw/pkg/server/metadata"
+	"bgw/pkg/test"
+)
+
+func TestLimitType(t *testing.T) {
+	Convey("test limit type", t, func() {
+		So(isLimitType(""), ShouldBeTrue)
+		So(isLimitType(limitCounter), ShouldBeTrue)
+		So(isLimitType(limitSlidingLog), ShouldBeTrue)
+		So(isLimitType(limitConcurrency), ShouldBeTrue)
+		So(isLimitType("bucket"), ShouldBeFalse)
+
+		So(isWindowType(limitSlidingWindow), ShouldBeTrue)
+		So(isWindowType(limitGCRA), ShouldBeFalse)
+		So(isWindowType(limitCounter), ShouldBeFalse)
+	})
+
+	Convey("test parse limit type flag", t, func() {
+		rl := &rateLimiter{}
+		err := rl.parseFlags([]string{"limiter", "--rate=10", "--type=sliding_log"})
+		So(err, ShouldBeNil)
+		So(rl.flags.limitType, ShouldEqual, limitSlidingLog)
+
+		err = rl.parseFlags([]string{"limiter", "--rate=10", "--type=counter"})
+		So(err, ShouldNotBeNil)
+
+		lm := &rateLimiterV2{}
+		err = lm.parseFlags([]string{"limiterV2", "--type=xxx",
+			`--rules=[{"rate":10,"type":"concurrency"},{"rate":10,"type":"xxx"}]`,
+			`--options=[{"rate":10,"limitType":"sliding_window"}]`})
+		So(err, ShouldBeNil)
+		So(lm.flags.limitType, ShouldEqual, "")
+		So(len(lm.flags.rules), ShouldEqual, 3)
+		So(lm.flags.rules[0].LimitType, ShouldEqual, limitConcurrency)
+		So(lm.flags.rules[1].LimitType, ShouldEqual, "")
+		So(lm.flags.rules[2].LimitType, ShouldEqual, limitSlidingWindow)
+	})
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	Convey("test concurrency acquire and release", t, func() {
+		inflight := 0
+		var released []string
+		p := gomonkey.ApplyFunc(redis.AcquireConcurrency, func(ctx context.Context, key string, limit gredis.Limit) (*gredis.Result, string, error) {
+			if inflight >= limit.Rate {
+				return &gredis.Result{Limit: limit}, "", nil
+			}
+			inflight++
+			return &gredis.Result{Limit: limit, Allowed: 1, Remaining: limit.Rate - inflight}, "slot", nil
+		})
+		defer p.Reset()
+		p.ApplyFunc(redis.ReleaseConcurrency, func(key, slot string) {
+			released = append(released, slot)
+			inflight--
+		})
+
+		limit := gredis.Limit{Rate: 1, Period: time.Second}
+		lm := &rateLimiterV2{}
+		rctx, _ := test.NewReqCtx()
+		So(lm.doLimit(rctx, "key", limitConcurrency, 1, limit, true), ShouldBeNil)
+		So(lm.doLimit(rctx, "key", limitConcurrency, 1, limit, true), ShouldNotBeNil)
+		So(inflight, ShouldEqual, 1)
+
+		releaseConcurrency(rctx)
+		So(inflight, ShouldEqual, 0)
+		So(released, ShouldResemble, []string{"slot"})
+		So(rctx.UserValue(concurrencyUserKey), ShouldBeNil)
+
+		// released after the request finished
+		lm.flags.rules = []limitRule{{Limit: limit, LimitType: limitConcurrency, Step: 1}}
+		p.ApplyPrivateMethod(reflect.TypeOf(lm), "loadQuota", func(ctx *types.Ctx, md *metadata.Metadata, dataProvider string, rule limitRule) (gredis.Limit, string, error) {
+			return limit, "key", nil
+		})
+		h := lm.Do(func(ctx *fasthttp.RequestCtx) error {
+			So(inflight, ShouldEqual, 1)
+			return nil
+		})
+		rctx, _ = test.NewReqCtx()
+		So(h(rctx), ShouldBeNil)
+		So(inflight, ShouldEqual, 0)
+	})
+
+	Convey("test concurrency redis error", t, func() {
+		p := gomonkey.ApplyFunc(redis.AcquireConcurrency, func(ctx context.Context, key string, limit gredis.Limit) (*gredis.Result, string, error) {
+			return nil, "", errors.New("mock")
+		})
+		defer p.Reset()
+
+		lm := &rateLimiterV2{}
+		rctx, _ := test.NewReqCtx()
+		So(lm.doLimit(rctx, "key", limitConcurrency, 1, gredis.Limit{Rate: 1}, true), ShouldBeNil)
+		So(rctx.UserValue(concurrencyUserKey), ShouldBeNil)
+	})
+}
@@ -0,0 +1,228 @@
+package biz_limiter
+
+import (
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/gredis"
+
+	"bgw/pkg/common/berror"
+	"bgw/pkg/common/types"
+	"bgw/pkg/common/util"
+	"bgw/pkg/remoting/redis"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/metadata"
+	"bgw/pkg/service"
+)
+
+var (
+	_ filter.Explainer = &rateLimiter{}
+	_ filter.Explainer = &rateLimiterV2{}
+	_ filter.Explainer = &limiterMemo{}
+)
+
+// RuleExplain how a limit rule applies to the request
+type RuleExplain struct {
+	Filter    string `json:"filter"`
+	Rule      string `json:"rule,omitempty"`
+	Type      string `json:"type"`
+	Provider  string `json:"provider,omitempty"` // quota data provider
+	Key       string `json:"key,omitempty"`      // limit key without redis prefix
+	Rate      int    `json:"rate,omitempty"`     // effective quota
+	Burst     int    `json:"burst,omitempty"`
+	Period    string `json:"period,omitempty"`
+	Step      int    `json:"step,omitempty"`
+	Remaining int    `json:"remaining"`      // -1 if unknown
+	Next      []bool `json:"next,omitempty"` // whether each of the next n requests is allowed if sent at once
+	Error     string `json:"error,omitempty"`
+}
+
+// Explain implements filter.Explainer, remaining is peeked from redis without consuming quota
+func (r *rateLimiter) Explain(ctx *types.Ctx, n int) interface{} {
+	md := metadata.MDFromContext(ctx)
+	limitType := r.flags.limitType
+	if limitType == "" {
+		limitType = limitGCRA
+	}
+	e := &RuleExplain{
+		Filter:    r.GetName(),
+		Rule:      util.ToJSONString(map[string]interface{}{"group": r.flags.group, "hasSymbol": r.flags.hasSymbol, "unified": r.flags.unified}),
+		Type:      limitType,
+		Step:      1,
+		Remaining: -1,
+	}
+
+	limit, key, err := r.resolveLimit(ctx, md.Route, md.UID)
+	if err != nil {
+		e.Error = err.Error()
+		return []*RuleExplain{e}
+	}
+	e.explain(ctx, r.limiter, key, limit, n)
+	return []*RuleExplain{e}
+}
+
+// Explain implements filter.Explainer, remaining is peeked from redis without consuming quota
+func (r *rateLimiterV2) Explain(ctx *types.Ctx, n int) interface{} {
+	md := metadata.MDFromContext(ctx)
+	rules := matchedRules(ctx, md, r.flags)
+	res := make([]*RuleExplain, 0, len(rules))
+	for _, rule := range rules {
+		e := newRuleExplain(r.GetName(), r.flags, rule)
+		limit, key, err := r.resolveLimit(ctx, md, e.Provider, e.Type, rule)
+		if err != nil {
+			e.Error = err.Error()
+		} else {
+			e.explain(ctx, r.limiter, key, limit, n)
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// Explain implements filter.Explainer, memo limiter is per pod and remaining is unknown
+func (l *limiterMemo) Explain(ctx *types.Ctx, _ int) interface{} {
+	md := metadata.MDFromContext(ctx)
+	rules := matchedRules(ctx, md, l.flags)
+	res := make([]*RuleExplain, 0, len(rules))
+	for _, rule := range rules {
+		e := newRuleExplain(l.GetName(), l.flags, rule)
+		e.Type = "memo"
+		res = append(res, e)
+		if rule.UID && md.UID <= 0 {
+			e.Error = berror.NewInterErr("limiter_v3 uid is 0").Error()
+			continue
+		}
+		limit, key, err := l.loadQuota(ctx, md, e.Provider, rule)
+		if err != nil {
+			e.Error = err.Error()
+			continue
+		}
+		limit, _ = capLimit(limit)
+		e.setLimit(key, limit)
+	}
+	return res
+}
+
+// matchedRules rules evaluated by Do of v2 and memo limiters
+func matchedRules(ctx *types.Ctx, md *metadata.Metadata, f flagsV2) []limitRule {
+	if f.selectType == selectTypeOne {
+		app := md.GetRoute().GetAppName(ctx)
+		for _, ru := range f.rules {
+			if ru.Category == app {
+				return []limitRule{ru}
+			}
+		}
+		return nil
+	}
+
+	rules := make([]limitRule, 0, len(f.rules))
+	for _, ru := range f.rules {
+		if !ru.IsZero() {
+			rules = append(rules, ru)
+		}
+	}
+	return rules
+}
+
+func newRuleExplain(name string, f flagsV2, rule limitRule) *RuleExplain {
+	if rule.DataProvider == "" {
+		rule.DataProvider = f.dataProvider
+	}
+	if rule.LimitType == "" {
+		rule.LimitType = f.limitType
+	}
+	e := &RuleExplain{
+		Filter:    name,
+		Rule:      util.ToJSONString(rule),
+		Type:      rule.LimitType,
+		Provider:  rule.DataProvider,
+		Step:      rule.Step,
+		Remaining: -1,
+	}
+	if e.Type == "" {
+		e.Type = limitGCRA
+	}
+	return e
+}
+
+func (e *RuleExplain) setLimit(key string, limit gredis.Limit) {
+	e.Key = key
+	e.Rate = limit.Rate
+	e.Burst = limit.Burst
+	e.Period = limit.Period.String()
+}
+
+// explain peek remaining of key and simulate the next n requests
+func (e *RuleExplain) explain(ctx *types.Ctx, l *gredis.Limiter, key string, limit gredis.Limit, n int) {
+	e.setLimit(key, limit)
+
+	remaining, err := peekRemaining(ctx, l, key, e.Type, limit)
+	if err != nil {
+		e.Error = err.Error()
+		return
+	}
+	e.Remaining = remaining
+	e.Next = simulateNext(remaining, e.Step, n)
+}
+
+// peekRemaining query remaining quota with zero cost
+func peekRemaining(ctx *types.Ctx, l *gredis.Limiter, key, limitType string, limit gredis.Limit) (int, error) {
+	var (
+		c      = service.GetContext(ctx)
+		result *gredis.Result
+		err    error
+	)
+	switch limitType {
+	case limitConcurrency:
+		inflight, err := redis.PeekConcurrency(c, key)
+		if err != nil {
+			return 0, err
+		}
+		if remaining := limit.Rate - inflight; remaining > 0 {
+			return remaining, nil
+		}
+		return 0, nil
+	case limitCounter:
+		result, err = redis.AllowM(c, l, key, limit, 0)
+	case limitSlidingLog:
+		result, err = redis.AllowSlidingLog(c, key, limit, 0)
+	case limitSlidingWindow:
+		result, err = redis.AllowSlidingWindow(c, key, limit, 0)
+	default:
+		result, err = redis.AllowN(c, l, key, limit, 0)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return result.Remaining, nil
+}
+
+// simulateNext whether each of the next n requests is allowed if they are sent at once
+func simulateNext(remaining, step, n int) []bool {
+	if step <= 0 {
+		step = 1
+	}
+	next := make([]bool, n)
+	for i := range next {
+		if remaining >= step {
+			next[i] = true
+			remaining -= step
+		}
+	}
+	return next
+}
+
+// capLimit apply default rate cap and burst, false if rate is 0
+func capLimit(limit gredis.Limit) (gredis.Limit, bool) {
+	ratio := int(limit.Period / time.Second)
+	defaultValue := defaultRate * ratio
+	if limit.Rate > defaultValue {
+		limit.Rate = defaultValue
+	}
+	if limit.Rate <= 0 {
+		return limit, false
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Rate
+	}
+	return limit, true
+}
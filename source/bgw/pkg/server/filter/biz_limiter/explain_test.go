@@ -0,0 +1,84 @@
+package biz_limiter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/gredis"
+	"github.com/agiledragon/gomonkey/v2"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"bgw/pkg/common/types"
+	"bgw/pkg/remoting/redis"
+	"bgw/pkg/server/metadata"
+	"bgw/pkg/test"
+)
+
+func TestSimulateNext(t *testing.T) {
+	Convey("test simulate next", t, func() {
+		So(simulateNext(3, 1, 5), ShouldResemble, []bool{true, true, true, false, false})
+		So(simulateNext(5, 2, 3), ShouldResemble, []bool{true, true, false})
+		So(simulateNext(0, 0, 2), ShouldResemble, []bool{false, false})
+		So(len(simulateNext(1, 1, 0)), ShouldEqual, 0)
+	})
+
+	Convey("test cap limit", t, func() {
+		limit, ok := capLimit(gredis.Limit{Rate: defaultRate * 2, Period: time.Second})
+		So(ok, ShouldBeTrue)
+		So(limit.Rate, ShouldEqual, defaultRate)
+		So(limit.Burst, ShouldEqual, defaultRate)
+
+		limit, ok = capLimit(gredis.Limit{Rate: 10, Burst: 20, Period: time.Minute})
+		So(ok, ShouldBeTrue)
+		So(limit.Rate, ShouldEqual, 10)
+		So(limit.Burst, ShouldEqual, 20)
+
+		_, ok = capLimit(gredis.Limit{Rate: 0, Period: time.Second})
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestExplain(t *testing.T) {
+	Convey("test matched rules", t, func() {
+		rctx, _ := test.NewReqCtx()
+		md := metadata.MDFromContext(rctx)
+		f := flagsV2{rules: []limitRule{{Limit: gredis.Limit{Rate: 10}}, {}}}
+		So(len(matchedRules(rctx, md, f)), ShouldEqual, 1)
+	})
+
+	Convey("test explain v2", t, func() {
+		limit := gredis.Limit{Rate: 3, Burst: 3, Period: time.Second}
+		lm := &rateLimiterV2{}
+		lm.flags.rules = []limitRule{
+			{Limit: limit, Step: 1},
+			{Limit: limit, Step: 1, LimitType: limitConcurrency},
+		}
+		p := gomonkey.ApplyPrivateMethod(reflect.TypeOf(lm), "loadQuota", func(ctx *types.Ctx, md *metadata.Metadata, dataProvider string, rule limitRule) (gredis.Limit, string, error) {
+			return limit, "key", nil
+		})
+		defer p.Reset()
+		p.ApplyFunc(redis.AllowN, func(ctx context.Context, l *gredis.Limiter, key string, limit gredis.Limit, n int) (*gredis.Result, error) {
+			So(n, ShouldEqual, 0)
+			return &gredis.Result{Limit: limit, Remaining: 2}, nil
+		})
+		p.ApplyFunc(redis.PeekConcurrency, func(ctx context.Context, key string) (int, error) {
+			return 3, nil
+		})
+
+		rctx, _ := test.NewReqCtx()
+		metadata.MDFromContext(rctx).UID = 1
+		res, ok := lm.Explain(rctx, 3).([]*RuleExplain)
+		So(ok, ShouldBeTrue)
+		So(len(res), ShouldEqual, 2)
+		So(res[0].Type, ShouldEqual, limitGCRA)
+		So(res[0].Key, ShouldEqual, "key")
+		So(res[0].Rate, ShouldEqual, 3)
+		So(res[0].Remaining, ShouldEqual, 2)
+		So(res[0].Next, ShouldResemble, []bool{true, true, false})
+		So(res[1].Type, ShouldEqual, limitConcurrency)
+		So(res[1].Remaining, ShouldEqual, 0)
+		So(res[1].Next, ShouldResemble, []bool{false, false, false})
+	})
+}
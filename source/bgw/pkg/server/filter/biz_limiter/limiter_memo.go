@@ -130,18 +130,11 @@ func (l *limiterMemo) Limit(ctx *types.Ctx, md *metadata.Metadata, dataProvider,
 		return err
 	}
 
-	ratio := int(limit.Period / time.Second)
-	defaultValue := defaultRate * ratio
-	if limit.Rate > defaultValue {
-		limit.Rate = defaultValue
-	}
-	if limit.Rate <= 0 {
+	limit, ok := capLimit(limit)
+	if !ok {
 		err = berror.NewInterErr("redis_limiter_v2 rate is 0", dataProvider, limitType)
 		return
 	}
-	if limit.Burst <= 0 {
-		limit.Burst = limit.Rate
-	}
 	glog.Debug(ctx, "redis key", glog.String("key", key), glog.Any("rule-limit", rule))
 
 	l.mutex.RLock()
@@ -197,7 +190,7 @@ func (l *limiterMemo) Init(ctx context.Context, args ...string) (err error) {
 		return berror.NewInterErr("invalid args, can't must filter")
 	}
 
-	if err = l.initRules(ctx, args...); err != nil {
+	if err = l.initRules(ctx, args...); err != nil || filter.IsDryRun(ctx) {
 		return
 	}
 
@@ -218,6 +211,9 @@ func (l *limiterMemo) initRules(ctx context.Context, args ...string) (err error)
 	if err = l.parseFlags(args); err != nil {
 		return
 	}
+	if filter.IsDryRun(ctx) {
+		return
+	}
 
 	isFutures := l.flags.dataProvider == futuresService
 	isOption := l.flags.dataProvider == optionService
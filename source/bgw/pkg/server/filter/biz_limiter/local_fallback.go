@@ -0,0 +1,218 @@
+package biz_limiter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/galert"
+	"code.bydev.io/fbu/gateway/gway.git/gcore/env"
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"code.bydev.io/fbu/gateway/gway.git/gmetric"
+	"code.bydev.io/fbu/gateway/gway.git/gredis"
+
+	"bgw/pkg/common"
+	"bgw/pkg/common/constant"
+	"bgw/pkg/config"
+	"bgw/pkg/discovery"
+	"bgw/pkg/registry/nacos"
+	"bgw/pkg/server/filter/biz_limiter/rate"
+)
+
+const (
+	defaultFailureThreshold = 3                      // consecutive redis failures before switching to local limiter
+	defaultSlowThreshold    = 100 * time.Millisecond // redis call slower than this is counted as failure
+	fallbackProbeInterval   = time.Second            // interval of probing redis while degraded
+	fallbackMaxKeys         = 100000                 // reset local limiters when too many keys
+	fallbackAlertTitle      = "redis limiter fallback"
+)
+
+// fallbackSlowThreshold configured by LimiterFallback.SlowThreshold of app config
+func fallbackSlowThreshold() time.Duration {
+	if ms := config.Global.LimiterFallback.SlowThreshold; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return defaultSlowThreshold
+}
+
+// fallbackFailureThreshold configured by LimiterFallback.FailureThreshold of app config
+func fallbackFailureThreshold() int32 {
+	if n := config.Global.LimiterFallback.FailureThreshold; n > 0 {
+		return int32(n)
+	}
+	return defaultFailureThreshold
+}
+
+var gLocalFallback = newLocalFallback()
+
+type localLimiter struct {
+	limiter *rate.Limiter
+	rate    int
+	pods    int
+}
+
+// localFallback switches rate limiting to per-pod in-memory token buckets when redis is unavailable,
+// the quota of each key is divided by the count of gateway pods, and switches back when redis recovers.
+type localFallback struct {
+	degraded  atomic.Bool
+	failures  atomic.Int32
+	lastProbe atomic.Int64
+	pods      gatewayPods
+
+	mutex    sync.RWMutex
+	limiters map[string]*localLimiter
+}
+
+func newLocalFallback() *localFallback {
+	return &localFallback{
+		limiters: make(map[string]*localLimiter),
+	}
+}
+
+// Degraded reports whether local limiter is in use
+func (f *localFallback) Degraded() bool {
+	return f.degraded.Load()
+}
+
+// tryProbe only one request per probe interval is sent to redis while degraded
+func (f *localFallback) tryProbe() bool {
+	now := time.Now().UnixNano()
+	last := f.lastProbe.Load()
+	if now-last < int64(fallbackProbeInterval) {
+		return false
+	}
+	return f.lastProbe.CompareAndSwap(last, now)
+}
+
+// onResult records the result of a redis call
+func (f *localFallback) onResult(ctx context.Context, cost time.Duration, err error) {
+	if err == nil && cost < fallbackSlowThreshold() {
+		f.failures.Store(0)
+		if f.degraded.CompareAndSwap(true, false) {
+			f.reset()
+			gmetric.IncDefaultCounter("redis_limit_v2", "fallback_off")
+			glog.Info(ctx, "redis limiter recovered, switch back to redis")
+			galert.Info(ctx, "redis limiter recovered, switch back to redis", galert.WithTitle(fallbackAlertTitle))
+		}
+		return
+	}
+
+	if err == nil {
+		gmetric.IncDefaultError("redis_limit_v2", "slow")
+	}
+	if f.failures.Add(1) < fallbackFailureThreshold() {
+		return
+	}
+	if f.degraded.CompareAndSwap(false, true) {
+		f.lastProbe.Store(time.Now().UnixNano())
+		gmetric.IncDefaultCounter("redis_limit_v2", "fallback_on")
+		reason := fmt.Sprintf("slow, cost=%v", cost)
+		if err != nil {
+			reason = err.Error()
+		}
+		msg := fmt.Sprintf("redis limiter unavailable, switch to local limiter, pods=%d, reason=%s", f.pods.count(), reason)
+		glog.Error(ctx, msg)
+		galert.Error(ctx, msg, galert.WithTitle(fallbackAlertTitle))
+	}
+}
+
+// Allow limits the key with local token bucket, rate and burst are divided by pod count
+func (f *localFallback) Allow(key string, limit gredis.Limit, n int) (remain int, allowed bool) {
+	pods := f.pods.count()
+
+	f.mutex.RLock()
+	l, ok := f.limiters[key]
+	f.mutex.RUnlock()
+	if !ok || l.rate != limit.Rate || l.pods != pods {
+		f.mutex.Lock()
+		l, ok = f.limiters[key]
+		if !ok || l.rate != limit.Rate || l.pods != pods {
+			if len(f.limiters) >= fallbackMaxKeys {
+				f.limiters = make(map[string]*localLimiter)
+			}
+			l = newLocalLimiter(limit, pods)
+			f.limiters[key] = l
+		}
+		f.mutex.Unlock()
+	}
+
+	gmetric.IncDefaultCounter("redis_limit_v2", "fallback_limit")
+	if n <= 1 {
+		return l.limiter.AllowAvailable()
+	}
+	return 0, l.limiter.AllowN(time.Now(), n)
+}
+
+func (f *localFallback) reset() {
+	f.mutex.Lock()
+	f.limiters = make(map[string]*localLimiter)
+	f.mutex.Unlock()
+}
+
+func newLocalLimiter(limit gredis.Limit, pods int) *localLimiter {
+	period := limit.Period
+	if period <= 0 {
+		period = time.Second
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Rate
+	}
+	r := float64(limit.Rate) / period.Seconds() / float64(pods)
+	b := int(math.Ceil(float64(burst) / float64(pods)))
+	if b < 1 {
+		b = 1
+	}
+	return &localLimiter{
+		limiter: rate.NewLimiter(rate.Limit(r), b),
+		rate:    limit.Rate,
+		pods:    pods,
+	}
+}
+
+// gatewayPods count of gateway pods registered in nacos
+type gatewayPods struct {
+	once      sync.Once
+	discovery discovery.ServiceRegistryModule
+	url       *common.URL
+}
+
+func (p *gatewayPods) init(ctx context.Context) {
+	p.once.Do(func() {
+		cluster := config.GetHTTPServerConfig().ServiceRegistry.ServiceName
+		if cluster == "" {
+			cluster = env.ProjectEnvName()
+		}
+		url, err := common.NewURL("bgw-"+cluster,
+			common.WithProtocol(constant.NacosProtocol),
+			common.WithNamespace(config.GetRegistryNamespace()),
+			common.WithGroup(nacos.RegisterGroup),
+		)
+		if err != nil {
+			glog.Error(ctx, "redis limiter fallback build url error", glog.String("error", err.Error()))
+			return
+		}
+
+		d := discovery.NewServiceRegistry(ctx)
+		if err = d.Watch(ctx, url); err != nil {
+			glog.Error(ctx, "redis limiter fallback watch pods error", glog.String("error", err.Error()))
+			return
+		}
+		p.url = url
+		p.discovery = d
+	})
+}
+
+// count returns 1 if pods are unknown
+func (p *gatewayPods) count() int {
+	if p.discovery == nil || p.url == nil {
+		return 1
+	}
+	if n := len(p.discovery.GetInstances(p.url)); n > 0 {
+		return n
+	}
+	return 1
+}
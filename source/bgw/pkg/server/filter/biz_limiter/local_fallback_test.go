@@ -0,0 +1,87 @@
+package biz_limiter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/galert"
+	"code.bydev.io/fbu/gateway/gway.git/gredis"
+	"github.com/agiledragon/gomonkey/v2"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"bgw/pkg/config"
+)
+
+func TestLocalFallback(t *testing.T) {
+	Convey("test local fallback", t, func() {
+		patchErr := gomonkey.ApplyFunc(galert.Error, func(ctx context.Context, message string, opts ...galert.Option) {})
+		defer patchErr.Reset()
+		patchInfo := gomonkey.ApplyFunc(galert.Info, func(ctx context.Context, message string, opts ...galert.Option) {})
+		defer patchInfo.Reset()
+
+		ctx := context.Background()
+		f := newLocalFallback()
+		mockErr := errors.New("mock")
+
+		// degrade after consecutive failures
+		f.onResult(ctx, time.Millisecond, mockErr)
+		f.onResult(ctx, time.Millisecond, mockErr)
+		So(f.Degraded(), ShouldBeFalse)
+		f.onResult(ctx, time.Millisecond, nil)
+		f.onResult(ctx, time.Millisecond, mockErr)
+		f.onResult(ctx, time.Millisecond, mockErr)
+		So(f.Degraded(), ShouldBeFalse)
+		f.onResult(ctx, fallbackSlowThreshold(), nil)
+		So(f.Degraded(), ShouldBeTrue)
+
+		// probe once per interval
+		So(f.tryProbe(), ShouldBeFalse)
+		f.lastProbe.Store(time.Now().Add(-fallbackProbeInterval).UnixNano())
+		So(f.tryProbe(), ShouldBeTrue)
+		So(f.tryProbe(), ShouldBeFalse)
+
+		// local limiter
+		limit := gredis.Limit{Rate: 2, Burst: 2, Period: time.Second}
+		_, ok := f.Allow("key", limit, 1)
+		So(ok, ShouldBeTrue)
+		_, ok = f.Allow("key", limit, 1)
+		So(ok, ShouldBeTrue)
+		_, ok = f.Allow("key", limit, 1)
+		So(ok, ShouldBeFalse)
+		_, ok = f.Allow("key2", limit, 3)
+		So(ok, ShouldBeFalse)
+
+		// recover
+		f.onResult(ctx, time.Millisecond, nil)
+		So(f.Degraded(), ShouldBeFalse)
+		So(len(f.limiters), ShouldEqual, 0)
+	})
+
+	Convey("test configured thresholds", t, func() {
+		old := config.Global.LimiterFallback
+		defer func() { config.Global.LimiterFallback = old }()
+
+		config.Global.LimiterFallback = config.LimiterFallback{}
+		So(fallbackSlowThreshold(), ShouldEqual, defaultSlowThreshold)
+		So(fallbackFailureThreshold(), ShouldEqual, defaultFailureThreshold)
+
+		patch := gomonkey.ApplyFunc(galert.Error, func(ctx context.Context, message string, opts ...galert.Option) {})
+		defer patch.Reset()
+		config.Global.LimiterFallback = config.LimiterFallback{SlowThreshold: 50, FailureThreshold: 1}
+		So(fallbackSlowThreshold(), ShouldEqual, 50*time.Millisecond)
+		f := newLocalFallback()
+		f.onResult(context.Background(), 50*time.Millisecond, nil)
+		So(f.Degraded(), ShouldBeTrue)
+	})
+
+	Convey("test local limiter scaled by pods", t, func() {
+		l := newLocalLimiter(gredis.Limit{Rate: 100, Period: 10 * time.Second}, 4)
+		So(float64(l.limiter.Limit()), ShouldEqual, 2.5)
+		So(l.limiter.Burst(), ShouldEqual, 25)
+
+		l = newLocalLimiter(gredis.Limit{Rate: 1, Burst: 1, Period: time.Second}, 4)
+		So(l.limiter.Burst(), ShouldEqual, 1)
+	})
+}
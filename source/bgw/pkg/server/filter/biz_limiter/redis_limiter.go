@@ -33,6 +33,7 @@ type flags struct {
 	hasSymbol         bool
 	disableCustomRate bool
 	unified           bool
+	limitType         string // gcra,sliding_log,sliding_window,concurrency
 	gredis.Limit
 }
 
@@ -65,6 +66,7 @@ func (r *rateLimiter) Do(next types.Handler) types.Handler {
 			md    = metadata.MDFromContext(ctx)
 			route = md.GetRoute()
 		)
+		defer releaseConcurrency(ctx)
 
 		if !route.Valid() {
 			return berror.ErrRouteKeyInvalid
@@ -90,6 +92,23 @@ func (r *rateLimiter) Do(next types.Handler) types.Handler {
 func (r *rateLimiter) Limit(c *types.Ctx, route metadata.RouteKey, memberID int64, platform string) (err error) {
 	var now = time.Now()
 
+	limit, redisKey, err := r.resolveLimit(c, route, memberID)
+	if err != nil {
+		return
+	}
+
+	if err = r.doLimit(c, redisKey, limit, platform); err != nil {
+		return
+	}
+
+	glog.Debug(c, "redis_limiter allowed", glog.Duration("cost", time.Since(now)), glog.Bool("rule.hasSymbol", r.flags.hasSymbol),
+		glog.String("redis key", redisKey), glog.Any("route", route))
+
+	return
+}
+
+// resolveLimit get the effective limit and redis key of member
+func (r *rateLimiter) resolveLimit(c *types.Ctx, route metadata.RouteKey, memberID int64) (gredis.Limit, string, error) {
 	// get default limit
 	limit := r.flags.Limit
 
@@ -97,7 +116,7 @@ func (r *rateLimiter) Limit(c *types.Ctx, route metadata.RouteKey, memberID int6
 	// !NOTE: get quota from loaders only when group is not empty, if rate is zero, use user's quota
 	if r.flags.group != "" {
 		if memberID <= 0 {
-			return berror.NewInterErr("redis limiter memberID is 0, group mode")
+			return limit, "", berror.NewInterErr("redis limiter memberID is 0, group mode")
 		}
 
 		appName := route.GetAppName(c)
@@ -109,8 +128,7 @@ func (r *rateLimiter) Limit(c *types.Ctx, route metadata.RouteKey, memberID int6
 				rate = r.getQuota(c, appName, memberID, r.flags.group)
 			}
 			if limit.Rate <= 0 && rate <= 0 {
-				err = berror.NewInterErr("redis limiter group rate is 0")
-				return
+				return limit, "", berror.NewInterErr("redis limiter group rate is 0")
 			}
 			// use user's quota first
 			if rate > 0 {
@@ -141,21 +159,12 @@ func (r *rateLimiter) Limit(c *types.Ctx, route metadata.RouteKey, memberID int6
 		limit.Rate = defaultRate
 	}
 	if limit.Rate <= 0 {
-		err = berror.NewInterErr("redis group limiter rate is 0")
-		return
+		return limit, "", berror.NewInterErr("redis group limiter rate is 0")
 	}
 	if limit.Burst <= 0 {
 		limit.Burst = limit.Rate
 	}
-
-	if err = r.doLimit(c, redisKey, limit, platform); err != nil {
-		return
-	}
-
-	glog.Debug(c, "redis_limiter allowed", glog.Duration("cost", time.Since(now)), glog.Bool("rule.hasSymbol", r.flags.hasSymbol),
-		glog.String("redis key", redisKey), glog.Any("route", route))
-
-	return
+	return limit, redisKey, nil
 }
 
 func (r *rateLimiter) blockTradeLimit(ctx *types.Ctx, route metadata.RouteKey, md *metadata.Metadata) error {
@@ -197,6 +206,9 @@ func (r *rateLimiter) Init(ctx context.Context, args ...string) error {
 	if err := r.parseFlags(args); err != nil {
 		return err
 	}
+	if filter.IsDryRun(ctx) {
+		return nil
+	}
 
 	if !r.flags.disableCustomRate {
 		if !routeKey.AllApp {
@@ -213,12 +225,26 @@ func (r *rateLimiter) doLimit(ctx *types.Ctx, key string, limit gredis.Limit, pl
 		gmetric.ObserveDefaultLatencySince(now, "rate", "limit")
 	}()
 
-	result, err := redis.AllowN(service.GetContext(ctx), r.limiter, key, limit, 1)
-	if err != nil {
-		// if redis internal error, not block biz request
-		glog.Error(ctx, "redis.AllowN error", glog.String("key", key), glog.Any("limit", limit), glog.String("err", err.Error()))
-		gmetric.IncDefaultError("redis_limit_v1", "allown_error")
-		return nil
+	var (
+		result *gredis.Result
+		err    error
+	)
+	if isWindowType(r.flags.limitType) {
+		result, err = allowWindow(ctx, key, r.flags.limitType, 1, limit)
+		if err != nil {
+			// if redis internal error, not block biz request
+			glog.Error(ctx, "redis limit error", glog.String("type", r.flags.limitType), glog.String("key", key), glog.Any("limit", limit), glog.String("err", err.Error()))
+			gmetric.IncDefaultError("redis_limit_v1", r.flags.limitType+"_error")
+			return nil
+		}
+	} else {
+		result, err = redis.AllowN(service.GetContext(ctx), r.limiter, key, limit, 1)
+		if err != nil {
+			// if redis internal error, not block biz request
+			glog.Error(ctx, "redis.AllowN error", glog.String("key", key), glog.Any("limit", limit), glog.String("err", err.Error()))
+			gmetric.IncDefaultError("redis_limit_v1", "allown_error")
+			return nil
+		}
 	}
 
 	setHeader(ctx, limit.Rate, result, platform == string(bplatform.OpenAPI))
@@ -291,10 +317,15 @@ func (r *rateLimiter) parseFlags(args []string) (err error) {
 	parse.BoolVar(&f.hasSymbol, "hasSymbol", false, "rate limit use symbol or not")
 	parse.BoolVar(&f.disableCustomRate, "disableCustomRate", false, "rate limit disable get custom rate from etcd")
 	parse.BoolVar(&f.unified, "unified", false, "unified limit")
+	parse.StringVar(&f.limitType, "type", "", "rate limit type, gcra, sliding_log, sliding_window or concurrency")
 	if err = parse.Parse(args[1:]); err != nil {
 		return
 	}
 
+	if f.limitType != "" && f.limitType != limitGCRA && !isWindowType(f.limitType) {
+		return berror.NewInterErr("biz limit type is invalid", f.limitType)
+	}
+
 	// group must default rate
 	if f.group != "" && f.Rate <= 0 {
 		return berror.NewInterErr("biz limit group is nil, but not default rate")
@@ -35,10 +35,11 @@ const (
 type flagsV2 struct {
 	rules        []limitRule // multiple limit rules
 	dataProvider string      // rules data provider
-	limitType    string      // counter,bucket
+	limitType    string      // counter,gcra,sliding_log,sliding_window,concurrency
 	unified      bool        // unified account
 	selectType   string      // one, all
 	batch        bool        // batch
+	hashTag      bool        // keys of uid rules are tagged by {uid}, so gcra rules of a user are batched in redis cluster
 }
 
 type limitRule struct {
@@ -49,7 +50,7 @@ type limitRule struct {
 	Step             int    `json:"step"`               // increase step
 	EnableCustomRate bool   `json:"enable_custom_rate"` // related to data provider, otherwise use default rate
 	DataProvider     string `json:"data"`               // data provider, default is futures
-	LimitType        string `json:"type"`               // counter,bucket
+	LimitType        string `json:"type"`               // counter,gcra,sliding_log,sliding_window,concurrency
 	Cap              int    `json:"cap"`                // upper limit of quota
 }
 
@@ -81,6 +82,7 @@ func (r *rateLimiterV2) Do(next types.Handler) types.Handler {
 			err error
 			md  = metadata.MDFromContext(ctx)
 		)
+		defer releaseConcurrency(ctx)
 
 		if r.flags.selectType == selectTypeOne {
 			if err = r.limitOne(ctx, md); err != nil {
@@ -91,25 +93,8 @@ func (r *rateLimiterV2) Do(next types.Handler) types.Handler {
 		}
 
 		// As soon as one current limiterV2 gets rejected, return
-		for _, rule := range r.flags.rules {
-			if rule.IsZero() {
-				continue
-			}
-
-			dataProvider := r.flags.dataProvider
-			if rule.DataProvider != "" {
-				dataProvider = rule.DataProvider
-			}
-
-			limitType := r.flags.limitType
-			if rule.LimitType != "" {
-				limitType = rule.LimitType
-			}
-
-			err = r.Limit(ctx, md, dataProvider, limitType, rule)
-			if err != nil {
-				return err
-			}
+		if err = r.limitRules(ctx, md, r.flags.rules); err != nil {
+			return err
 		}
 
 		return next(ctx)
@@ -134,42 +119,80 @@ func (r *rateLimiterV2) limitOne(ctx *types.Ctx, md *metadata.Metadata) (err err
 		return nil
 	}
 
-	dataProvider := r.flags.dataProvider
+	return r.limitRules(ctx, md, []limitRule{rule})
+}
+
+// ruleOptions data provider and limit type of rule, default is the filter's
+func (r *rateLimiterV2) ruleOptions(rule limitRule) (dataProvider, limitType string) {
+	dataProvider = r.flags.dataProvider
 	if rule.DataProvider != "" {
 		dataProvider = rule.DataProvider
 	}
 
-	limitType := r.flags.limitType
+	limitType = r.flags.limitType
 	if rule.LimitType != "" {
 		limitType = rule.LimitType
 	}
-	return r.Limit(ctx, md, dataProvider, limitType, rule)
+	return
 }
 
-// Limit gen rate and burst and redis-key
-func (r *rateLimiterV2) Limit(ctx *types.Ctx, md *metadata.Metadata, dataProvider, limitType string, rule limitRule) (err error) {
-	now := time.Now()
+// limitRules limit rules in the configured order and return as soon as one rule is rejected,
+// adjacent gcra rules are batched and evaluated by AllowMulti
+func (r *rateLimiterV2) limitRules(ctx *types.Ctx, md *metadata.Metadata, rules []limitRule) error {
+	gcras := make([]limitRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.IsZero() {
+			continue
+		}
 
-	if rule.UID && md.UID <= 0 {
-		return berror.NewInterErr("redis_limiter_v2 uid is 0")
-	}
+		dataProvider, limitType := r.ruleOptions(rule)
+		if limitType == "" || limitType == limitGCRA {
+			gcras = append(gcras, rule)
+			continue
+		}
 
-	limit, key, err := r.loadQuota(ctx, md, dataProvider, rule)
-	if err != nil {
-		return err
+		if err := r.limitGCRAs(ctx, md, gcras); err != nil {
+			return err
+		}
+		gcras = gcras[:0]
+
+		if err := r.Limit(ctx, md, dataProvider, limitType, rule); err != nil {
+			return err
+		}
 	}
 
-	ratio := int(limit.Period / time.Second)
-	defaultValue := defaultRate * ratio
-	if limit.Rate > defaultValue {
-		limit.Rate = defaultValue
+	return r.limitGCRAs(ctx, md, gcras)
+}
+
+// limitGCRAs limit adjacent gcra rules, a single rule is limited by Limit
+func (r *rateLimiterV2) limitGCRAs(ctx *types.Ctx, md *metadata.Metadata, rules []limitRule) error {
+	switch len(rules) {
+	case 0:
+		return nil
+	case 1:
+		dataProvider, limitType := r.ruleOptions(rules[0])
+		return r.Limit(ctx, md, dataProvider, limitType, rules[0])
 	}
-	if limit.Rate <= 0 {
-		err = berror.NewInterErr("redis_limiter_v2 rate is 0", dataProvider, limitType)
-		return
+
+	limits := make([]gredis.MultiLimit, 0, len(rules))
+	for _, rule := range rules {
+		dataProvider, limitType := r.ruleOptions(rule)
+		limit, key, err := r.resolveLimit(ctx, md, dataProvider, limitType, rule)
+		if err != nil {
+			return err
+		}
+		limits = append(limits, gredis.MultiLimit{Key: key, Limit: limit, N: rule.Step})
 	}
-	if limit.Burst <= 0 {
-		limit.Burst = limit.Rate
+	return r.doLimitMulti(ctx, limits, bplatform.Client(md.Extension.Platform) == bplatform.OpenAPI)
+}
+
+// Limit gen rate and burst and redis-key
+func (r *rateLimiterV2) Limit(ctx *types.Ctx, md *metadata.Metadata, dataProvider, limitType string, rule limitRule) (err error) {
+	now := time.Now()
+
+	limit, key, err := r.resolveLimit(ctx, md, dataProvider, limitType, rule)
+	if err != nil {
+		return err
 	}
 	glog.Debug(ctx, "redis key", glog.String("key", key), glog.Any("rule-limit", rule))
 
@@ -181,6 +204,27 @@ func (r *rateLimiterV2) Limit(ctx *types.Ctx, md *metadata.Metadata, dataProvide
 	return
 }
 
+// resolveLimit get the effective limit and redis key of rule
+func (r *rateLimiterV2) resolveLimit(ctx *types.Ctx, md *metadata.Metadata, dataProvider, limitType string, rule limitRule) (gredis.Limit, string, error) {
+	if rule.UID && md.UID <= 0 {
+		return gredis.Limit{}, "", berror.NewInterErr("redis_limiter_v2 uid is 0")
+	}
+
+	limit, key, err := r.loadQuota(ctx, md, dataProvider, rule)
+	if err != nil {
+		return gredis.Limit{}, "", err
+	}
+	if r.flags.hashTag && (rule.UID || r.flags.unified) && md.UID > 0 {
+		key = uidHashTag(md.UID) + key
+	}
+
+	limit, ok := capLimit(limit)
+	if !ok {
+		return gredis.Limit{}, "", berror.NewInterErr("redis_limiter_v2 rate is 0", dataProvider, limitType)
+	}
+	return limit, key, nil
+}
+
 func (r *rateLimiterV2) loadQuota(ctx *types.Ctx, md *metadata.Metadata, dataProvider string, rule limitRule) (gredis.Limit, string, error) {
 	appName := md.GetRoute().GetAppName(ctx)
 	if md.Route.AllApp {
@@ -278,10 +322,12 @@ func (r *rateLimiterV2) Init(ctx context.Context, args ...string) (err error) {
 		return berror.NewInterErr("invalid args, can't must filter")
 	}
 
-	if err = r.initRules(ctx, args...); err != nil {
+	if err = r.initRules(ctx, args...); err != nil || filter.IsDryRun(ctx) {
 		return
 	}
 
+	gLocalFallback.pods.init(ctx)
+
 	var enableCustomRate bool
 	for _, rule := range r.flags.rules {
 		if rule.EnableCustomRate {
@@ -301,24 +347,46 @@ func (r *rateLimiterV2) doLimit(ctx *types.Ctx, key, limitType string, step int,
 		gmetric.ObserveDefaultLatencySince(now, "rate_v2", "limit")
 	}()
 
+	// redis is unavailable, use local limiter except probe request
+	if gLocalFallback.Degraded() && !gLocalFallback.tryProbe() {
+		if limitType == limitConcurrency {
+			return nil
+		}
+		return r.doLocalLimit(ctx, key, step, limit, withHeader)
+	}
+
 	var result *gredis.Result
 
 	switch limitType {
 	case limitCounter:
 		result, err = redis.AllowM(service.GetContext(ctx), r.limiter, key, limit, step)
+		gLocalFallback.onResult(ctx, time.Since(now), err)
 		if err != nil {
 			// !!NOTE ignore redis internal error, not block biz request
 			glog.Error(ctx, "redis.AllowM error", glog.String("key", key), glog.Any("limit", limit), glog.String("err", err.Error()))
 			gmetric.IncDefaultError("redis_limit_v2", "allowm_error")
-			return nil
+			return r.onRedisError(ctx, key, step, limit, withHeader)
+		}
+	case limitSlidingLog, limitSlidingWindow, limitConcurrency:
+		result, err = allowWindow(ctx, key, limitType, step, limit)
+		gLocalFallback.onResult(ctx, time.Since(now), err)
+		if err != nil {
+			// !!NOTE ignore redis internal error, not block biz request
+			glog.Error(ctx, "redis limit error", glog.String("type", limitType), glog.String("key", key), glog.Any("limit", limit), glog.String("err", err.Error()))
+			gmetric.IncDefaultError("redis_limit_v2", limitType+"_error")
+			if limitType == limitConcurrency {
+				return nil
+			}
+			return r.onRedisError(ctx, key, step, limit, withHeader)
 		}
 	default:
 		result, err = redis.AllowN(service.GetContext(ctx), r.limiter, key, limit, step)
+		gLocalFallback.onResult(ctx, time.Since(now), err)
 		if err != nil {
 			// !!NOTE ignore redis internal error, not block biz request
 			glog.Error(ctx, "redis.AllowN error", glog.String("key", key), glog.Any("limit", limit), glog.String("err", err.Error()))
 			gmetric.IncDefaultError("redis_limit_v2", "allown_error")
-			return nil
+			return r.onRedisError(ctx, key, step, limit, withHeader)
 		}
 	}
 
@@ -334,6 +402,103 @@ func (r *rateLimiterV2) doLimit(ctx *types.Ctx, key, limitType string, step int,
 	return
 }
 
+// uidHashTag redis cluster hash tag of user, keys of one user with the tag are in the same hash slot
+func uidHashTag(uid int64) string {
+	return "{" + cast.Int64toa(uid) + "}:"
+}
+
+// doLimitMulti limit gcra rules, adjacent rules in the same redis hash slot are evaluated atomically by one AllowMulti,
+// header is set for every counted rule in order as doLimit, so the rejected or the last rule is in header
+func (r *rateLimiterV2) doLimitMulti(ctx *types.Ctx, limits []gredis.MultiLimit, withHeader bool) error {
+	now := time.Now()
+	defer func() {
+		gmetric.ObserveDefaultLatencySince(now, "rate_v2", "limit_multi")
+	}()
+
+	if gLocalFallback.Degraded() && !gLocalFallback.tryProbe() {
+		for _, l := range limits {
+			if err := r.doLocalLimit(ctx, l.Key, l.N, l.Limit, withHeader); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, group := range groupBySlot(limits) {
+		if len(group) == 1 {
+			if err := r.doLimit(ctx, group[0].Key, limitGCRA, group[0].N, group[0].Limit, withHeader); err != nil {
+				return err
+			}
+			continue
+		}
+
+		start := time.Now()
+		results, err := redis.AllowMulti(service.GetContext(ctx), r.limiter, group)
+		gLocalFallback.onResult(ctx, time.Since(start), err)
+		if err != nil {
+			// !!NOTE ignore redis internal error, not block biz request
+			gmetric.IncDefaultError("redis_limit_v2", "allow_multi_error")
+			for _, l := range group {
+				if err = r.onRedisError(ctx, l.Key, l.N, l.Limit, withHeader); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		// rules passed but not counted because another rule is rejected have Allowed 0 and RetryAfter -1,
+		// the rejected rule has RetryAfter >= 0
+		for i, result := range results {
+			if result.Allowed == 0 && result.RetryAfter < 0 {
+				continue
+			}
+			setHeader(ctx, group[i].Limit.Rate, result, withHeader)
+			if result.Allowed == 0 {
+				glog.Debug(ctx, "redis_limiter_v2 blocked", glog.Duration("cost", time.Since(now)), glog.String("route", group[i].Key),
+					glog.Any("limit", group[i].Limit), glog.Any("limit-result", result))
+				return berror.ErrVisitsLimit
+			}
+		}
+	}
+	return nil
+}
+
+// groupBySlot group adjacent limits by redis cluster hash slot of key, so limits are still evaluated in order
+func groupBySlot(limits []gredis.MultiLimit) [][]gredis.MultiLimit {
+	groups := make([][]gredis.MultiLimit, 0, len(limits))
+	last := -1
+	for _, l := range limits {
+		slot := gredis.KeySlot(l.Key)
+		if slot != last {
+			groups = append(groups, nil)
+			last = slot
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], l)
+	}
+	return groups
+}
+
+// onRedisError request is allowed until redis is marked as unavailable
+func (r *rateLimiterV2) onRedisError(ctx *types.Ctx, key string, step int, limit gredis.Limit, withHeader bool) error {
+	if !gLocalFallback.Degraded() {
+		return nil
+	}
+	return r.doLocalLimit(ctx, key, step, limit, withHeader)
+}
+
+// doLocalLimit limit with per-pod in-memory limiter while redis is unavailable
+func (r *rateLimiterV2) doLocalLimit(ctx *types.Ctx, key string, step int, limit gredis.Limit, withHeader bool) error {
+	remain, allowed := gLocalFallback.Allow(key, limit, step)
+	if !allowed {
+		setHeaderMemo(ctx, limit.Rate, 0, withHeader)
+		glog.Debug(ctx, "redis_limiter_v2 local blocked", glog.String("route", key), glog.Any("limit", limit))
+		return berror.ErrVisitsLimit
+	}
+
+	setHeaderMemo(ctx, limit.Rate, remain, withHeader)
+	return nil
+}
+
 // getQuota get limit rate value from service provider
 // return rate, resetSymbol, error
 func (r *rateLimiterV2) getQuota(ctx context.Context, app string, unified bool, params *rateParams) (int, error) {
@@ -414,13 +579,16 @@ type options struct {
 	EnableCustomRate bool   `json:"enable_custom_rate,omitempty" yaml:"enable_custom_rate,omitempty"` // 是否启用自定义提频
 	Group            string `json:"group,omitempty" yaml:"group,omitempty"`                           // group维度名称
 	DataProvider     string `json:"dataProvider,omitempty" yaml:"dataProvider,omitempty"`             // 限频数据源，futures，etcd，默认为网关etcd
-	LimitType        string `json:"limitType,omitempty" yaml:"limitType,omitempty"`                   // 限流方式，counter,bucket,默认为令牌桶
+	LimitType        string `json:"limitType,omitempty" yaml:"limitType,omitempty"`                   // 限流方式，counter,gcra,sliding_log,sliding_window,concurrency,默认为令牌桶
 }
 
 func (r *rateLimiterV2) initRules(ctx context.Context, args ...string) (err error) {
 	if err = r.parseFlags(args); err != nil {
 		return
 	}
+	if filter.IsDryRun(ctx) {
+		return
+	}
 
 	isFutures := r.flags.dataProvider == futuresService
 	isOption := r.flags.dataProvider == optionService
@@ -476,15 +644,22 @@ func (r *rateLimiterV2) parseFlags(args []string) (err error) {
 
 	parse.StringVar(&s, "rules", "[]", "rule json string")
 	parse.StringVar(&f.dataProvider, "data", "", "rate data provider")
-	parse.StringVar(&f.limitType, "type", "", "rate limit type, counter or default")
+	parse.StringVar(&f.limitType, "type", "", "rate limit type, counter, gcra, sliding_log, sliding_window, concurrency")
 	parse.BoolVar(&f.unified, "unified", false, "unified limit")
 	parse.StringVar(&f.selectType, "selectType", "all", "limit rule select type")
 	parse.StringVar(&optsT, "options", "[]", "limit options")
+	parse.BoolVar(&f.hashTag, "hashTag", false, "tag keys of uid rules by {uid}, quota of the route is reset once when turned on")
 
 	if err = parse.Parse(args[1:]); err != nil {
 		return
 	}
 
+	// unknown limit type falls back to token bucket
+	if !isLimitType(f.limitType) {
+		glog.Info(context.TODO(), "redis limiterV2: unknown limit type:"+f.limitType)
+		f.limitType = ""
+	}
+
 	if err = util.JsonUnmarshal([]byte(s), &rules); err != nil {
 		return
 	}
@@ -496,6 +671,10 @@ func (r *rateLimiterV2) parseFlags(args []string) (err error) {
 		if rules[i].Step <= 0 {
 			rules[i].Step = 1
 		}
+		if !isLimitType(rules[i].LimitType) {
+			glog.Info(context.TODO(), "redis limiterV2: unknown limit type:"+rules[i].LimitType)
+			rules[i].LimitType = ""
+		}
 		// default one second
 		if rules[i].PeriodSec <= 0 {
 			rules[i].Period = time.Second
@@ -537,7 +716,7 @@ func (r *rateLimiterV2) parseFlags(args []string) (err error) {
 			return fmt.Errorf("redis limiterV2: unknown data provider: %s", rule.DataProvider)
 		}
 		rule.LimitType = opt.LimitType
-		if rule.LimitType != "" && rule.LimitType != limitCounter {
+		if !isLimitType(rule.LimitType) {
 			glog.Info(context.TODO(), "redis limiterV2: unknown limit type:"+rule.LimitType)
 			rule.LimitType = ""
 		}
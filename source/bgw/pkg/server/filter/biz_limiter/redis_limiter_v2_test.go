@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +12,7 @@ import (
 	"code.bydev.io/fbu/gateway/gway.git/gmetric"
 
 	"bgw/pkg/common/berror"
+	"bgw/pkg/common/constant"
 	"bgw/pkg/remoting/redis"
 
 	"code.bydev.io/fbu/gateway/gway.git/gredis"
@@ -479,6 +481,145 @@ func TestRateLimiterV2_doLimit(t *testing.T) {
 	})
 }
 
+func TestRateLimiterV2_doLimitMulti(t *testing.T) {
+	Convey("test rateLimiterV2 doLimitMulti", t, func() {
+		metricsFunc := gomonkey.ApplyFunc(gmetric.ObserveDefaultLatencySince, func(t time.Time, typ, label string) {
+			return
+		})
+		defer metricsFunc.Reset()
+
+		IncDefaultError := gomonkey.ApplyFunc(gmetric.IncDefaultError, func(typ string, label string) {
+			return
+		})
+		defer IncDefaultError.Reset()
+
+		r := &rateLimiterV2{}
+		ctx, _ := test.NewReqCtx()
+		limits := []gredis.MultiLimit{
+			{Key: "{1}:a", Limit: gredis.PerSecond(5), N: 1},
+			{Key: "{1}:b", Limit: gredis.PerSecond(7), N: 1},
+			{Key: "{2}:c", Limit: gredis.PerSecond(1), N: 1},
+			{Key: "{1}:d", Limit: gredis.PerSecond(1), N: 1},
+		}
+
+		// only adjacent limits of the same slot are grouped, order is kept
+		groups := groupBySlot(limits)
+		So(len(groups), ShouldEqual, 3)
+		So(groups[0], ShouldResemble, []gredis.MultiLimit{limits[0], limits[1]})
+		So(groups[1], ShouldResemble, []gredis.MultiLimit{limits[2]})
+		So(groups[2], ShouldResemble, []gredis.MultiLimit{limits[3]})
+
+		var multiKeys, singleKeys []string
+		allowN := gomonkey.ApplyFunc(redis.AllowN, func(ctx context.Context, l *gredis.Limiter, key string, limit gredis.Limit, n int) (*gredis.Result, error) {
+			singleKeys = append(singleKeys, key)
+			return &gredis.Result{Allowed: 1}, nil
+		})
+		defer allowN.Reset()
+
+		allowMulti := gomonkey.ApplyFunc(redis.AllowMulti, func(ctx context.Context, l *gredis.Limiter, limits []gredis.MultiLimit) ([]*gredis.Result, error) {
+			for _, l := range limits {
+				multiKeys = append(multiKeys, l.Key)
+			}
+			return []*gredis.Result{{Allowed: 1}, {Allowed: 1}}, nil
+		})
+		err := r.doLimitMulti(ctx, limits, false)
+		So(err, ShouldBeNil)
+		So(multiKeys, ShouldResemble, []string{"{1}:a", "{1}:b"})
+		So(singleKeys, ShouldResemble, []string{"{2}:c", "{1}:d"})
+		allowMulti.Reset()
+
+		// the second rule is rejected, the first one passed but is not counted, header is of the rejected rule
+		allowMulti = gomonkey.ApplyFunc(redis.AllowMulti, func(ctx context.Context, l *gredis.Limiter, limits []gredis.MultiLimit) ([]*gredis.Result, error) {
+			return []*gredis.Result{
+				{Limit: limits[0].Limit, Allowed: 0, Remaining: 4, RetryAfter: -1},
+				{Limit: limits[1].Limit, Allowed: 0, Remaining: 0, RetryAfter: 100 * time.Millisecond},
+			}, nil
+		})
+		err = r.doLimitMulti(ctx, limits, true)
+		So(err, ShouldEqual, berror.ErrVisitsLimit)
+		So(string(ctx.Response.Header.Peek(constant.HeaderAPILimit)), ShouldEqual, "7")
+		So(string(ctx.Response.Header.Peek(constant.HeaderAPILimitStatus)), ShouldEqual, "0")
+		allowMulti.Reset()
+
+		// redis error, request is allowed
+		allowMulti = gomonkey.ApplyFunc(redis.AllowMulti, func(ctx context.Context, l *gredis.Limiter, limits []gredis.MultiLimit) ([]*gredis.Result, error) {
+			return nil, errors.New("mock")
+		})
+		err = r.doLimitMulti(ctx, limits, false)
+		So(err, ShouldBeNil)
+		allowMulti.Reset()
+	})
+}
+
+func TestRateLimiterV2_limitRules(t *testing.T) {
+	Convey("test rateLimiterV2 limitRules keeps rule order", t, func() {
+		lm := &rateLimiterV2{}
+		rctx, md := test.NewReqCtx()
+
+		var calls []string
+		p := gomonkey.ApplyPrivateMethod(reflect.TypeOf(lm), "resolveLimit", func(ctx *types.Ctx, md *metadata.Metadata, dataProvider, limitType string, rule limitRule) (gredis.Limit, string, error) {
+			return rule.Limit, rule.Category, nil
+		})
+		defer p.Reset()
+		p.ApplyPrivateMethod(reflect.TypeOf(lm), "doLimitMulti", func(ctx *types.Ctx, limits []gredis.MultiLimit, withHeader bool) error {
+			keys := make([]string, 0, len(limits))
+			for _, l := range limits {
+				keys = append(keys, l.Key)
+			}
+			calls = append(calls, "multi:"+strings.Join(keys, ","))
+			return nil
+		})
+		p.ApplyFunc((*rateLimiterV2).Limit, func(r *rateLimiterV2, ctx *types.Ctx, md *metadata.Metadata, dataProvider, limitType string, rule limitRule) (err error) {
+			calls = append(calls, "one:"+rule.Category)
+			return nil
+		})
+
+		limit := gredis.PerSecond(1)
+		rules := []limitRule{
+			{Limit: limit, Category: "a"},
+			{Limit: limit, Category: "b", LimitType: limitGCRA},
+			{Limit: limit, Category: "c", LimitType: limitConcurrency},
+			{Limit: limit, Category: "d"},
+			{Limit: limit, Category: "e", LimitType: limitCounter},
+		}
+		So(lm.limitRules(rctx, md, rules), ShouldBeNil)
+		So(calls, ShouldResemble, []string{"multi:a,b", "one:c", "one:d", "one:e"})
+	})
+}
+
+func TestRateLimiterV2_hashTag(t *testing.T) {
+	Convey("test rateLimiterV2 keys of uid rules are in the same slot", t, func() {
+		lm := &rateLimiterV2{}
+		rctx, md := test.NewReqCtx()
+		md.UID = 100
+
+		limit := gredis.PerSecond(10)
+		pathRule := limitRule{Limit: limit, extractor: extractor{UID: true, Path: true}}
+		groupRule := limitRule{Limit: limit, extractor: extractor{UID: true, Group: "order"}}
+		appRule := limitRule{Limit: limit, extractor: extractor{Group: "order"}}
+
+		_, key, err := lm.resolveLimit(rctx, md, "", "", pathRule)
+		So(err, ShouldBeNil)
+		So(strings.HasPrefix(key, "{"), ShouldBeFalse)
+
+		lm.flags.hashTag = true
+		_, pathKey, err := lm.resolveLimit(rctx, md, "", "", pathRule)
+		So(err, ShouldBeNil)
+		So(pathKey, ShouldEqual, "{100}:"+key)
+		_, groupKey, err := lm.resolveLimit(rctx, md, "", "", groupRule)
+		So(err, ShouldBeNil)
+		So(gredis.KeySlot(groupKey), ShouldEqual, gredis.KeySlot(pathKey))
+
+		// rule without uid is shared by users, not tagged
+		_, appKey, err := lm.resolveLimit(rctx, md, "", "", appRule)
+		So(err, ShouldBeNil)
+		So(strings.HasPrefix(appKey, "{"), ShouldBeFalse)
+
+		So(lm.parseFlags([]string{"limiterV2", "--hashTag=true", `--rules=[{"rate":10}]`}), ShouldBeNil)
+		So(lm.flags.hashTag, ShouldBeTrue)
+	})
+}
+
 func TestRateLimiterV2_getQuota(t *testing.T) {
 	Convey("test rateLimiterV2 getQuota", t, func() {
 		r := &rateLimiterV2{}
@@ -71,6 +71,10 @@ func (b *bsp) Do(next types.Handler) types.Handler {
 }
 
 func (b *bsp) Init(ctx context.Context, args ...string) error {
+	if filter.IsDryRun(ctx) {
+		return nil
+	}
+
 	var err error
 	once.Do(func() {
 		checker, err = gbsp.NewChecker(ctx, getBspPublicKey())
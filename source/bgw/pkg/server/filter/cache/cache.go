@@ -0,0 +1,335 @@
+package cache
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"code.bydev.io/fbu/gateway/gway.git/gcore/cast"
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"code.bydev.io/fbu/gateway/gway.git/gmetric"
+	"code.bydev.io/frameworks/byone/core/syncx"
+	"github.com/coocood/freecache"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/metadata"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/common/util"
+	"bgw/pkg/config"
+	"bgw/pkg/server/filter"
+	gmetadata "bgw/pkg/server/metadata"
+)
+
+const (
+	defaultCacheSize = 64 // Mb
+	defaultTTL       = 1  // seconds
+
+	metricType = "route_cache"
+
+	metaBrokerID = "brokerID"
+	metaSiteID   = "siteID"
+	metaLanguage = "language"
+)
+
+var (
+	cacheOnce     sync.Once
+	responseCache *freecache.Cache
+
+	// generation is increased on every filter Init, routes are rebuilt on each AppConfig version,
+	// so entries of old version are never hit and will be evicted by ttl or lru
+	generation atomic.Int64
+)
+
+func Init() {
+	filter.Register(filter.CacheFilterKey, newCache) // route filter
+}
+
+type result interface {
+	GetStatus() int
+	GetData() ([]byte, error)
+	Metadata() metadata.MD
+	io.Closer
+}
+
+// entry is the cached upstream result
+type entry struct {
+	Status int         `json:"status"`
+	MD     metadata.MD `json:"md"`
+	Data   []byte      `json:"data"`
+}
+
+// clone copy entry for concurrent requests sharing the same upstream result
+func (e *entry) clone() *entry {
+	return &entry{
+		Status: e.Status,
+		MD:     e.MD.Copy(),
+		Data:   e.Data,
+	}
+}
+
+// cachedResult is the result carrier of a cache hit
+type cachedResult struct {
+	e *entry
+}
+
+// GetStatus get cached status
+func (c *cachedResult) GetStatus() int { return c.e.Status }
+
+// GetData get cached data
+func (c *cachedResult) GetData() ([]byte, error) { return c.e.Data, nil }
+
+// Metadata get cached metadata
+func (c *cachedResult) Metadata() metadata.MD {
+	if c.e.MD == nil {
+		c.e.MD = metadata.New(nil)
+	}
+	return c.e.MD
+}
+
+// Close do nothing
+func (c *cachedResult) Close() error { return nil }
+
+type cache struct {
+	ttl      int      // seconds
+	query    []string // query keys, sorted, all query args if empty
+	metas    []string // metadata keys
+	prefix   string   // route key + generation
+	store    *freecache.Cache
+	flight   syncx.SingleFlight
+	hasQuery bool
+}
+
+func newCache() filter.Filter {
+	return &cache{}
+}
+
+// GetName returns the name of the filter
+func (c *cache) GetName() string {
+	return filter.CacheFilterKey
+}
+
+// Init cache flag parse ( --ttl=1 --query=symbol,category --metadata=brokerID,language )
+func (c *cache) Init(ctx context.Context, args ...string) error {
+	if filter.IsDryRun(ctx) {
+		if len(args) == 0 {
+			return nil
+		}
+		return c.parseFlags(ctx, args)
+	}
+
+	cacheOnce.Do(func() {
+		size := config.Global.Data.CacheSize.ResponseCacheSize
+		if size < defaultCacheSize {
+			size = defaultCacheSize
+		}
+		responseCache = freecache.NewCache(size * 1024 * 1024)
+	})
+
+	c.ttl = defaultTTL
+	c.store = responseCache
+	c.flight = syncx.NewSingleFlight()
+
+	var route string
+	if len(args) > 0 {
+		route = args[0]
+		if err := c.parseFlags(ctx, args); err != nil {
+			return err
+		}
+	}
+
+	c.prefix = route + ":" + strconv.FormatInt(generation.Inc(), 10)
+	return nil
+}
+
+func (c *cache) parseFlags(ctx context.Context, args []string) error {
+	var query, metas string
+
+	p := flag.NewFlagSet("cache", flag.ContinueOnError)
+	p.IntVar(&c.ttl, "ttl", defaultTTL, "cache ttl, seconds")
+	p.StringVar(&query, "query", "", "query keys of cache key, split by comma")
+	p.StringVar(&metas, "metadata", "", "metadata of cache key, brokerID,siteID,language")
+
+	if err := p.Parse(args[1:]); err != nil {
+		glog.Info(ctx, "cache parse error", glog.Any("args", args), glog.String("error", err.Error()))
+		return err
+	}
+
+	if c.ttl <= 0 {
+		c.ttl = defaultTTL
+	}
+
+	c.query = splitKeys(query)
+	sort.Strings(c.query)
+	c.hasQuery = len(c.query) > 0
+	c.metas = splitKeys(metas)
+	for _, m := range c.metas {
+		switch m {
+		case metaBrokerID, metaSiteID, metaLanguage:
+		default:
+			return fmt.Errorf("invalid cache metadata: %s", m)
+		}
+	}
+
+	return nil
+}
+
+// Do implements the filter interface
+func (c *cache) Do(next types.Handler) types.Handler {
+	return func(ctx *types.Ctx) error {
+		if !ctx.IsGet() {
+			return next(ctx)
+		}
+
+		key := c.key(ctx)
+		if e := c.get(key); e != nil {
+			gmetric.IncDefaultCounter(metricType, "hit")
+			ctx.SetUserValue(constant.CtxInvokeResult, &cachedResult{e: e})
+			return nil
+		}
+
+		// stampede protect, only one request of the same key goes upstream
+		var leader bool
+		v, err := c.flight.Do(key, func() (interface{}, error) {
+			if e := c.get(key); e != nil {
+				return e, nil
+			}
+
+			leader = true
+			gmetric.IncDefaultCounter(metricType, "miss")
+			if err := next(ctx); err != nil {
+				return nil, err
+			}
+			return c.set(ctx, key), nil
+		})
+		if leader {
+			return err
+		}
+
+		e, ok := v.(*entry)
+		if err != nil || !ok || e == nil {
+			// leader failed or result is not cacheable, invoke by self
+			gmetric.IncDefaultCounter(metricType, "bypass")
+			return next(ctx)
+		}
+
+		gmetric.IncDefaultCounter(metricType, "shared")
+		ctx.SetUserValue(constant.CtxInvokeResult, &cachedResult{e: e.clone()})
+		return nil
+	}
+}
+
+// key build cache key: route:generation:path?query#metadata
+func (c *cache) key(ctx *types.Ctx) string {
+	var b strings.Builder
+	b.WriteString(c.prefix)
+	b.WriteByte(':')
+	b.Write(ctx.Path())
+	b.WriteByte('?')
+
+	args := ctx.QueryArgs()
+	if c.hasQuery {
+		for i, k := range c.query {
+			if i > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(k)
+			b.WriteByte('=')
+			b.Write(args.Peek(k))
+		}
+	} else {
+		kvs := make([]string, 0, args.Len())
+		args.VisitAll(func(k, v []byte) {
+			kvs = append(kvs, string(k)+"="+string(v))
+		})
+		sort.Strings(kvs)
+		b.WriteString(strings.Join(kvs, "&"))
+	}
+
+	if len(c.metas) > 0 {
+		md := gmetadata.MDFromContext(ctx)
+		b.WriteByte('#')
+		for i, m := range c.metas {
+			if i > 0 {
+				b.WriteByte('&')
+			}
+			switch m {
+			case metaBrokerID:
+				b.WriteString(strconv.FormatInt(int64(md.BrokerID), 10))
+			case metaSiteID:
+				b.WriteString(md.SiteID)
+			case metaLanguage:
+				b.WriteString(md.GetLanguage())
+			}
+		}
+	}
+
+	return b.String()
+}
+
+func (c *cache) get(key string) *entry {
+	data, err := c.store.Get(cast.UnsafeStringToBytes(key))
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+
+	e := &entry{}
+	if err = util.JsonUnmarshal(data, e); err != nil {
+		return nil
+	}
+	return e
+}
+
+// set store upstream result if cacheable, only success result is cached
+func (c *cache) set(ctx *types.Ctx, key string) *entry {
+	source, ok := ctx.UserValue(constant.CtxInvokeResult).(result)
+	if !ok || source == nil {
+		return nil
+	}
+
+	if status := source.GetStatus(); status != 0 && status != 200 {
+		return nil
+	}
+	md := source.Metadata()
+	if codes := md.Get(constant.BgwAPIResponseCodes); len(codes) > 0 && codes[0] != "0" {
+		return nil
+	}
+
+	data, err := source.GetData()
+	if err != nil {
+		return nil
+	}
+
+	e := &entry{
+		Status: source.GetStatus(),
+		MD:     md.Copy(),
+		Data:   append([]byte(nil), data...),
+	}
+	raw, err := util.JsonMarshal(e)
+	if err != nil {
+		return nil
+	}
+
+	if err = c.store.Set([]byte(key), raw, c.ttl); err != nil {
+		glog.Debug(ctx, "response cache set error", glog.String("key", key), glog.String("error", err.Error()))
+		gmetric.IncDefaultError(metricType, "set")
+	}
+	return e
+}
+
+func splitKeys(s string) []string {
+	tokens := strings.Split(s, ",")
+	keys := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		t = strings.TrimSpace(t)
+		if t != "" {
+			keys = append(keys, t)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc/metadata"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/server/filter"
+	gmetadata "bgw/pkg/server/metadata"
+)
+
+func TestCache_Init(t *testing.T) {
+	Convey("test cache init", t, func() {
+		Init()
+		f, err := filter.GetFilter(context.Background(), filter.CacheFilterKey)
+		So(err, ShouldBeNil)
+		So(f.GetName(), ShouldEqual, filter.CacheFilterKey)
+
+		c := &cache{}
+		err = c.Init(context.Background(), "route", "--ttl=5", "--query=symbol,category", "--metadata=brokerID,language")
+		So(err, ShouldBeNil)
+		So(c.ttl, ShouldEqual, 5)
+		So(c.query, ShouldResemble, []string{"category", "symbol"})
+		So(c.metas, ShouldResemble, []string{"brokerID", "language"})
+
+		c2 := &cache{}
+		err = c2.Init(context.Background(), "route", "--ttl=0")
+		So(err, ShouldBeNil)
+		So(c2.ttl, ShouldEqual, defaultTTL)
+		So(c2.prefix, ShouldNotEqual, c.prefix)
+
+		err = c2.Init(context.Background(), "route", "--metadata=uid")
+		So(err, ShouldNotBeNil)
+
+		err = c2.Init(context.Background(), "route", "--wrong=1")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCache_Key(t *testing.T) {
+	Convey("test cache key", t, func() {
+		c := &cache{}
+		_ = c.Init(context.Background(), "route", "--query=symbol", "--metadata=brokerID")
+
+		ctx := &types.Ctx{}
+		ctx.Request.SetRequestURI("/v5/market/tickers?symbol=BTCUSDT&limit=1")
+		md := gmetadata.MDFromContext(ctx)
+		md.BrokerID = 9
+		So(c.key(ctx), ShouldEqual, c.prefix+":/v5/market/tickers?symbol=BTCUSDT#9")
+
+		c2 := &cache{}
+		_ = c2.Init(context.Background(), "route")
+		ctx2 := &types.Ctx{}
+		ctx2.Request.SetRequestURI("/v5/market/tickers?symbol=BTCUSDT&category=linear")
+		So(c2.key(ctx2), ShouldEqual, c2.prefix+":/v5/market/tickers?category=linear&symbol=BTCUSDT")
+	})
+}
+
+func TestCache_Do(t *testing.T) {
+	Convey("test cache do", t, func() {
+		c := &cache{}
+		_ = c.Init(context.Background(), "route", "--ttl=10")
+
+		var calls int
+		h := c.Do(func(ctx *types.Ctx) error {
+			calls++
+			ctx.SetUserValue(constant.CtxInvokeResult, &mockResult{data: []byte(`{"a":1}`), md: metadata.Pairs("k", "v")})
+			return nil
+		})
+
+		newCtx := func() *types.Ctx {
+			ctx := &types.Ctx{}
+			ctx.Request.Header.SetMethod("GET")
+			ctx.Request.SetRequestURI("/v5/market/time")
+			return ctx
+		}
+
+		ctx := newCtx()
+		So(h(ctx), ShouldBeNil)
+		So(calls, ShouldEqual, 1)
+
+		ctx = newCtx()
+		So(h(ctx), ShouldBeNil)
+		So(calls, ShouldEqual, 1)
+		res, ok := ctx.UserValue(constant.CtxInvokeResult).(result)
+		So(ok, ShouldBeTrue)
+		data, _ := res.GetData()
+		So(string(data), ShouldEqual, `{"a":1}`)
+		So(res.Metadata().Get("k"), ShouldResemble, []string{"v"})
+
+		// post is not cached
+		ctx = newCtx()
+		ctx.Request.Header.SetMethod("POST")
+		So(h(ctx), ShouldBeNil)
+		So(calls, ShouldEqual, 2)
+
+		// business error is not cached
+		c2 := &cache{}
+		_ = c2.Init(context.Background(), "route")
+		h = c2.Do(func(ctx *types.Ctx) error {
+			calls++
+			ctx.SetUserValue(constant.CtxInvokeResult, &mockResult{md: metadata.Pairs(constant.BgwAPIResponseCodes, "10001")})
+			return nil
+		})
+		So(h(newCtx()), ShouldBeNil)
+		So(h(newCtx()), ShouldBeNil)
+		So(calls, ShouldEqual, 4)
+
+		h = c2.Do(func(ctx *types.Ctx) error {
+			return errors.New("mock err")
+		})
+		So(h(newCtx()), ShouldNotBeNil)
+	})
+}
+
+type mockResult struct {
+	data []byte
+	md   metadata.MD
+}
+
+func (m *mockResult) GetStatus() int           { return 200 }
+func (m *mockResult) GetData() ([]byte, error) { return m.data, nil }
+func (m *mockResult) Metadata() metadata.MD    { return m.md }
+func (m *mockResult) Close() error             { return nil }
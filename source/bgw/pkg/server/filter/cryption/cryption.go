@@ -104,7 +104,9 @@ func (s *crypter) Do(next types.Handler) types.Handler {
 
 // Init will init the filter
 func (s *crypter) Init(ctx context.Context, args ...string) (err error) {
-	_ = initCipher(ctx)
+	if !filter.IsDryRun(ctx) {
+		_ = initCipher(ctx)
+	}
 
 	if len(args) == 0 {
 		return nil
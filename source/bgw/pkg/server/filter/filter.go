@@ -39,6 +39,9 @@ const (
 	CryptionFilterKey           = "FILTER_BIZ_CRYPTION"
 	BanFilterKey                = "FILTER_BIZ_BAN"
 	BspFilterKey                = "FILTER_BSP"
+	TransformFilterKey          = "FILTER_TRANSFORM"    // route filter, request & response body transform
+	CacheFilterKey              = "FILTER_CACHE"        // route filter, upstream result cache
+	LoadShedderFilterKey        = "FILTER_LOAD_SHEDDER" // appended to every route chain, shed low priority routes on overload
 )
 
 var (
@@ -59,6 +62,26 @@ type Initializer interface {
 	Init(ctx context.Context, args ...string) error
 }
 
+// Explainer explains how the filter would handle the request without side effects,
+// n is the count of following requests to simulate, used by admin
+type Explainer interface {
+	Explain(ctx *types.Ctx, n int) interface{}
+}
+
+type dryRunKey struct{}
+
+// WithDryRun mark ctx as dry run, Initializer should only parse and validate args,
+// side effects like remote loaders or connections must be skipped
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun check whether ctx is dry run
+func IsDryRun(ctx context.Context) bool {
+	v, _ := ctx.Value(dryRunKey{}).(bool)
+	return v
+}
+
 // Func is a filter function
 type Func func(types.Handler) types.Handler
 
@@ -74,6 +74,10 @@ func (g *geo) Init(ctx context.Context, args ...string) (err error) {
 		return
 	}
 
+	if filter.IsDryRun(ctx) {
+		return g.parse(ctx, args)
+	}
+
 	gm, err := geoip.NewGeoManager()
 	if err != nil || gm == nil {
 		return fmt.Errorf("NewGeoManager error:%w", err)
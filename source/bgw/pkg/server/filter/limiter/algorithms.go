@@ -0,0 +1,114 @@
+package limiter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	limitTokenBucket   = "token_bucket"   // default
+	limitSlidingLog    = "sliding_log"    // exact count of requests in the last period
+	limitSlidingWindow = "sliding_window" // weighted count of current and previous fixed window
+	limitConcurrency   = "concurrency"    // in-flight requests, rate is the max in-flight count
+)
+
+// localLimiter in-process limiter of one route
+type localLimiter interface {
+	Allow() bool
+}
+
+func newLocalLimiter(limitType string, limit int, period time.Duration) localLimiter {
+	switch limitType {
+	case limitSlidingLog:
+		return &slidingLog{limit: limit, period: period, log: make([]time.Time, 0, limit)}
+	case limitSlidingWindow:
+		return &slidingWindow{limit: limit, period: period}
+	case limitConcurrency:
+		return &concurrency{limit: int64(limit)}
+	default:
+		return rate.NewLimiter(rate.Limit(float64(limit)/period.Seconds()), limit)
+	}
+}
+
+// slidingLog keeps the timestamps of last limit requests as a ring
+type slidingLog struct {
+	sync.Mutex
+	limit  int
+	period time.Duration
+	log    []time.Time
+	head   int // oldest timestamp when log is full
+}
+
+func (l *slidingLog) Allow() bool {
+	now := time.Now()
+
+	l.Lock()
+	defer l.Unlock()
+
+	if len(l.log) < l.limit {
+		l.log = append(l.log, now)
+		return true
+	}
+	if l.limit == 0 || now.Sub(l.log[l.head]) < l.period {
+		return false
+	}
+	l.log[l.head] = now
+	l.head = (l.head + 1) % l.limit
+	return true
+}
+
+// slidingWindow weights the counter of previous fixed window by its overlap with the sliding window
+type slidingWindow struct {
+	sync.Mutex
+	limit  int
+	period time.Duration
+	window int64 // index of current window
+	cur    int
+	prev   int
+}
+
+func (l *slidingWindow) Allow() bool {
+	now := time.Now().UnixNano()
+	window := now / int64(l.period)
+	elapsed := now - window*int64(l.period)
+
+	l.Lock()
+	defer l.Unlock()
+
+	switch window - l.window {
+	case 0:
+	case 1:
+		l.prev, l.cur = l.cur, 0
+	default:
+		l.prev, l.cur = 0, 0
+	}
+	l.window = window
+
+	count := int(int64(l.prev)*(int64(l.period)-elapsed)/int64(l.period)) + l.cur
+	if count >= l.limit {
+		return false
+	}
+	l.cur++
+	return true
+}
+
+// concurrency counts in-flight requests, slot is returned by release after the request is finished
+type concurrency struct {
+	limit    int64
+	inflight atomic.Int64
+}
+
+func (l *concurrency) Allow() bool {
+	if l.inflight.Add(1) > l.limit {
+		l.inflight.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (l *concurrency) release() {
+	l.inflight.Add(-1)
+}
@@ -6,4 +6,5 @@ func Init() {
 	filter.Register(filter.QPSRateLimitFilterKeyGlobal, newGlobalLimiter()) // global filter
 	filter.Register(filter.QPSRateLimitFilterKey, new)                      // route filter
 	filter.Register(filter.IPRateLimitFilterKey, newIPLimiter)              // route filter
+	filter.Register(filter.LoadShedderFilterKey, newLoadShedder())          // appended to every route chain
 }
@@ -12,7 +12,6 @@ import (
 
 	"code.bydev.io/fbu/gateway/gway.git/gcore/cast"
 	"code.bydev.io/fbu/gateway/gway.git/glog"
-	"golang.org/x/time/rate"
 )
 
 var (
@@ -24,7 +23,9 @@ var (
 
 type limiter struct {
 	rateLimit int
-	limiter   *rate.Limiter
+	limitType string
+	period    time.Duration
+	limiter   localLimiter
 }
 
 func new() filter.Filter {
@@ -34,6 +35,7 @@ func new() filter.Filter {
 
 	return &limiter{
 		rateLimit: defaultRate,
+		period:    time.Second,
 	}
 }
 
@@ -50,6 +52,9 @@ func (l *limiter) Do(next types.Handler) types.Handler {
 			glog.Debug(ctx, "local limiter block", glog.Duration("cost", time.Since(now)), glog.String("path", cast.UnsafeBytesToString(ctx.Path())))
 			return berror.ErrVisitsLimit
 		}
+		if c, ok := l.limiter.(*concurrency); ok {
+			defer c.release()
+		}
 
 		glog.Debug(ctx, "local limiter allow", glog.Duration("cost", time.Since(now)), glog.String("path", cast.UnsafeBytesToString(ctx.Path())))
 
@@ -64,7 +69,7 @@ func (l *limiter) Init(ctx context.Context, args ...string) (err error) {
 		return
 	}
 
-	l.limiter = rate.NewLimiter(rate.Limit(l.rateLimit), l.rateLimit)
+	l.limiter = newLocalLimiter(l.limitType, l.rateLimit, l.period)
 	return
 }
 
@@ -75,6 +80,19 @@ func (l *limiter) parseFlags(args []string) (err error) {
 
 	parse := flag.NewFlagSet("limiter", flag.ContinueOnError)
 	parse.IntVar(&l.rateLimit, "rate", defaultRate, "rate val")
+	parse.StringVar(&l.limitType, "type", "", "limit type, token_bucket, sliding_log, sliding_window or concurrency")
+	parse.DurationVar(&l.period, "period", time.Second, "period like: 1s, 10s or 1m")
+	if err = parse.Parse(args[1:]); err != nil {
+		return
+	}
 
-	return parse.Parse(args[1:])
+	switch l.limitType {
+	case "", limitTokenBucket, limitSlidingLog, limitSlidingWindow, limitConcurrency:
+	default:
+		return berror.NewInterErr("limiter type is invalid", l.limitType)
+	}
+	if l.rateLimit < 0 || l.period <= 0 {
+		return berror.NewInterErr("limiter rate or period is invalid")
+	}
+	return
 }
@@ -3,6 +3,7 @@ package limiter
 import (
 	"context"
 	"testing"
+	"time"
 
 	"code.bydev.io/fbu/gateway/gway.git/gcore/observer"
 	. "github.com/smartystreets/goconvey/convey"
@@ -82,3 +83,34 @@ func TestLimiter(t *testing.T) {
 		So(err, ShouldNotBeNil)
 	})
 }
+
+func TestLimiterAlgorithms(t *testing.T) {
+	Convey("test limiter algorithms", t, func() {
+		l := new().(*limiter)
+		So(l.Init(context.Background(), "route", "--type=xxx"), ShouldNotBeNil)
+		So(l.Init(context.Background(), "route", "--period=0s"), ShouldNotBeNil)
+
+		So(l.Init(context.Background(), "route", "--rate=2", "--type=sliding_log", "--period=50ms"), ShouldBeNil)
+		So(l.limiter.Allow(), ShouldBeTrue)
+		So(l.limiter.Allow(), ShouldBeTrue)
+		So(l.limiter.Allow(), ShouldBeFalse)
+		time.Sleep(60 * time.Millisecond)
+		So(l.limiter.Allow(), ShouldBeTrue)
+
+		So(l.Init(context.Background(), "route", "--rate=2", "--type=sliding_window", "--period=1h"), ShouldBeNil)
+		So(l.limiter.Allow(), ShouldBeTrue)
+		So(l.limiter.Allow(), ShouldBeTrue)
+		So(l.limiter.Allow(), ShouldBeFalse)
+
+		// slot is released after the request finished
+		So(l.Init(context.Background(), "route", "--rate=1", "--type=concurrency"), ShouldBeNil)
+		var inner error
+		handler := l.Do(func(ctx *types.Ctx) error {
+			inner = l.Do(func(ctx *types.Ctx) error { return nil })(ctx)
+			return nil
+		})
+		So(handler(&types.Ctx{}), ShouldBeNil)
+		So(inner, ShouldNotBeNil)
+		So(handler(&types.Ctx{}), ShouldBeNil)
+	})
+}
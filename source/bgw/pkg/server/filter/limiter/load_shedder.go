@@ -0,0 +1,245 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"code.bydev.io/fbu/gateway/gway.git/gmetric"
+
+	"bgw/pkg/common/berror"
+	"bgw/pkg/common/types"
+	"bgw/pkg/config"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/metadata"
+)
+
+const (
+	shedSampleInterval = 250 * time.Millisecond
+	shedCPUDecay       = 0.8         // ewma decay of cpu usage
+	shedLatencyDecay   = 0.8         // ewma decay of route latency
+	shedBaselineWindow = time.Minute // baseline latency follows higher latency in this window
+	shedBand           = 0.3         // pressure width from a priority starts shedding to fully shed
+	shedStaleTime      = time.Second // route pressure expires without new latency, as fully shed route has no samples
+)
+
+// shedStart pressure to start shedding of each priority, lower priority is shed first,
+// trading and unlabeled routes are never shed
+var shedStart = map[string]float64{
+	metadata.PriorityMisc:    0,
+	metadata.PriorityMarket:  shedBand,
+	metadata.PriorityAccount: shedBand * 2,
+}
+
+var _ filter.Filter = &loadShedder{}
+
+// loadShedder sheds low priority routes when gateway is overloaded, it's appended to every route chain by core
+// as it needs route priority.
+// pressure comes from cpu usage, goroutine count and route latency:
+// a route is congested when its latency stays above target for a whole interval (codel),
+// and its pressure is the gradient of latency to target.
+type loadShedder struct {
+	enable           bool
+	cpuThreshold     float64
+	maxGoroutines    int
+	latencyTarget    time.Duration
+	latencyTolerance float64
+	interval         time.Duration
+
+	cpu        atomic.Int64 // permille of cpu quota, ewma
+	goroutines atomic.Int64
+	routes     sync.Map // route key -> *routeStat
+	once       sync.Once
+}
+
+func newLoadShedder() filter.Filter {
+	return newLoadShedderWithConfig(config.Global.LoadShedding)
+}
+
+func newLoadShedderWithConfig(cfg config.LoadShedding) *loadShedder {
+	l := &loadShedder{
+		enable:           cfg.Enable,
+		cpuThreshold:     float64(cfg.CPUThreshold) / 100,
+		maxGoroutines:    cfg.MaxGoroutines,
+		latencyTarget:    time.Duration(cfg.LatencyTarget) * time.Millisecond,
+		latencyTolerance: cfg.LatencyTolerance,
+		interval:         time.Duration(cfg.Interval) * time.Millisecond,
+	}
+	if l.cpuThreshold <= 0 || l.cpuThreshold >= 1 {
+		l.cpuThreshold = 0.8
+	}
+	if l.maxGoroutines <= 0 {
+		l.maxGoroutines = 50000
+	}
+	if l.latencyTarget <= 0 {
+		l.latencyTarget = 50 * time.Millisecond
+	}
+	if l.latencyTolerance < 1 {
+		l.latencyTolerance = 2
+	}
+	if l.interval <= 0 {
+		l.interval = 100 * time.Millisecond
+	}
+	return l
+}
+
+// GetName returns the name of the filter
+func (l *loadShedder) GetName() string {
+	return filter.LoadShedderFilterKey
+}
+
+// Do shed request by route priority
+func (l *loadShedder) Do(next types.Handler) types.Handler {
+	if !l.enable {
+		return next
+	}
+	l.once.Do(func() {
+		go l.sample()
+	})
+
+	return func(ctx *types.Ctx) error {
+		md := metadata.MDFromContext(ctx)
+		priority := md.Route.Priority
+		route := md.Route.String()
+		rs := l.getRouteStat(route)
+
+		if l.shouldShed(priority, rs, time.Now()) {
+			glog.Debug(ctx, "load_shed hit", glog.String("route", route), glog.String("priority", priority))
+			gmetric.IncDefaultError("load_shed", priority)
+			ctx.SetStatusCode(http.StatusTooManyRequests)
+			return berror.ErrVisitsLimit
+		}
+
+		err := next(ctx)
+		if md.ReqCost > 0 {
+			rs.observe(md.ReqCost, time.Now(), l.latencyTarget, l.latencyTolerance, l.interval)
+		}
+		return err
+	}
+}
+
+func (l *loadShedder) shouldShed(priority string, rs *routeStat, now time.Time) bool {
+	start, ok := shedStart[priority]
+	if !ok {
+		return false
+	}
+
+	p := math.Max(l.systemPressure(), rs.pressure(now))
+	if p <= start {
+		return false
+	}
+	return rand.Float64() < (p-start)/shedBand
+}
+
+// systemPressure 0 means not overloaded, 1 means fully overloaded
+func (l *loadShedder) systemPressure() float64 {
+	var p float64
+	if cpu := float64(l.cpu.Load()) / 1000; cpu > l.cpuThreshold {
+		p = (cpu - l.cpuThreshold) / (1 - l.cpuThreshold)
+	}
+	if g := int(l.goroutines.Load()); g > l.maxGoroutines {
+		p = math.Max(p, float64(g-l.maxGoroutines)/float64(l.maxGoroutines))
+	}
+	return math.Min(p, 1)
+}
+
+func (l *loadShedder) getRouteStat(key string) *routeStat {
+	if v, ok := l.routes.Load(key); ok {
+		return v.(*routeStat)
+	}
+	v, _ := l.routes.LoadOrStore(key, &routeStat{})
+	return v.(*routeStat)
+}
+
+// sample cpu usage and goroutine count
+func (l *loadShedder) sample() {
+	ticker := time.NewTicker(shedSampleInterval)
+	defer ticker.Stop()
+
+	lastCPU, err := processCPUTime()
+	if err != nil {
+		glog.Error(context.Background(), "load shedder get cpu time error", glog.String("error", err.Error()))
+	}
+	lastTime := time.Now()
+	var usage float64
+	for now := range ticker.C {
+		l.goroutines.Store(int64(runtime.NumGoroutine()))
+
+		cpu, err := processCPUTime()
+		if err != nil {
+			continue
+		}
+		cur := float64(cpu-lastCPU) / float64(now.Sub(lastTime)) / float64(runtime.GOMAXPROCS(0))
+		lastCPU, lastTime = cpu, now
+		usage = usage*shedCPUDecay + cur*(1-shedCPUDecay)
+		l.cpu.Store(int64(usage * 1000))
+
+		gmetric.SetDefaultGauge(l.systemPressure(), "load_shed", "pressure")
+	}
+}
+
+// processCPUTime user and system cpu time of the process
+func processCPUTime() (time.Duration, error) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, err
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano()), nil
+}
+
+// routeStat upstream latency of route
+type routeStat struct {
+	sync.Mutex
+	latency     float64   // ms, ewma
+	baseline    float64   // ms, min latency drifts up slowly
+	target      float64   // ms, max(latency target, baseline * tolerance)
+	congestedAt time.Time // latency above target until this time means congested, zero if below target
+	updated     time.Time
+}
+
+func (s *routeStat) observe(cost time.Duration, now time.Time, target time.Duration, tolerance float64, interval time.Duration) {
+	ms := float64(cost) / float64(time.Millisecond)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.latency == 0 {
+		s.latency = ms
+	} else {
+		s.latency = s.latency*shedLatencyDecay + ms*(1-shedLatencyDecay)
+	}
+	if s.baseline == 0 || ms < s.baseline {
+		s.baseline = ms
+	} else {
+		s.baseline += (ms - s.baseline) * math.Min(float64(now.Sub(s.updated))/float64(shedBaselineWindow), 1)
+	}
+	s.target = math.Max(float64(target)/float64(time.Millisecond), s.baseline*tolerance)
+	s.updated = now
+
+	if s.latency <= s.target {
+		s.congestedAt = time.Time{}
+		return
+	}
+	if s.congestedAt.IsZero() {
+		// congested only if latency stays above target for a whole interval
+		s.congestedAt = now.Add(interval)
+	}
+}
+
+// pressure gradient of latency to target when route is congested
+func (s *routeStat) pressure(now time.Time) float64 {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.congestedAt.IsZero() || now.Before(s.congestedAt) || now.Sub(s.updated) > shedStaleTime {
+		return 0
+	}
+	return 1 - s.target/s.latency
+}
@@ -0,0 +1,115 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"bgw/pkg/common/berror"
+	"bgw/pkg/common/types"
+	"bgw/pkg/config"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/metadata"
+)
+
+func TestLoadShedder(t *testing.T) {
+	Convey("test disabled", t, func() {
+		l := newLoadShedderWithConfig(config.LoadShedding{})
+		So(l.GetName(), ShouldEqual, filter.LoadShedderFilterKey)
+		So(l.cpuThreshold, ShouldEqual, 0.8)
+		So(l.latencyTarget, ShouldEqual, 50*time.Millisecond)
+
+		l.cpu.Store(1000)
+		called := false
+		err := l.Do(func(*types.Ctx) error {
+			called = true
+			return nil
+		})(&types.Ctx{})
+		So(err, ShouldBeNil)
+		So(called, ShouldBeTrue)
+	})
+
+	Convey("test system pressure", t, func() {
+		l := newLoadShedderWithConfig(config.LoadShedding{CPUThreshold: 80, MaxGoroutines: 100})
+		So(l.systemPressure(), ShouldEqual, 0)
+
+		l.cpu.Store(900)
+		So(l.systemPressure(), ShouldAlmostEqual, 0.5)
+		l.goroutines.Store(180)
+		So(l.systemPressure(), ShouldAlmostEqual, 0.8)
+		l.goroutines.Store(1000)
+		So(l.systemPressure(), ShouldEqual, 1)
+	})
+
+	Convey("test shed by priority", t, func() {
+		l := newLoadShedderWithConfig(config.LoadShedding{CPUThreshold: 80})
+		rs := &routeStat{}
+		now := time.Now()
+
+		// pressure 0.5, misc fully shed, market partly shed, account and trading passed
+		l.cpu.Store(900)
+		So(l.shouldShed(metadata.PriorityMisc, rs, now), ShouldBeTrue)
+		So(l.shouldShed(metadata.PriorityAccount, rs, now), ShouldBeFalse)
+		So(l.shouldShed(metadata.PriorityTrading, rs, now), ShouldBeFalse)
+
+		l.cpu.Store(1000)
+		So(l.shouldShed(metadata.PriorityMarket, rs, now), ShouldBeTrue)
+		So(l.shouldShed(metadata.PriorityTrading, rs, now), ShouldBeFalse)
+	})
+
+	Convey("test route congestion", t, func() {
+		rs := &routeStat{}
+		now := time.Now()
+		target, interval := 50*time.Millisecond, 100*time.Millisecond
+
+		rs.observe(10*time.Millisecond, now, target, 2, interval)
+		So(rs.pressure(now), ShouldEqual, 0)
+
+		// above target but not for a whole interval
+		for i := 0; i < 20; i++ {
+			rs.observe(time.Second, now, target, 2, interval)
+		}
+		So(rs.pressure(now), ShouldEqual, 0)
+
+		now = now.Add(interval)
+		rs.observe(time.Second, now, target, 2, interval)
+		p := rs.pressure(now)
+		So(p, ShouldBeGreaterThan, 0.9)
+
+		// expires without samples
+		So(rs.pressure(now.Add(shedStaleTime*2)), ShouldEqual, 0)
+
+		// recovered
+		for i := 0; i < 50; i++ {
+			rs.observe(10*time.Millisecond, now, target, 2, interval)
+		}
+		So(rs.pressure(now), ShouldEqual, 0)
+	})
+
+	Convey("test shed request", t, func() {
+		l := newLoadShedderWithConfig(config.LoadShedding{Enable: true})
+		l.once.Do(func() {})
+		l.cpu.Store(1000)
+
+		h := l.Do(func(ctx *types.Ctx) error {
+			metadata.MDFromContext(ctx).ReqCost = time.Millisecond
+			return nil
+		})
+
+		ctx := &types.Ctx{}
+		md := metadata.MDFromContext(ctx)
+		md.Route.Priority = metadata.PriorityMisc
+		So(h(ctx), ShouldEqual, berror.ErrVisitsLimit)
+
+		ctx = &types.Ctx{}
+		md = metadata.MDFromContext(ctx)
+		md.Route.Priority = metadata.PriorityTrading
+		So(h(ctx), ShouldBeNil)
+		So(l.getRouteStat(md.Route.String()).latency, ShouldEqual, 1)
+
+		// unlabeled route is never shed
+		ctx = &types.Ctx{}
+		So(h(ctx), ShouldBeNil)
+	})
+}
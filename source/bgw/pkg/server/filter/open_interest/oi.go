@@ -127,6 +127,9 @@ func (o *oi) Init(ctx context.Context, args ...string) error {
 		glog.Error(ctx, "open interest parse flags failed", glog.String("err", err.Error()))
 		return err
 	}
+	if filter.IsDryRun(ctx) {
+		return nil
+	}
 
 	err = initOI()
 	if err != nil {
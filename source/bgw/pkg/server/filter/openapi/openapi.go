@@ -223,6 +223,14 @@ func (o *openapi) Do(next types.Handler) types.Handler {
 
 // Init route key and biz_type ( "--bizType=1" )
 func (o *openapi) Init(ctx context.Context, args ...string) (err error) {
+	if filter.IsDryRun(ctx) {
+		if len(args) == 0 {
+			return nil
+		}
+		_, err = limiterFlagParse(ctx, args)
+		return
+	}
+
 	if gm, err := geoip.NewGeoManager(); err != nil || gm == nil {
 		return fmt.Errorf("NewGeoManager error:%w", err)
 	}
@@ -124,7 +124,7 @@ func (r *response) Do(next types.Handler) types.Handler {
 }
 
 // Init init response filter
-func (r *response) Init(_ context.Context, args ...string) (err error) {
+func (r *response) Init(ctx context.Context, args ...string) (err error) {
 	switch len(args) {
 	case 0:
 		r.flags = responseFlags{version: version.VersionV2, translator: &translate{}}
@@ -132,6 +132,10 @@ func (r *response) Init(_ context.Context, args ...string) (err error) {
 		if err = r.parseFlags(args...); err != nil {
 			return
 		}
+		// code loader listens nacos, skipped in dry run
+		if !filter.IsDryRun(ctx) {
+			setCodeLoaders(args[0])
+		}
 	}
 
 	switch {
@@ -172,9 +176,13 @@ func (r *response) parseFlags(args ...string) (err error) {
 	flags.translator.msgTag = msgTag
 
 	r.flags = flags
+	return
+}
 
+// setCodeLoaders set code loaders of the route app, all apps if route is for all apps
+func setCodeLoaders(route string) {
 	routeKey := gmetadata.RouteKey{}
-	routeKey = routeKey.Parse(args[0])
+	routeKey = routeKey.Parse(route)
 	if !routeKey.AllApp {
 		setCodeLoader(routeKey.AppName)
 		return
@@ -182,13 +190,12 @@ func (r *response) parseFlags(args ...string) (err error) {
 	setCodeLoader(constant.AppTypeFUTURES)
 	setCodeLoader(constant.AppTypeSPOT)
 	setCodeLoader(constant.AppTypeOPTION)
-
-	return
 }
 
 // skip skip invalid types
 func (r *response) skip(ctx *types.Ctx) bool {
-	return ctx.IsOptions()
+	// streamed response is written by invoker
+	return ctx.IsOptions() || ctx.UserValue(constant.CtxInvokeStream) != nil
 }
 
 func (r *response) recover(ctx *types.Ctx, v interface{}) {
@@ -69,6 +69,16 @@ func TestResponse(t *testing.T) {
 		})(rctx)
 		assert.Nil(t, err)
 	})
+	t.Run("response skip stream", func(t *testing.T) {
+		resp := new()
+		rctx := makeReqCtx()
+		err := resp.Do(func(rctx *fasthttp.RequestCtx) error {
+			rctx.SetUserValue(constant.CtxInvokeStream, true)
+			return nil
+		})(rctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(rctx.Response.Body()))
+	})
 	t.Run("response do and recovery", func(t *testing.T) {
 		resp := &response{}
 		assert.Equal(t, filter.ResponseFilterKey, resp.GetName())
@@ -48,7 +48,7 @@ func (s *signatureKey) Do(next types.Handler) types.Handler {
 
 // Init will init the filter
 func (s *signatureKey) Init(ctx context.Context, args ...string) (err error) {
-	if len(args) == 0 {
+	if len(args) == 0 || filter.IsDryRun(ctx) {
 		// skip must filter
 		return nil
 	}
@@ -0,0 +1,253 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// rule operations
+const (
+	opRename  = "rename"  // rename field, keep value
+	opDrop    = "drop"    // drop field
+	opDefault = "default" // set field value if absent or null
+	opCoerce  = "coerce"  // convert field value type
+)
+
+// coerce target types
+const (
+	typeString = "string"
+	typeNumber = "number"
+	typeInt    = "int"
+	typeBool   = "bool"
+)
+
+const pathSep = "."
+
+var (
+	errEmptyField   = errors.New("transform rule field is empty")
+	errInvalidField = errors.New("transform rule field is invalid")
+	errNotJSON      = errors.New("transform payload is not a json object or array")
+)
+
+// rule is a single field mapping
+// field is a dot separated path, arrays on the path are walked element by element,
+// e.g. result.list.price applies to price of every item in result.list
+type rule struct {
+	Op    string      `json:"op"`
+	Field string      `json:"field"`
+	To    string      `json:"to,omitempty"`    // rename: new field name, same level as field
+	Value interface{} `json:"value,omitempty"` // default: value to fill
+	Type  string      `json:"type,omitempty"`  // coerce: string, number, int, bool
+
+	path []string
+}
+
+// parseRules parse rule list from json, e.g.
+// [{"op":"rename","field":"orderQty","to":"qty"},{"op":"coerce","field":"qty","type":"string"}]
+func parseRules(raw string) ([]*rule, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var rules []*rule
+	d := json.NewDecoder(strings.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("transform rules unmarshal error: %w", err)
+	}
+
+	for i, r := range rules {
+		if r == nil {
+			return nil, fmt.Errorf("transform rule[%d] is nil", i)
+		}
+		if err := r.check(); err != nil {
+			return nil, fmt.Errorf("transform rule[%d] %s: %w", i, r.Field, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// check validate rule and build field path
+func (r *rule) check() error {
+	r.Field = strings.TrimSpace(r.Field)
+	if r.Field == "" {
+		return errEmptyField
+	}
+
+	r.path = strings.Split(r.Field, pathSep)
+	for _, p := range r.path {
+		if p == "" {
+			return errInvalidField
+		}
+	}
+
+	switch r.Op {
+	case opRename:
+		r.To = strings.TrimSpace(r.To)
+		if r.To == "" || strings.Contains(r.To, pathSep) {
+			return fmt.Errorf("invalid rename target: %q", r.To)
+		}
+	case opDrop:
+	case opDefault:
+		if r.Value == nil {
+			return errors.New("default value is nil")
+		}
+	case opCoerce:
+		switch r.Type {
+		case typeString, typeNumber, typeInt, typeBool:
+		default:
+			return fmt.Errorf("invalid coerce type: %q", r.Type)
+		}
+	default:
+		return fmt.Errorf("invalid op: %q", r.Op)
+	}
+
+	return nil
+}
+
+// apply walk the value along the rule path and do the operation on the parent object
+func (r *rule) apply(v interface{}) {
+	r.walk(v, r.path)
+}
+
+func (r *rule) walk(v interface{}, path []string) {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, item := range t {
+			r.walk(item, path)
+		}
+	case map[string]interface{}:
+		if len(path) == 1 {
+			r.do(t, path[0])
+			return
+		}
+		if next, ok := t[path[0]]; ok {
+			r.walk(next, path[1:])
+		}
+	}
+}
+
+func (r *rule) do(obj map[string]interface{}, key string) {
+	val, ok := obj[key]
+	switch r.Op {
+	case opRename:
+		if ok {
+			delete(obj, key)
+			obj[r.To] = val
+		}
+	case opDrop:
+		delete(obj, key)
+	case opDefault:
+		if !ok || val == nil {
+			obj[key] = r.Value
+		}
+	case opCoerce:
+		if ok && val != nil {
+			if nv, ok := coerce(val, r.Type); ok {
+				obj[key] = nv
+			}
+		}
+	}
+}
+
+// coerce convert json value into target type, numbers are kept as json.Number to avoid precision loss
+func coerce(v interface{}, typ string) (interface{}, bool) {
+	switch typ {
+	case typeString:
+		switch t := v.(type) {
+		case string:
+			return t, true
+		case json.Number:
+			return t.String(), true
+		case bool:
+			return strconv.FormatBool(t), true
+		}
+	case typeNumber:
+		switch t := v.(type) {
+		case json.Number:
+			return t, true
+		case string:
+			s := strings.TrimSpace(t)
+			if _, err := strconv.ParseFloat(s, 64); err == nil {
+				return json.Number(s), true
+			}
+		case bool:
+			if t {
+				return json.Number("1"), true
+			}
+			return json.Number("0"), true
+		}
+	case typeInt:
+		var s string
+		switch t := v.(type) {
+		case json.Number:
+			s = t.String()
+		case string:
+			s = strings.TrimSpace(t)
+		case bool:
+			if t {
+				return json.Number("1"), true
+			}
+			return json.Number("0"), true
+		default:
+			return nil, false
+		}
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(i, 10)), true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil && f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return json.Number(strconv.FormatInt(int64(f), 10)), true
+		}
+	case typeBool:
+		switch t := v.(type) {
+		case bool:
+			return t, true
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(t)); err == nil {
+				return b, true
+			}
+		case json.Number:
+			if f, err := t.Float64(); err == nil {
+				return f != 0, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// transformJSON apply rules on json payload in order
+func transformJSON(data []byte, rules []*rule) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return nil, errNotJSON
+	}
+
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	for _, r := range rules {
+		r.apply(v)
+	}
+
+	buf := &bytes.Buffer{}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+
+	// trim the newline appended by encoder
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
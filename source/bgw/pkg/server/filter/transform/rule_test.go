@@ -0,0 +1,114 @@
+package transform
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseRules(t *testing.T) {
+	Convey("test parse rules", t, func() {
+		rules, err := parseRules("")
+		So(err, ShouldBeNil)
+		So(len(rules), ShouldEqual, 0)
+
+		rules, err = parseRules(`[{"op":"rename","field":"a.b","to":"c"},{"op":"drop","field":"d"},{"op":"default","field":"e","value":1},{"op":"coerce","field":"f","type":"int"}]`)
+		So(err, ShouldBeNil)
+		So(len(rules), ShouldEqual, 4)
+		So(rules[0].path, ShouldResemble, []string{"a", "b"})
+
+		_, err = parseRules(`{"op":"drop"}`)
+		So(err, ShouldNotBeNil)
+
+		_, err = parseRules(`[{"op":"drop","field":""}]`)
+		So(err, ShouldNotBeNil)
+
+		_, err = parseRules(`[{"op":"drop","field":"a..b"}]`)
+		So(err, ShouldNotBeNil)
+
+		_, err = parseRules(`[{"op":"rename","field":"a","to":"b.c"}]`)
+		So(err, ShouldNotBeNil)
+
+		_, err = parseRules(`[{"op":"default","field":"a"}]`)
+		So(err, ShouldNotBeNil)
+
+		_, err = parseRules(`[{"op":"coerce","field":"a","type":"float"}]`)
+		So(err, ShouldNotBeNil)
+
+		_, err = parseRules(`[{"op":"move","field":"a"}]`)
+		So(err, ShouldNotBeNil)
+
+		_, err = parseRules(`[null]`)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestCoerce(t *testing.T) {
+	Convey("test coerce", t, func() {
+		v, ok := coerce(json.Number("1.50"), typeString)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, "1.50")
+
+		v, ok = coerce(true, typeString)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, "true")
+
+		v, ok = coerce(" 0.1 ", typeNumber)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, json.Number("0.1"))
+
+		_, ok = coerce("abc", typeNumber)
+		So(ok, ShouldBeFalse)
+
+		v, ok = coerce("12", typeInt)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, json.Number("12"))
+
+		v, ok = coerce(json.Number("3.0"), typeInt)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, json.Number("3"))
+
+		_, ok = coerce(json.Number("3.5"), typeInt)
+		So(ok, ShouldBeFalse)
+
+		v, ok = coerce("true", typeBool)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, true)
+
+		v, ok = coerce(json.Number("0"), typeBool)
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, false)
+
+		_, ok = coerce(map[string]interface{}{}, typeString)
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestTransformJSON(t *testing.T) {
+	Convey("test transform json", t, func() {
+		rules, err := parseRules(`[
+			{"op":"rename","field":"orderQty","to":"qty"},
+			{"op":"coerce","field":"qty","type":"string"},
+			{"op":"default","field":"category","value":"linear"},
+			{"op":"drop","field":"result.list.extra"},
+			{"op":"coerce","field":"result.list.price","type":"number"}
+		]`)
+		So(err, ShouldBeNil)
+
+		in := `{"orderQty":10,"result":{"list":[{"price":"1.23","extra":1},{"price":"x"}]}}`
+		out, err := transformJSON([]byte(in), rules)
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, `{"category":"linear","qty":"10","result":{"list":[{"price":1.23},{"price":"x"}]}}`)
+
+		out, err = transformJSON([]byte(`{"category":null,"big":12345678901234567890}`), rules)
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, `{"big":12345678901234567890,"category":"linear"}`)
+
+		_, err = transformJSON([]byte("a=1&b=2"), rules)
+		So(err, ShouldEqual, errNotJSON)
+
+		_, err = transformJSON([]byte(`{"a":`), rules)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,122 @@
+package transform
+
+import (
+	"context"
+	"flag"
+	"io"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"google.golang.org/grpc/metadata"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/server/filter"
+)
+
+func Init() {
+	filter.Register(filter.TransformFilterKey, newTransform) // route filter
+}
+
+type result interface {
+	GetStatus() int
+	GetData() ([]byte, error)
+	Metadata() metadata.MD
+	io.Closer
+}
+
+// transformed wraps upstream result, data is the transformed payload
+// NOTE: not compatible with response --any, which reads the raw proto message
+type transformed struct {
+	result
+	data []byte
+}
+
+// GetData get transformed data
+func (t *transformed) GetData() ([]byte, error) {
+	return t.data, nil
+}
+
+type transform struct {
+	request  []*rule
+	response []*rule
+}
+
+func newTransform() filter.Filter {
+	return &transform{}
+}
+
+// GetName returns the name of the filter
+func (t *transform) GetName() string {
+	return filter.TransformFilterKey
+}
+
+// Init transform flag parse, rules are json list, e.g.
+// --request=[{"op":"rename","field":"orderQty","to":"qty"}] --response=[{"op":"drop","field":"result.extra"}]
+func (t *transform) Init(ctx context.Context, args ...string) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	return t.parseFlags(ctx, args)
+}
+
+func (t *transform) parseFlags(ctx context.Context, args []string) (err error) {
+	var reqFlag, respFlag string
+
+	p := flag.NewFlagSet("transform", flag.ContinueOnError)
+	p.StringVar(&reqFlag, "request", "", "request body transform rules, json list")
+	p.StringVar(&respFlag, "response", "", "response body transform rules, json list")
+
+	if err = p.Parse(args[1:]); err != nil {
+		glog.Info(ctx, "transform parse error", glog.Any("args", args), glog.String("error", err.Error()))
+		return
+	}
+
+	if t.request, err = parseRules(reqFlag); err != nil {
+		return
+	}
+	t.response, err = parseRules(respFlag)
+	return
+}
+
+// Validate check transform filter args, args[0] is route key
+func Validate(ctx context.Context, args ...string) error {
+	return (&transform{}).Init(ctx, args...)
+}
+
+// Do implements the filter interface
+func (t *transform) Do(next types.Handler) types.Handler {
+	return func(ctx *types.Ctx) (err error) {
+		if len(t.request) > 0 && len(ctx.Request.Body()) > 0 {
+			body, err1 := transformJSON(ctx.Request.Body(), t.request)
+			if err1 != nil {
+				glog.Debug(ctx, "transform request skipped", glog.String("error", err1.Error()))
+			} else {
+				ctx.Request.SetBody(body)
+			}
+		}
+
+		if err = next(ctx); err != nil || len(t.response) == 0 {
+			return
+		}
+
+		source, ok := ctx.UserValue(constant.CtxInvokeResult).(result)
+		if !ok || source == nil {
+			return
+		}
+
+		data, err1 := source.GetData()
+		if err1 != nil || len(data) == 0 {
+			return
+		}
+
+		data, err1 = transformJSON(data, t.response)
+		if err1 != nil {
+			glog.Debug(ctx, "transform response skipped", glog.String("error", err1.Error()))
+			return
+		}
+
+		ctx.SetUserValue(constant.CtxInvokeResult, &transformed{result: source, data: data})
+		return
+	}
+}
@@ -0,0 +1,88 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc/metadata"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/server/filter"
+)
+
+func TestTransform_Init(t *testing.T) {
+	Convey("test transform init", t, func() {
+		Init()
+		f, err := filter.GetFilter(context.Background(), filter.TransformFilterKey)
+		So(err, ShouldBeNil)
+		So(f.GetName(), ShouldEqual, filter.TransformFilterKey)
+
+		tf := &transform{}
+		err = tf.Init(context.Background(), "route", `--request=[{"op":"drop","field":"a"}]`, `--response=[{"op":"drop","field":"b"}]`)
+		So(err, ShouldBeNil)
+		So(len(tf.request), ShouldEqual, 1)
+		So(len(tf.response), ShouldEqual, 1)
+
+		err = Validate(context.Background(), "route", `--request=[{"op":"drop"}]`)
+		So(err, ShouldNotBeNil)
+
+		err = Validate(context.Background(), "route", "--unknown=1")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestTransform_Do(t *testing.T) {
+	Convey("test transform do", t, func() {
+		tf := &transform{}
+		err := tf.Init(context.Background(), "route",
+			`--request=[{"op":"rename","field":"orderQty","to":"qty"}]`,
+			`--response=[{"op":"rename","field":"qty","to":"orderQty"}]`)
+		So(err, ShouldBeNil)
+
+		var upstream []byte
+		h := tf.Do(func(ctx *types.Ctx) error {
+			upstream = append([]byte(nil), ctx.Request.Body()...)
+			ctx.SetUserValue(constant.CtxInvokeResult, &mockResult{data: []byte(`{"qty":"1"}`)})
+			return nil
+		})
+
+		ctx := &types.Ctx{}
+		ctx.Request.SetBody([]byte(`{"orderQty":"1"}`))
+		err = h(ctx)
+		So(err, ShouldBeNil)
+		So(string(upstream), ShouldEqual, `{"qty":"1"}`)
+
+		res, ok := ctx.UserValue(constant.CtxInvokeResult).(result)
+		So(ok, ShouldBeTrue)
+		data, err := res.GetData()
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, `{"orderQty":"1"}`)
+		So(res.GetStatus(), ShouldEqual, 200)
+
+		// invalid request body pass through
+		ctx = &types.Ctx{}
+		ctx.Request.SetBody([]byte(`a=1`))
+		err = h(ctx)
+		So(err, ShouldBeNil)
+		So(string(upstream), ShouldEqual, `a=1`)
+
+		// upstream error
+		h = tf.Do(func(ctx *types.Ctx) error {
+			return errors.New("mock err")
+		})
+		err = h(&types.Ctx{})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+type mockResult struct {
+	data []byte
+}
+
+func (m *mockResult) GetStatus() int           { return 200 }
+func (m *mockResult) GetData() ([]byte, error) { return m.data, nil }
+func (m *mockResult) Metadata() metadata.MD    { return metadata.MD{} }
+func (m *mockResult) Close() error             { return nil }
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"bgw/pkg/server/cluster"
 	"bgw/pkg/server/core"
 	"bgw/pkg/service/tradingroute"
 
@@ -24,12 +26,24 @@ func (m *adminMgr) init(routeMgr core.RouteManager) {
 	gapp.RegisterAdmin("ping", "", m.onPing)
 	// curl 'http://localhost:6480/admin?cmd=routes&path=xxx&method=xxx&app=xxx'
 	gapp.RegisterAdmin("routes", "get route list, params: path=xxxx app=xxx", m.onGetRoute)
+	// curl 'http://localhost:6480/admin?cmd=outliers&state=ejected&addr=xxx'
+	gapp.RegisterAdmin("outliers", "get upstream instances ejection state, params: [state=healthy,ejected,recovering] addr=xxx", m.onGetOutliers)
+	// curl 'http://localhost:6480/admin?cmd=canary&route=xxx'
+	gapp.RegisterAdmin("canary", "get weighted backends of routes, params: route=xxx", m.onGetCanary)
+	// curl 'http://localhost:6480/admin?cmd=canary_set&route=app.module.service.registry.method&weights=GROUP_A:90,GROUP_B:10'
+	gapp.RegisterAdmin("canary_set", "set weights of route at runtime, params: route=xxx weights=group:weight,group:weight", m.onSetCanary)
+	// curl 'http://localhost:6480/admin?cmd=canary_clear&route=xxx'
+	gapp.RegisterAdmin("canary_clear", "restore configured weights of route, params: route=xxx", m.onClearCanary)
+	// curl 'http://localhost:6480/admin?cmd=openapi&app=xxx'
+	gapp.RegisterAdmin("openapi", "get openapi 3 document of loaded routes, params: app=xxx title=xxx", m.onGetOpenAPI)
 	// curl 'http://localhost:6480/admin?cmd=tradingroute&uid=xxx'
 	gapp.RegisterAdmin("tradingroute", "get route by uid", m.onGetTradingRoute)
 	// curl 'http://localhost:6480/admin?cmd=tradingroute_clear&mode=xxx&uid=xxx&scope=xxx'
 	gapp.RegisterAdmin("tradingroute_clear", "clear routing, [mode=routing,instances,all], [userId]", m.onClearTradingRoute)
 	// curl 'http://localhost:6480/admin?cmd=get_account_type&uid=xxx'
 	gapp.RegisterAdmin("get_account_type", "get account type by uid", m.onGetAccountType)
+	// curl 'http://localhost:6480/admin?cmd=limit_explain&method=GET&path=xxx&uid=xxx&apikey=xxx&symbol=xxx&n=10'
+	gapp.RegisterAdmin("limit_explain", "explain limit rules of route and simulate next n requests, params: path=xxx [method=GET] uid=xxx apikey=xxx symbol=xxx [n=10]", m.onLimitExplain)
 }
 
 func (m *adminMgr) onPing(args gapp.AdminArgs) (interface{}, error) {
@@ -90,6 +104,94 @@ func (m *adminMgr) onGetRoute(args gapp.AdminArgs) (interface{}, error) {
 	return res, nil
 }
 
+// 查询上游实例被动健康检查摘除状态,可以指定state,addr过滤
+func (m *adminMgr) onGetOutliers(args gapp.AdminArgs) (interface{}, error) {
+	state := args.GetStringBy("state")
+	addr := args.GetStringBy("addr")
+
+	res := make([]*cluster.OutlierStatus, 0)
+	for _, st := range cluster.OutlierSnapshot() {
+		if state != "" && st.State != state {
+			continue
+		}
+		if addr != "" && !wildcard.Match(addr, st.Addr) {
+			continue
+		}
+		res = append(res, st)
+	}
+
+	return res, nil
+}
+
+// 查询按权重灰度的路由,可以指定route模糊匹配
+func (m *adminMgr) onGetCanary(args gapp.AdminArgs) (interface{}, error) {
+	route := args.GetStringBy("route")
+
+	res := make([]*core.CanaryState, 0)
+	for _, st := range core.GetCanaryStates() {
+		if route != "" && !wildcard.Match(route, st.Route) {
+			continue
+		}
+		res = append(res, st)
+	}
+
+	return res, nil
+}
+
+// 运行时修改路由的灰度权重,无需发布新的配置版本
+func (m *adminMgr) onSetCanary(args gapp.AdminArgs) (interface{}, error) {
+	route := args.GetStringBy("route")
+	weights := args.GetStringBy("weights")
+	if route == "" || weights == "" {
+		return nil, fmt.Errorf("need route and weights")
+	}
+
+	backends := make([]*core.Backend, 0)
+	for _, kv := range strings.Split(weights, ",") {
+		group, weight, ok := strings.Cut(kv, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid weights: %s", kv)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight: %s", kv)
+		}
+		backends = append(backends, &core.Backend{Group: strings.TrimSpace(group), Weight: w})
+	}
+
+	if err := core.SetCanaryWeights(route, backends); err != nil {
+		return nil, err
+	}
+	return backends, nil
+}
+
+func (m *adminMgr) onClearCanary(args gapp.AdminArgs) (interface{}, error) {
+	route := args.GetStringBy("route")
+	if route == "" {
+		return nil, fmt.Errorf("need route")
+	}
+
+	core.ClearCanaryWeights(route)
+	return nil, nil
+}
+
+// 根据已加载的路由配置和proto描述生成openapi文档,可以指定app模糊匹配
+func (m *adminMgr) onGetOpenAPI(args gapp.AdminArgs) (interface{}, error) {
+	app := args.GetStringBy("app")
+	title := args.GetStringBy("title")
+	if title == "" {
+		title = "bgw"
+	}
+
+	var match func(string) bool
+	if app != "" {
+		match = func(key string) bool {
+			return wildcard.Match(app, key)
+		}
+	}
+	return core.BuildOpenAPI(context.Background(), title, match)
+}
+
 func (m *adminMgr) onGetTradingRoute(args gapp.AdminArgs) (interface{}, error) {
 	uid := args.GetInt64At(0)
 	if uid == 0 {
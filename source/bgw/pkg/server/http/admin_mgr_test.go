@@ -34,6 +34,10 @@ func (m *mockRoutMgr) Load(appConf *core.AppConfig) error {
 	return nil
 }
 
+func (m *mockRoutMgr) GetMethodConfig(route *core.Route) *core.MethodConfig {
+	return nil
+}
+
 func (m *mockRoutMgr) Routes() []*groute.Route {
 	r1 := &core.Route{
 		AppKey: "uta",
@@ -124,6 +128,21 @@ func TestAdminMgr(t *testing.T) {
 		_, err = am.onGetRoute(args)
 		So(err, ShouldBeNil)
 
+		args = gapp.AdminArgs{
+			Options: map[string]string{"state": "ejected", "addr": "127.0.0.1:*"},
+		}
+		_, err = am.onGetOutliers(args)
+		So(err, ShouldBeNil)
+
+		_, err = am.onGetCanary(gapp.AdminArgs{})
+		So(err, ShouldBeNil)
+		_, err = am.onSetCanary(gapp.AdminArgs{Options: map[string]string{"route": "a.b.c.d.e", "weights": "GROUP_A:1"}})
+		So(err, ShouldNotBeNil)
+		_, err = am.onSetCanary(gapp.AdminArgs{Options: map[string]string{"route": "a.b.c.d.e", "weights": "GROUP_A"}})
+		So(err, ShouldNotBeNil)
+		_, err = am.onClearCanary(gapp.AdminArgs{Options: map[string]string{"route": "a.b.c.d.e"}})
+		So(err, ShouldBeNil)
+
 		_, err = am.onGetTradingRoute(gapp.AdminArgs{})
 		So(err, ShouldNotBeNil)
 
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"code.bydev.io/fbu/gateway/gway.git/gapp"
+	"git.bybit.com/svc/mod/pkg/bplatform"
+	"github.com/valyala/fasthttp"
+
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/server/core"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/filter/openapi"
+	"bgw/pkg/server/metadata"
+	"bgw/pkg/service/symbolconfig"
+)
+
+const (
+	defaultExplainNext = 10
+	maxExplainNext     = 100
+)
+
+// limitExplain 路由上限流规则的解释结果
+type limitExplain struct {
+	Route    string           `json:"route"`
+	Path     string           `json:"path"`
+	Priority string           `json:"priority"`
+	UID      int64            `json:"uid"`
+	Filters  []*filterExplain `json:"filters"`
+}
+
+type filterExplain struct {
+	Name    string      `json:"name"`
+	Args    string      `json:"args,omitempty"`
+	Explain interface{} `json:"explain,omitempty"` // 仅实现了filter.Explainer的filter有值
+	Error   string      `json:"error,omitempty"`
+}
+
+// 模拟请求,查看路由命中的限流规则,生效的额度,redis中剩余额度,以及之后n次请求是否会被限流,不消耗额度
+func (m *adminMgr) onLimitExplain(args gapp.AdminArgs) (interface{}, error) {
+	if m.routeMgr == nil {
+		return nil, fmt.Errorf("empty route mgr")
+	}
+
+	path := args.GetStringBy("path")
+	if path == "" {
+		return nil, fmt.Errorf("need path")
+	}
+	method := strings.ToUpper(args.GetStringBy("method"))
+	if method == "" {
+		method = fasthttp.MethodGet
+	}
+	uid := args.GetInt64By("uid")
+	apikey := args.GetStringBy("apikey")
+	symbol := args.GetStringBy("symbol")
+	n := int(args.GetInt64By("n"))
+	if n <= 0 {
+		n = defaultExplainNext
+	}
+	if n > maxExplainNext {
+		n = maxExplainNext
+	}
+
+	ctx := &types.Ctx{}
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(path)
+	if apikey != "" {
+		ctx.Request.Header.Set(constant.HeaderAPIKey, apikey)
+	}
+	if symbol != "" {
+		types.ContextWithRequestSave(ctx, symbolconfig.Symbol, symbol)
+	}
+
+	if uid == 0 && apikey != "" {
+		memberID, err := openapi.GetMemberID(ctx, apikey)
+		if err != nil {
+			return nil, fmt.Errorf("get uid by apikey error: %w", err)
+		}
+		uid = memberID
+	}
+	userFn := func(*types.Ctx) (int64, bool, error) {
+		return uid, false, nil
+	}
+
+	route, err := m.routeMgr.FindRoute(ctx, core.NewCtxRouteDataProvider(ctx, userFn, getAccountTypeByUID))
+	if err != nil {
+		return nil, err
+	}
+	mc := m.routeMgr.GetMethodConfig(route)
+	if mc == nil {
+		return nil, fmt.Errorf("route not found: %s %s", method, path)
+	}
+
+	md := metadata.MDFromContext(ctx)
+	md.UID = uid
+	md.Route = mc.RouteKey()
+	md.Method = method
+	md.Path = path
+	md.StaticRoutePath = route.Path
+	if apikey != "" {
+		md.Extension.Platform = string(bplatform.OpenAPI)
+	}
+	if uid > 0 {
+		if at, err := getAccountTypeByUID(context.Background(), uid); err == nil {
+			md.UnifiedTrading = at == constant.AccountTypeUnifiedTrading
+			md.UnifiedMargin = at == constant.AccountTypeUnifiedMargin
+		}
+	}
+
+	res := &limitExplain{
+		Route:    md.Route.String(),
+		Path:     route.Path,
+		Priority: mc.GetPriority(),
+		UID:      uid,
+	}
+	// 使用路由上正在运行的filter实例,不重新创建和Init filter
+	for _, ff := range mc.GetFilters() {
+		fe := &filterExplain{Name: ff.Name, Args: ff.Args}
+		res.Filters = append(res.Filters, fe)
+
+		f := mc.GetRouteFilter(ff.Name)
+		if f == nil {
+			fe.Error = "route filter not created"
+			continue
+		}
+		if e, ok := f.(filter.Explainer); ok {
+			fe.Explain = e.Explain(ctx, n)
+		}
+	}
+
+	return res, nil
+}
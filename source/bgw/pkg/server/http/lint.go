@@ -0,0 +1,141 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"bgw/pkg/server/core"
+	"bgw/pkg/server/filter"
+)
+
+// LintIssue is a problem found in route config file
+type LintIssue struct {
+	File  string `json:"file"`
+	Route string `json:"route,omitempty"`
+	Err   error  `json:"-"`
+}
+
+// String format issue as "file [route]: error"
+func (i *LintIssue) String() string {
+	if i.Route == "" {
+		return i.File + ": " + i.Err.Error()
+	}
+	return i.File + " [" + i.Route + "]: " + i.Err.Error()
+}
+
+// Lint check route config files offline before they are uploaded,
+// files is file name -> yaml content.
+// http modules get the same static check as web console, other modules get the method and filter checks,
+// if initFilters is set, Init of every route filter is called in dry run mode to validate args,
+// at last all routes are loaded together to find conflicts, such as duplicate all-in-one or default routes.
+func Lint(ctx context.Context, files map[string]string, initFilters bool) ([]*core.AppConfig, []*LintIssue) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := newWebConsole(ctx)
+	apps := make([]*core.AppConfig, 0, len(names))
+	appFiles := make(map[string]string, len(names))
+	issues := make([]*LintIssue, 0)
+	for _, name := range names {
+		ac, err := w.lintPreCheck(name, files[name])
+		if err != nil {
+			issues = append(issues, &LintIssue{File: name, Err: err})
+			continue
+		}
+		if f, ok := appFiles[ac.Key()]; ok {
+			issues = append(issues, &LintIssue{File: name, Err: fmt.Errorf("app %s already defined in %s", ac.Key(), f)})
+			continue
+		}
+		if initFilters {
+			issues = append(issues, lintFilters(ctx, name, ac)...)
+		}
+		apps = append(apps, ac)
+		appFiles[ac.Key()] = name
+	}
+
+	errs := core.CheckRoutes(apps)
+	for _, ac := range apps {
+		if err, ok := errs[ac.Key()]; ok {
+			issues = append(issues, &LintIssue{File: appFiles[ac.Key()], Err: fmt.Errorf("route conflict: %w", err)})
+		}
+	}
+
+	return apps, issues
+}
+
+// lintPreCheck is staticPreCheck for all modules, grpc modules are not uploaded by web console,
+// so only the methods which have http path are checked for uniqueness
+func (w *webConsole) lintPreCheck(key, content string) (*core.AppConfig, error) {
+	ac := &core.AppConfig{}
+	if err := ac.Unmarshal(strings.NewReader(content), "yaml"); err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(ac.Module, "-http") {
+		return w.staticPreCheck(key, content)
+	}
+
+	if ac.App == "" || ac.Module == "" {
+		return nil, errors.New("config fill error, nil app or module")
+	}
+
+	uniqueRegisterMgr := &unique{
+		ctx:            w.ctx,
+		uniqueRegister: make(map[string]struct{}, 5),
+	}
+	for _, service := range ac.Services {
+		if service.Registry == "" {
+			return nil, errors.New("config fill error, nil registry")
+		}
+		for _, method := range service.Methods {
+			if method.Path != "" || len(method.Paths) > 0 {
+				if err := w.checkConfigPath(method, uniqueRegisterMgr); err != nil {
+					return nil, err
+				}
+			}
+			if err := w.checkFilters(method); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return ac, nil
+}
+
+// lintFilters create every filter of enabled methods with dry run Init, the same way as route chain does
+func lintFilters(ctx context.Context, file string, ac *core.AppConfig) []*LintIssue {
+	ctx = filter.WithDryRun(ctx)
+	issues := make([]*LintIssue, 0)
+	for _, service := range ac.Services {
+		for _, method := range service.Methods {
+			if method.Disable {
+				continue
+			}
+			rk := method.RouteKey().String()
+			for _, f := range method.GetFilters() {
+				args := append([]string{rk}, f.GetArgs()...)
+				if err := lintFilter(ctx, f.Name, args); err != nil {
+					issues = append(issues, &LintIssue{File: file, Route: rk, Err: err})
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// lintFilter create filter with dry run Init, panic of filter Init is reported as an issue
+func lintFilter(ctx context.Context, name string, args []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("filter %s init panic: %v", name, r)
+		}
+	}()
+
+	_, err = filter.GetFilter(ctx, name, args...)
+	return
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"bgw/pkg/common/types"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/filter/response"
+)
+
+const lintPanicFilterKey = "FILTER_LINT_PANIC"
+
+type lintPanicFilter struct{}
+
+func (f *lintPanicFilter) GetName() string { return lintPanicFilterKey }
+
+func (f *lintPanicFilter) Do(next types.Handler) types.Handler { return next }
+
+func (f *lintPanicFilter) Init(ctx context.Context, args ...string) error { panic("boom") }
+
+const lintRouteConfig = `
+app: option
+module: lint-grpc
+services:
+  - registry: LintService
+    name: LintService
+    protocol: grpc
+    methods:
+      - name: Ok
+        filters:
+          - name: FILTER_RESPONSE
+            args: '--version=v2'
+      - name: BadArgs
+        filters:
+          - name: FILTER_RESPONSE
+            args: '--wrongArgs=1'
+      - name: Panic
+        filters:
+          - name: FILTER_LINT_PANIC
+`
+
+func TestLint(t *testing.T) {
+	response.Init()
+	filter.Register(lintPanicFilterKey, func() filter.Filter { return &lintPanicFilter{} })
+
+	Convey("test lint filters", t, func() {
+		ctx := context.Background()
+		So(lintFilter(ctx, "FILTER_NOT_EXIST", []string{"route"}), ShouldNotBeNil)
+		So(lintFilter(ctx, lintPanicFilterKey, []string{"route"}).Error(), ShouldContainSubstring, "panic: boom")
+
+		// response filter does not listen nacos in dry run
+		So(lintFilter(filter.WithDryRun(ctx), filter.ResponseFilterKey, []string{"route", "--version=v2"}), ShouldBeNil)
+	})
+
+	Convey("test lint route config", t, func() {
+		apps, issues := Lint(context.Background(), map[string]string{"option_lint-grpc.yaml": lintRouteConfig}, true)
+		So(len(apps), ShouldEqual, 1)
+		So(len(issues), ShouldEqual, 2)
+
+		routes := make([]string, 0, len(issues))
+		for _, issue := range issues {
+			So(issue.File, ShouldEqual, "option_lint-grpc.yaml")
+			routes = append(routes, issue.Route)
+		}
+		joined := strings.Join(routes, ",")
+		So(joined, ShouldContainSubstring, "BadArgs")
+		So(joined, ShouldContainSubstring, "Panic")
+		So(joined, ShouldNotContainSubstring, "Ok")
+
+		// filters are not initialized
+		_, issues = Lint(context.Background(), map[string]string{"option_lint-grpc.yaml": lintRouteConfig}, false)
+		So(len(issues), ShouldEqual, 0)
+	})
+}
@@ -30,6 +30,8 @@ import (
 	"bgw/pkg/config_center/nacos"
 	retcd "bgw/pkg/remoting/etcd"
 	"bgw/pkg/server/core"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/filter/transform"
 )
 
 const (
@@ -290,6 +292,10 @@ func (w *webConsole) staticPreCheck(key, content string) (*core.AppConfig, error
 				glog.Error(w.ctx, "checkConfigPath error", glog.String("file", key), glog.String("error", err.Error()))
 				return nil, err
 			}
+			if err := w.checkFilters(method); err != nil {
+				glog.Error(w.ctx, "checkFilters error", glog.String("file", key), glog.String("error", err.Error()))
+				return nil, err
+			}
 		}
 		if !env.IsProduction() {
 			// test env service discovery namespace and group
@@ -360,6 +366,26 @@ func (w *webConsole) checkConfigPath(method *core.MethodConfig, uniqueRegisterMg
 	return nil
 }
 
+// checkFilters check filter args which can be validated statically
+func (w *webConsole) checkFilters(method *core.MethodConfig) error {
+	filters := make([]core.Filter, 0, len(method.Filters)+len(method.Service().GetFilters()))
+	filters = append(filters, method.Service().GetFilters()...)
+	filters = append(filters, method.Filters...)
+	for _, f := range filters {
+		if f.Disable {
+			continue
+		}
+		switch f.Name {
+		case filter.TransformFilterKey:
+			args := append([]string{method.RouteKey().String()}, f.GetArgs()...)
+			if err := transform.Validate(w.ctx, args...); err != nil {
+				return fmt.Errorf("filter %s args error: %w", f.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
 type unique struct {
 	ctx            context.Context
 	uniqueRegister map[string]struct{}
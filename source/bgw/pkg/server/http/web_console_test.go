@@ -20,6 +20,7 @@ import (
 
 	"bgw/pkg/common/bhttp"
 	"bgw/pkg/server/core"
+	"bgw/pkg/server/filter"
 
 	"github.com/tj/assert"
 
@@ -458,3 +459,43 @@ module: 1212-http`
 
 	})
 }
+
+func TestCheckFilters(t *testing.T) {
+	console := newWebConsole(context.Background())
+	ac := &core.AppConfig{
+		App:    "asaas",
+		Module: "sas-http",
+		Services: []*core.ServiceConfig{
+			{
+				Registry: "xxxx",
+				Methods: []*core.MethodConfig{
+					{
+						HttpMethod: "POST",
+						Path:       "as",
+						Filters: []core.Filter{
+							{Name: filter.TransformFilterKey, Args: `--request=[{"op":"rename","field":"a","to":"b"}]`},
+						},
+					},
+					{
+						HttpMethod: "POST",
+						Path:       "as2",
+						Filters: []core.Filter{
+							{Name: filter.TransformFilterKey, Args: `--request=[{"op":"rename","field":"a"}]`},
+						},
+					},
+				},
+			},
+		},
+	}
+	ac.Integrate()
+
+	err := console.checkFilters(ac.Services[0].Methods[0])
+	assert.NoError(t, err)
+
+	err = console.checkFilters(ac.Services[0].Methods[1])
+	assert.Error(t, err)
+
+	ac.Services[0].Methods[1].Filters[0].Disable = true
+	err = console.checkFilters(ac.Services[0].Methods[1])
+	assert.NoError(t, err)
+}
@@ -134,6 +134,24 @@ type ACL struct {
 	Groups     []string `json:"groups,omitempty" yaml:"groups"`
 }
 
+// route priorities of load shedding, from high to low, route without priority is never shed
+const (
+	PriorityTrading = "trading"
+	PriorityAccount = "account"
+	PriorityMarket  = "market"
+	PriorityMisc    = "misc"
+)
+
+// IsValidPriority check route priority
+func IsValidPriority(p string) bool {
+	switch p {
+	case PriorityTrading, PriorityAccount, PriorityMarket, PriorityMisc:
+		return true
+	default:
+		return false
+	}
+}
+
 // RouteKey setup in context, used in filters
 type RouteKey struct {
 	Protocol    string
@@ -146,6 +164,7 @@ type RouteKey struct {
 	Group       string
 	ACL         ACL
 	Category    string
+	Priority    string // load shedding priority
 	AllApp      bool
 	AppCfg      AppCfg
 }
@@ -52,6 +52,7 @@ type Acceptor interface {
 	Extensions() map[string]string // 扩展信息
 	LastWriteFailTime() int64      // 最后一次写失败时间
 	SendChannelRate() float64      // channel使用率
+	Standby() bool                 // 是否为standby节点,standby节点不同步用户
 
 	Send(msg *envelopev1.SubscribeResponse) error
 	// SendAdmin 发送admin消息,会阻塞等待返回结果,超时会返回timeout error
@@ -112,6 +113,7 @@ type acceptor struct {
 	extensions        map[string]string // 扩展信息
 	lastWriteFailTime atomic.Int64      // 最后一次写入管道失败时间
 	publicTopics      []string          // 公有推送topic
+	standby           atomic.Bool       // 分组中的备用节点
 
 	wg      sync.WaitGroup
 	sendCh  chan *envelopev1.SubscribeResponse
@@ -131,6 +133,10 @@ func (a *acceptor) SendChannelRate() float64 {
 	return 100.0 * float64(len(a.sendCh)) / float64(cap(a.sendCh))
 }
 
+func (a *acceptor) Standby() bool {
+	return a.standby.Load()
+}
+
 func (a *acceptor) LastWriteFailTime() int64 {
 	return a.lastWriteFailTime.Load()
 }
@@ -368,6 +374,21 @@ func (a *acceptor) sendLeftMessage() {
 	}
 }
 
+// drainPending 取出关闭后未发送的消息,用于切换时重放给standby节点,admin消息等待方已经超时,直接丢弃
+func (a *acceptor) drainPending() []*envelopev1.SubscribeResponse {
+	var res []*envelopev1.SubscribeResponse
+	for {
+		select {
+		case v := <-a.sendCh:
+			if v.Cmd != envelopev1.Command_COMMAND_ADMIN {
+				res = append(res, v)
+			}
+		default:
+			return res
+		}
+	}
+}
+
 func (a *acceptor) onStop() {
 	a.Close()
 }
@@ -19,6 +19,7 @@ func newAcceptorMgr() *acceptorMgr {
 	m := &acceptorMgr{
 		acceptorMap: make(map[string]Acceptor),
 		topics:      make(map[string][]Acceptor),
+		groups:      make(map[string][]*acceptor),
 	}
 	return m
 }
@@ -29,20 +30,28 @@ type AcceptorMgr interface {
 	GetByIndex(index int) Acceptor
 	GetByAppID(id string) []Acceptor
 	GetAll() []Acceptor
+	GetStandbys() []Acceptor
 	GetByTopics(topics []string) []Acceptor
 }
 
 type acceptorMgr struct {
-	acceptors   []Acceptor            // add or remove will copy a new list
-	acceptorMap map[string]Acceptor   // id -> acceptor
-	topics      map[string][]Acceptor // topic -> acceptor list
+	acceptors   []Acceptor             // active acceptor, add or remove will copy a new list
+	standbys    []Acceptor             // standby acceptor, 不参与路由和用户同步
+	acceptorMap map[string]Acceptor    // id -> acceptor, 包含standby
+	topics      map[string][]Acceptor  // topic -> active acceptor list
+	groups      map[string][]*acceptor // group key -> 按加入顺序排列的成员
 	mux         deadlock.RWMutex
 }
 
+// acceptorGroupKey 相同appid和用户分片的acceptor属于同一个分组
+func acceptorGroupKey(a Acceptor) string {
+	return fmt.Sprintf("%s/%d/%d", a.AppID(), a.UserShardIndex(), a.UserShardTotal())
+}
+
 func (m *acceptorMgr) Size() int {
 	m.mux.RLock()
 	defer m.mux.RUnlock()
-	return len(m.acceptors)
+	return len(m.acceptorMap)
 }
 
 func (m *acceptorMgr) Get(id string) Acceptor {
@@ -70,10 +79,16 @@ func (m *acceptorMgr) GetByAppID(appId string) []Acceptor {
 			res = append(res, a)
 		}
 	}
+	for _, a := range m.standbys {
+		if a.AppID() == appId {
+			res = append(res, a)
+		}
+	}
 	m.mux.RUnlock()
 	return res
 }
 
+// GetAll 返回所有active的acceptor
 func (m *acceptorMgr) GetAll() []Acceptor {
 	m.mux.RLock()
 	res := m.acceptors
@@ -81,6 +96,13 @@ func (m *acceptorMgr) GetAll() []Acceptor {
 	return res
 }
 
+func (m *acceptorMgr) GetStandbys() []Acceptor {
+	m.mux.RLock()
+	res := m.standbys
+	m.mux.RUnlock()
+	return res
+}
+
 func (m *acceptorMgr) GetByTopics(topics []string) []Acceptor {
 	m.mux.RLock()
 	defer m.mux.RUnlock()
@@ -113,8 +135,9 @@ func (m *acceptorMgr) GetByTopics(topics []string) []Acceptor {
 func (m *acceptorMgr) Close() {
 	glog.Info(context.Background(), "start to stop acceptor_mgr")
 	m.mux.Lock()
-	acceptors := make([]Acceptor, 0, len(m.acceptors))
+	acceptors := make([]Acceptor, 0, len(m.acceptorMap))
 	acceptors = append(acceptors, m.acceptors...)
+	acceptors = append(acceptors, m.standbys...)
 	m.mux.Unlock()
 
 	for _, acc := range acceptors {
@@ -151,39 +174,70 @@ func (m *acceptorMgr) Add(a *acceptor) error {
 		return fmt.Errorf("duplicate acceptor id: %v", a.ID())
 	}
 
-	acceptors := make([]Acceptor, len(m.acceptors)+1)
-	copy(acceptors, m.acceptors)
-	acceptors[len(m.acceptors)] = a
-
-	m.acceptors = acceptors
 	m.acceptorMap[a.ID()] = a
+	key := acceptorGroupKey(a)
+	group := m.groups[key]
+	m.groups[key] = append(group, a)
+
+	// 分组中已经有active节点,新加入的作为standby
+	if len(group) > 0 && getDynamicConf().EnableAcceptorStandby {
+		a.standby.Store(true)
+		m.standbys = appendAcceptor(m.standbys, a)
+		glog.Info(context.Background(), "acceptor_mgr add standby acceptor", glog.String("id", a.ID()), glog.String("group", key))
+		return nil
+	}
+
+	m.addActive(a)
+	return nil
+}
 
+func (m *acceptorMgr) addActive(a *acceptor) {
+	m.acceptors = appendAcceptor(m.acceptors, a)
 	for _, t := range a.topics {
 		m.topics[t] = append(m.topics[t], a)
 	}
-
-	return nil
 }
 
 func (m *acceptorMgr) Remove(id string) {
+	old, next := m.remove(id)
+	if old != nil && next != nil {
+		m.handoff(old, next)
+	}
+}
+
+// remove 删除acceptor,如果删除的是active节点,则提升分组中的第一个standby节点
+func (m *acceptorMgr) remove(id string) (old, next *acceptor) {
 	m.mux.Lock()
 	defer m.mux.Unlock()
-	_, ok := m.acceptorMap[id]
+	acc, ok := m.acceptorMap[id]
 	if !ok {
-		return
+		return nil, nil
 	}
 
 	delete(m.acceptorMap, id)
 	glog.Infof(context.Background(), "acceptor_mgr remove acceptor, id: %v, left: %v", id, len(m.acceptorMap))
 
-	acceptors := make([]Acceptor, 0, len(m.acceptors)-1)
-	for _, a := range m.acceptors {
-		if a.ID() != id {
-			acceptors = append(acceptors, a)
+	gkey := acceptorGroupKey(acc)
+	group := make([]*acceptor, 0, len(m.groups[gkey]))
+	for _, a := range m.groups[gkey] {
+		if a.ID() == id {
+			old = a
+		} else {
+			group = append(group, a)
 		}
 	}
-	m.acceptors = acceptors
+	if len(group) > 0 {
+		m.groups[gkey] = group
+	} else {
+		delete(m.groups, gkey)
+	}
+
+	if acc.Standby() {
+		m.standbys = removeAcceptor(m.standbys, id)
+		return nil, nil
+	}
 
+	m.acceptors = removeAcceptor(m.acceptors, id)
 	for key, list := range m.topics {
 		for idx, ac := range list {
 			if ac.ID() == id {
@@ -193,6 +247,58 @@ func (m *acceptorMgr) Remove(id string) {
 			}
 		}
 	}
+
+	for _, a := range group {
+		if a.Standby() {
+			next = a
+			break
+		}
+	}
+	if old == nil || next == nil {
+		return nil, nil
+	}
+
+	next.standby.Store(false)
+	m.standbys = removeAcceptor(m.standbys, next.ID())
+	m.addActive(next)
+	return old, next
+}
+
+// handoff standby节点接管分片,重放旧节点未发送的消息,并全量同步用户
+func (m *acceptorMgr) handoff(old, next *acceptor) {
+	pending := old.drainPending()
+	for _, msg := range pending {
+		_ = next.Send(msg)
+	}
+
+	WSCounterInc("acceptor", "handoff")
+	glog.Info(context.Background(), "acceptor_mgr standby take over",
+		glog.String("app_id", next.AppID()),
+		glog.String("old_id", old.ID()),
+		glog.String("new_id", next.ID()),
+		glog.Int("pending", len(pending)),
+	)
+
+	_ = DispatchEvent(&SyncConfigEvent{acceptorID: next.ID()})
+	_ = DispatchEvent(NewSyncAllUserEvent(next.ID()))
+}
+
+// appendAcceptor 复制一个新的列表,避免影响读取中的列表
+func appendAcceptor(list []Acceptor, a Acceptor) []Acceptor {
+	res := make([]Acceptor, len(list)+1)
+	copy(res, list)
+	res[len(list)] = a
+	return res
+}
+
+func removeAcceptor(list []Acceptor, id string) []Acceptor {
+	res := make([]Acceptor, 0, len(list))
+	for _, a := range list {
+		if a.ID() != id {
+			res = append(res, a)
+		}
+	}
+	return res
 }
 
 func (m *acceptorMgr) RefreshAppIDGauge(appID string) {
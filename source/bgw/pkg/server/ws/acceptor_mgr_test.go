@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"code.bydev.io/fbu/gateway/gway.git/glog"
+	envelopev1 "code.bydev.io/fbu/gateway/proto.git/pkg/envelope/v1"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,3 +44,49 @@ func TestAcceptorMgr(t *testing.T) {
 
 	m.Close()
 }
+
+func TestAcceptorMgrStandby(t *testing.T) {
+	glog.SetLevel(glog.FatalLevel)
+
+	sconf := getDynamicConf()
+	sconf.EnableAcceptorStandby = true
+	defer func() { sconf.EnableAcceptorStandby = false }()
+
+	topics := []string{"private.order"}
+	m := newAcceptorMgr()
+	acc1 := newAcceptor(nil, "linear_0_a", "linear", topics, &acceptorOptions{ShardIndex: 0, ShardTotal: 2})
+	acc2 := newAcceptor(nil, "linear_0_b", "linear", topics, &acceptorOptions{ShardIndex: 0, ShardTotal: 2})
+	acc3 := newAcceptor(nil, "linear_1_a", "linear", topics, &acceptorOptions{ShardIndex: 1, ShardTotal: 2})
+	assert.Nil(t, m.Add(acc1))
+	assert.Nil(t, m.Add(acc2))
+	assert.Nil(t, m.Add(acc3))
+
+	assert.False(t, acc1.Standby())
+	assert.True(t, acc2.Standby())
+	assert.False(t, acc3.Standby())
+	assert.Equal(t, 3, m.Size())
+	assert.Equal(t, 2, len(m.GetAll()))
+	assert.Equal(t, 1, len(m.GetStandbys()))
+	assert.Equal(t, 3, len(m.GetByAppID("linear")))
+	assert.Equal(t, 2, len(m.GetByTopics(topics)))
+
+	// active断开,standby接管并重放未发送的消息
+	acc1.sendCh <- &envelopev1.SubscribeResponse{Cmd: envelopev1.Command_COMMAND_SYNC}
+	acc1.sendCh <- &envelopev1.SubscribeResponse{Cmd: envelopev1.Command_COMMAND_ADMIN}
+	acc2.running = 1
+	m.Remove(acc1.ID())
+	assert.False(t, acc2.Standby())
+	assert.Equal(t, 2, len(m.GetAll()))
+	assert.Equal(t, 0, len(m.GetStandbys()))
+	assert.Equal(t, 2, len(m.GetByTopics(topics)))
+	assert.Equal(t, 1, len(acc2.sendCh))
+
+	// standby断开不影响active
+	acc4 := newAcceptor(nil, "linear_1_b", "linear", topics, &acceptorOptions{ShardIndex: 1, ShardTotal: 2})
+	assert.Nil(t, m.Add(acc4))
+	assert.True(t, acc4.Standby())
+	m.Remove(acc4.ID())
+	assert.False(t, acc3.Standby())
+	assert.Equal(t, 2, len(m.GetAll()))
+	assert.Equal(t, 0, len(m.GetStandbys()))
+}
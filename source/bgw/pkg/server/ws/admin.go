@@ -30,15 +30,17 @@ func setupAdmin() {
 	gapp.RegisterAdmin("metrics", "查看SDK业务端埋点", onAdminMetrics)
 	gapp.RegisterAdmin("user_info", "查看用户数据, 参数: uid", onAdminUserInfo)
 	gapp.RegisterAdmin("sync_user", "同步用户数据, 参数: uid", onAdminSyncUser)
-	gapp.RegisterAdmin("session_per_user", "查看用户session连接数排行", onAdminSessionPerUsr)
+	gapp.RegisterAdmin("session_per_user", "查看用户session连接数排行, 参数: [cluster=true]", onAdminSessionPerUsr)
+	gapp.RegisterAdmin("user_sessions", "查看用户在集群中的连接, 参数: uid", onAdminUserSessions)
+	gapp.RegisterAdmin("cluster_nodes", "查看集群中的节点", onAdminClusterNodes)
 	gapp.RegisterAdmin("config", "查看配置", onAdminConfig)
 	gapp.RegisterAdmin("check_gray", "判断某个uid是否是灰度shard(internal/qianqian/option)", onAdminCheckGray)
 	gapp.RegisterAdmin("verify_login", "验证登录是否正常", onAdminVerifyLogin)
 	gapp.RegisterAdmin("verify_auth", "验证Auth是否正常", onAdminVerifyAuth)
-	gapp.RegisterAdmin("kick_user", "踢出用户", onAdminKickUser)
+	gapp.RegisterAdmin("kick_user", "踢出用户, 参数: uid [cluster=true]", onAdminKickUser)
 	gapp.RegisterAdmin("debug", "调试", onAdminDebug)
 	gapp.RegisterAdmin("public_info", "公有推送信息", onAdminPublicInfo)
-	gapp.RegisterAdmin("get_all_topics", "获取topic信息", onAdminGetTopics)
+	gapp.RegisterAdmin("get_all_topics", "获取topic信息, 参数: [cluster=true]", onAdminGetTopics)
 }
 
 func newAdminReq(typ envelopev1.Admin_Type, args string) *envelopev1.SubscribeResponse {
@@ -80,13 +82,17 @@ func onAdminGetAcceptorList(args gapp.AdminArgs) (interface{}, error) {
 			acceptors = append(acceptors, acc)
 		}
 	} else {
-		acceptors = GetAcceptorMgr().GetAll()
+		acceptors = append(acceptors, GetAcceptorMgr().GetAll()...)
+		acceptors = append(acceptors, GetAcceptorMgr().GetStandbys()...)
 	}
 
 	sort.Slice(acceptors, func(i, j int) bool {
 		a1 := acceptors[i]
 		a2 := acceptors[j]
 		if a1.AppID() == a2.AppID() {
+			if a1.UserShardIndex() == a2.UserShardIndex() {
+				return !a1.Standby() && a2.Standby()
+			}
 			return a1.UserShardIndex() < a2.UserShardIndex()
 		}
 
@@ -99,7 +105,7 @@ func onAdminGetAcceptorList(args gapp.AdminArgs) (interface{}, error) {
 		ShardTotal  int      `json:"shard_total"`          //
 		FocusEvents int      `json:"focus_events"`         //
 		Extensions  string   `json:"extensions,omitempty"` // 扩展信息
-		Nodes       []string `json:"nodes,omitempty"`      // 节点信息: id/ip/shardIndex/createTime/{diff}
+		Nodes       []string `json:"nodes,omitempty"`      // 节点信息: id/ip/shardIndex/state/createTime/{diff}
 	}
 
 	res := make(map[string]*AcceptorInfo)
@@ -131,8 +137,12 @@ func onAdminGetAcceptorList(args gapp.AdminArgs) (interface{}, error) {
 			b.WriteString(ext)
 		}
 
+		state := "active"
+		if acc.Standby() {
+			state = "standby"
+		}
 		createTime := acc.CreateTime().Format(time.RFC3339)
-		node := fmt.Sprintf("%s/%s/%d/%s/%s", acc.ID(), acc.Address(), acc.UserShardIndex(), createTime, b.String())
+		node := fmt.Sprintf("%s/%s/%d/%s/%s/%s", acc.ID(), acc.Address(), acc.UserShardIndex(), state, createTime, b.String())
 		info.Nodes = append(info.Nodes, node)
 	}
 
@@ -275,16 +285,20 @@ func onAdminUserInfo(args gapp.AdminArgs) (interface{}, error) {
 	}
 
 	type SessionInfo struct {
-		ID          string    `json:"id"`            // session_id
-		WriteCount  int64     `json:"write_count"`   // 总发送次数
-		DropCount   int64     `json:"drop_count"`    // 丢弃数量
-		MaxIdleTime string    `json:"max_idle_time"` // 最大空闲时间
-		StartTime   time.Time `json:"start_time"`    // 起始时间
-		Duration    string    `json:"duration"`      // 连接时长
-		Version     string    `json:"version"`       // 协议版本
-		Path        string    `json:"path"`          // 连接路径
-		IP          string    `json:"ip"`            // 客户端地址
-		Topics      string    `json:"topics"`        // 订阅topics
+		ID           string    `json:"id"`             // session_id
+		WriteCount   int64     `json:"write_count"`    // 总发送次数
+		DropCount    int64     `json:"drop_count"`     // 丢弃数量
+		MaxIdleTime  string    `json:"max_idle_time"`  // 最大空闲时间
+		RTT          string    `json:"rtt,omitempty"`  // 最近一次服务端心跳的往返时间
+		StartTime    time.Time `json:"start_time"`     // 起始时间
+		Duration     string    `json:"duration"`       // 连接时长
+		Version      string    `json:"version"`        // 协议版本
+		Path         string    `json:"path"`           // 连接路径
+		IP           string    `json:"ip"`             // 客户端地址
+		Topics       string    `json:"topics"`         // 订阅topics
+		QueueSize    int       `json:"queue_size"`     // 发送队列当前深度
+		MaxQueueSize int64     `json:"max_queue_size"` // 发送队列最大深度
+		SnapshotOnly bool      `json:"snapshot_only"`  // 是否降级为只推送全量数据
 	}
 
 	type UserInfo struct {
@@ -314,16 +328,20 @@ func onAdminUserInfo(args gapp.AdminArgs) (interface{}, error) {
 				st := s.GetStartTime()
 				status := s.GetStatus()
 				session := SessionInfo{
-					ID:          s.ID(),
-					WriteCount:  status.WriteCount,
-					DropCount:   status.DropCount,
-					MaxIdleTime: status.MaxIdleTime.String(),
-					StartTime:   st,
-					Duration:    time.Since(st).String(),
-					Version:     s.ProtocolVersion().String(),
-					Path:        client.GetPath(),
-					IP:          client.GetIP(),
-					Topics:      strings.Join(client.GetTopics().Values(), ","),
+					ID:           s.ID(),
+					WriteCount:   status.WriteCount,
+					DropCount:    status.DropCount,
+					MaxIdleTime:  status.MaxIdleTime.String(),
+					RTT:          formatRTT(status.RTT),
+					StartTime:    st,
+					Duration:     time.Since(st).String(),
+					Version:      s.ProtocolVersion().String(),
+					Path:         client.GetPath(),
+					IP:           client.GetIP(),
+					Topics:       strings.Join(client.GetTopics().Values(), ","),
+					QueueSize:    status.QueueSize,
+					MaxQueueSize: status.MaxQueueSize,
+					SnapshotOnly: status.SnapshotOnly,
 				}
 				item.Sessions = append(item.Sessions, session)
 			}
@@ -419,43 +437,26 @@ func onAdminSessionPerUsr(args gapp.AdminArgs) (interface{}, error) {
 		User         int64  `json:"uid,omitempty"`
 	}
 
-	apiKeyHelper := make(map[string]int64)
-	uidHelper := make(map[string]int64)
-	mgr := GetUserMgr()
-	if mgr == nil {
-		return nil, nil
-	}
-	for _, v := range mgr.GetAllUsers() {
-		for _, s := range v.GetSessions() {
-			cli := s.GetClient()
-			if cli == nil {
-				continue
-			}
-			key := cli.GetAPIKey()
-			if key == "" {
-				continue
-			}
-
-			if _, ok := apiKeyHelper[key]; ok {
-				apiKeyHelper[key] += 1
-			} else {
-				apiKeyHelper[key] = 1
-				uidHelper[key] = v.GetMemberID()
-			}
+	stats := localAPIKeyStats()
+	if isClusterArgs(args) {
+		var err error
+		stats, err = gSessionDir.APIKeyStats(context.Background())
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	if len(apiKeyHelper) == 0 {
-		return apiKeyHelper, nil
+	if len(stats) == 0 {
+		return stats, nil
 	}
 
 	var res []Result
 
-	for k, v := range apiKeyHelper {
+	for k, v := range stats {
 		res = append(res, Result{
 			ApiKey:       k,
-			SessionCount: v,
-			User:         uidHelper[k],
+			SessionCount: v.Count,
+			User:         v.UID,
 		})
 	}
 
@@ -549,13 +550,45 @@ func onAdminKickUser(args gapp.AdminArgs) (interface{}, error) {
 		return nil, fmt.Errorf("invalid uid")
 	}
 
-	user := GetUserMgr().GetUser(uid)
-	sessions := user.GetSessions()
-	for _, sess := range sessions {
-		sess.Stop()
+	if isClusterArgs(args) {
+		nodes, err := gSessionDir.Kick(context.Background(), uid)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"uid": uid, "nodes": nodes}, nil
+	}
+
+	return map[string]interface{}{"uid": uid, "size": kickLocalUser(uid)}, nil
+}
+
+// onAdminUserSessions 查看用户在集群所有节点上的连接
+func onAdminUserSessions(args gapp.AdminArgs) (interface{}, error) {
+	uid := args.GetInt64At(0)
+	if uid == 0 {
+		uid = args.GetInt64By("uid")
+	}
+
+	if uid == 0 {
+		return nil, fmt.Errorf("invalid uid")
+	}
+
+	sessions, err := gSessionDir.UserSessions(context.Background(), uid)
+	if err != nil {
+		return nil, err
 	}
 
-	return map[string]interface{}{"uid": uid, "size": len(sessions)}, nil
+	limit := getDynamicConf().MaxSessionsPerUser
+	return map[string]interface{}{
+		"uid":      uid,
+		"size":     len(sessions),
+		"limit":    limit,
+		"exceeded": limit > 0 && len(sessions) > limit,
+		"sessions": sessions,
+	}, nil
+}
+
+func onAdminClusterNodes(args gapp.AdminArgs) (interface{}, error) {
+	return gSessionDir.Nodes(context.Background())
 }
 
 func onAdminDebug(args gapp.AdminArgs) (interface{}, error) {
@@ -591,5 +624,14 @@ func onAdminPublicInfo(args gapp.AdminArgs) (interface{}, error) {
 }
 
 func onAdminGetTopics(args gapp.AdminArgs) (interface{}, error) {
+	if isClusterArgs(args) {
+		return gSessionDir.Topics(context.Background())
+	}
+
 	return gConfigMgr.GetAllTopics(), nil
 }
+
+// isClusterArgs 是否查询整个集群,需要开启session目录
+func isClusterArgs(args gapp.AdminArgs) bool {
+	return args.GetStringBy("cluster") == "true"
+}
@@ -25,6 +25,8 @@ const (
 	defaultAcceptorBufferSize   = 40960
 	defaultMaxMetricsSize       = 40960 * 4
 	defaultInputDataSize        = 4096
+	defaultResumeTTL            = time.Minute * 2
+	defaultMaxRPCPerSession     = 10
 	// 这些topic会定时推送,默认自动屏蔽
 	defaultPushTopicBlacklist = `
 	private.position,private.wallet,
@@ -87,6 +89,7 @@ type AppConf struct {
 	AuthTickEnable           bool          `json:"auth_tick_enable,default=true"`              // 是否禁用定时auth校验
 	MaxSnapshotSize          int           `json:"max_snapshot_size,default=20"`               // 队列中最大snapshot数量,过大会积压,过小会频繁全量同步
 	StopWaitTime             time.Duration `json:"stop_wait_time,default=20s"`                 // 服务停止等待时间,用于优雅下线
+	EnableSessionDirectory   bool          `json:"enable_session_directory,default=false"`     // 是否开启集群session目录,使用middleware.toml中的Redis
 }
 
 func (a *AppConf) SetDefaultTesting() {
@@ -156,17 +159,18 @@ type RPCServerConf struct {
 // WSServerConf
 // nolint
 type WSServerConf struct {
-	ListenPort         int           `json:"listen_port,default=8081"`          // 监听端口
-	Compression        bool          `json:"compression,default=true"`          //
-	ReadTimeout        time.Duration `json:"read_timeout,default=60s"`          //
-	WriteTimeout       time.Duration `json:"write_timeout,default=6s"`          //
-	IdleTimeout        time.Duration `json:"idle_timeout,default=10s"`          //
-	ReadBufferSize     int           `json:"read_buffer_size,default=81920"`    //
-	WriteBufferSize    int           `json:"write_buffer_size,default=1024000"` //
-	MaxRequestBodySize int           `json:"max_request_body_size,default=4"`   // 单位M
-	Routes             []string      `json:"routes"`                            //
-	EnableRegistry     bool          `json:"enable_registry,default=false"`     // 是否开启服务注册
-	ServiceName        string        `json:"service_name,optional"`             // 服务名后缀
+	ListenPort         int                `json:"listen_port,default=8081"`          // 监听端口
+	Compression        bool               `json:"compression,default=true"`          //
+	ReadTimeout        time.Duration      `json:"read_timeout,default=60s"`          //
+	WriteTimeout       time.Duration      `json:"write_timeout,default=6s"`          //
+	IdleTimeout        time.Duration      `json:"idle_timeout,default=10s"`          //
+	ReadBufferSize     int                `json:"read_buffer_size,default=81920"`    //
+	WriteBufferSize    int                `json:"write_buffer_size,default=1024000"` //
+	MaxRequestBodySize int                `json:"max_request_body_size,default=4"`   // 单位M
+	Routes             []string           `json:"routes"`                            //
+	EnableRegistry     bool               `json:"enable_registry,default=false"`     // 是否开启服务注册
+	ServiceName        string             `json:"service_name,optional"`             // 服务名后缀
+	SlowConsumers      []SlowConsumerConf `json:"slow_consumers,optional"`           // 发送队列满时的处理策略,未配置的路径丢弃新消息
 }
 
 func newDynamicConf() *dynamicConf {
@@ -220,6 +224,13 @@ type dynamicConf struct {
 	SyncUserRegularInterval      time.Duration `yaml:"sync_user_regular_interval" json:"sync_user_regular_interval"`           // 异步同步用户时间间隔
 	DisableAuthorizedOnConnected bool          `yaml:"disable_authorized_on_connected" json:"disable_authorized_on_connected"` // 连接时鉴权,默认开启
 	MaxMetricsSize               int           `yaml:"max_metrics_size" json:"max_metrics_size"`                               // 最大数量
+	ResumeBufferSize             int           `yaml:"resume_buffer_size" json:"resume_buffer_size"`                           // 每个用户缓存的私有推送数量,用于断线重连resume,零则不开启
+	ResumeTTL                    time.Duration `yaml:"resume_ttl" json:"resume_ttl"`                                           // 断线后resume状态保留时间
+	MaxSessionsPerApiKey         int           `yaml:"max_sessions_per_api_key" json:"max_sessions_per_api_key"`               // 单个apikey最大连接数,开启session目录时为集群维度,零则不限制
+	MaxTopicsPerSession          int           `yaml:"max_topics_per_session" json:"max_topics_per_session"`                   // 单个连接最大订阅数,零则不限制
+	MaxSubscribePerMinute        int           `yaml:"max_subscribe_per_minute" json:"max_subscribe_per_minute"`               // 单个连接每分钟最大订阅次数,零则不限制
+	MaxRPCPerSession             int           `yaml:"max_rpc_per_session" json:"max_rpc_per_session"`                         // 单个连接同时处理的rpc请求数
+	EnableAcceptorStandby        bool          `yaml:"enable_acceptor_standby" json:"enable_acceptor_standby"`                 // 相同appid和分片的acceptor只有一个active,其余为standby,断开后自动切换
 }
 
 func (d *dynamicConf) Parse(data []byte) error {
@@ -249,6 +260,10 @@ func (d *dynamicConf) Verify() {
 	verifyIntFn(&d.AcceptorBufferSize, defaultAcceptorBufferSize)
 	verifyIntFn(&d.InputDataSize, defaultInputDataSize)
 	verifyIntFn(&d.MaxMetricsSize, defaultMaxMetricsSize)
+	verifyIntFn(&d.MaxRPCPerSession, defaultMaxRPCPerSession)
+	if d.ResumeTTL <= 0 {
+		d.ResumeTTL = defaultResumeTTL
+	}
 }
 
 func (d *dynamicConf) IsGray(uid int64) bool {
@@ -118,11 +118,11 @@ func (c *configMgr) AddTopic(typ topicType, topics []string) {
 	c.topicMap.Store(n)
 }
 
-// CheckTopics 校验是否是合法topic
+// CheckTopics 校验是否是合法topic,带过滤参数的校验去掉参数后的topic
 func (c *configMgr) CheckTopics(topics []string) (successes []string, fails []string) {
 	dict, _ := c.topicMap.Load().(map[string]topicType)
 	for _, t := range topics {
-		if _, ok := dict[t]; ok {
+		if _, ok := dict[topicBase(t)]; ok {
 			successes = append(successes, t)
 		} else {
 			fails = append(fails, t)
@@ -136,7 +136,7 @@ func (c *configMgr) CheckTopics(topics []string) (successes []string, fails []st
 func (c *configMgr) HasPrivateTopics(topics []string) bool {
 	dict, _ := c.topicMap.Load().(map[string]topicType)
 	for _, t := range topics {
-		if x, ok := dict[t]; ok && x == topicTypePrivate {
+		if x, ok := dict[topicBase(t)]; ok && x == topicTypePrivate {
 			return true
 		}
 	}
@@ -161,6 +161,10 @@ func (ex *exchange) OnMessage(ctx context.Context, acceptor Acceptor, msg *envel
 			continue
 		}
 
+		flags := pushFlag(m.Flags)
+		passthrough := flags.IsPassthrough()
+		seq := gUserMgr.recordPush(m.UserId, m.Topic, m.Data, passthrough, m.SessionId)
+
 		user := GetUserMgr().GetUser(m.UserId)
 		if user == nil {
 			WSCounterInc("exchange", "user_offline")
@@ -176,18 +180,23 @@ func (ex *exchange) OnMessage(ctx context.Context, acceptor Acceptor, msg *envel
 		mt.WsStartTimeE9 = nowUnixNano()
 		mt.Push = m
 
-		flags := pushFlag(m.Flags)
-		passthrough := flags.IsPassthrough()
-		sessions := user.FilterSessions(passthrough, m.Topic, m.SessionId)
+		sessions := user.FilterSessions(passthrough, m.Topic, m.SessionId, m.Data)
 		if len(sessions) == 0 {
-			WSCounterInc("exchange", "not_found_sessions")
-			DispatchEvent(NewForceSyncUserEvent(m.UserId, acceptor.ID()))
+			// 订阅了topic但被过滤参数过滤掉是正常情况,只有没有任何session订阅topic时才需要强制同步
+			if !passthrough && m.SessionId == "" && user.HasTopic(m.Topic) {
+				WSCounterInc("exchange", "filtered_sessions")
+			} else {
+				WSCounterInc("exchange", "not_found_sessions")
+				DispatchEvent(NewForceSyncUserEvent(m.UserId, acceptor.ID()))
+			}
 		}
 
 		for _, s := range sessions {
 			err := s.Write(&Message{
-				Type: MsgTypePush,
-				Data: m.Data,
+				Type:  MsgTypePush,
+				Data:  m.Data,
+				Topic: m.Topic,
+				Seq:   seq,
 			})
 
 			if err == nil {
@@ -607,6 +616,12 @@ func (ex *exchange) onSyncAllUser(ev *SyncAllUserEvent) {
 		return
 	}
 
+	// standby节点切换为active后再同步
+	if acceptor.Standby() {
+		WSCounterInc("exchange", "ignore_standby")
+		return
+	}
+
 	users := ev.users
 	if len(users) == 0 {
 		users = GetUserMgr().GetAllUsers()
@@ -738,6 +753,9 @@ func (ex *exchange) onSyncConfig(ev *SyncConfigEvent) {
 		for _, acc := range acceptors {
 			ex.doSyncConfig(acc)
 		}
+		for _, acc := range gAcceptorMgr.GetStandbys() {
+			ex.doSyncConfig(acc)
+		}
 		glog.Debug(context.Background(), "onSyncConfig", glog.Int64("count", int64(len(acceptors))))
 	}
 }
@@ -0,0 +1,243 @@
+package ws
+
+import (
+	"errors"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// frameFormat is the encoding of websocket frames negotiated on connect
+type frameFormat int8
+
+const (
+	frameFormatJSON     frameFormat = 0 // text frame, json
+	frameFormatProtobuf frameFormat = 1 // binary frame, protobuf, see protocol_v5.md
+)
+
+const (
+	urlParamKeyFormat   = "format"   // ?format=protobuf
+	formatProtobuf      = "protobuf" //
+	subprotocolProtobuf = "protobuf" // Sec-WebSocket-Protocol: protobuf
+)
+
+func (f frameFormat) String() string {
+	switch f {
+	case frameFormatProtobuf:
+		return formatProtobuf
+	default:
+		return "json"
+	}
+}
+
+// parseFrameFormat negotiate format by query param or websocket subprotocol
+func parseFrameFormat(param, subprotocol string) frameFormat {
+	if param == formatProtobuf || subprotocol == subprotocolProtobuf {
+		return frameFormatProtobuf
+	}
+	return frameFormatJSON
+}
+
+var errInvalidProtoFrame = errors.New("invalid protobuf frame")
+
+// field numbers of frames, must be the same as frame.proto
+const (
+	// Request
+	fieldReqID  protowire.Number = 1
+	fieldOp     protowire.Number = 2
+	fieldArgs   protowire.Number = 3
+	fieldPath   protowire.Number = 4
+	fieldBody   protowire.Number = 5
+	fieldHeader protowire.Number = 6
+	// Response
+	fieldRspReqID   protowire.Number = 1
+	fieldRspOp      protowire.Number = 2
+	fieldRspSuccess protowire.Number = 3
+	fieldRspRetMsg  protowire.Number = 4
+	fieldRspConnID  protowire.Number = 5
+	fieldRspArgs    protowire.Number = 6
+	fieldRspBody    protowire.Number = 7
+	fieldRspHeader  protowire.Number = 8
+	// Push
+	fieldPushTopic protowire.Number = 1
+	fieldPushData  protowire.Number = 2
+	fieldPushSeq   protowire.Number = 3
+	// Frame
+	fieldFrameResponse protowire.Number = 1
+	fieldFramePush     protowire.Number = 2
+	// map entry
+	fieldMapKey   protowire.Number = 1
+	fieldMapValue protowire.Number = 2
+)
+
+// requestProto is the binary request of client,
+// args is used by ping/subscribe/unsubscribe/auth/login, path/body/header is used by trade
+type requestProto struct {
+	ReqID  string
+	Op     string
+	Args   []string
+	Path   string
+	Body   []byte
+	Header map[string]string
+}
+
+func (r *requestProto) unmarshal(b []byte) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errInvalidProtoFrame
+		}
+		b = b[n:]
+
+		if typ != protowire.BytesType {
+			// unknown fields are skipped for compatibility
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return errInvalidProtoFrame
+			}
+			b = b[n:]
+			continue
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return errInvalidProtoFrame
+		}
+		b = b[n:]
+
+		switch num {
+		case fieldReqID:
+			r.ReqID = string(v)
+		case fieldOp:
+			r.Op = string(v)
+		case fieldArgs:
+			r.Args = append(r.Args, string(v))
+		case fieldPath:
+			r.Path = string(v)
+		case fieldBody:
+			r.Body = append(r.Body[:0], v...)
+		case fieldHeader:
+			key, value, err := consumeMapEntry(v)
+			if err != nil {
+				return err
+			}
+			if r.Header == nil {
+				r.Header = make(map[string]string, 4)
+			}
+			r.Header[key] = value
+		}
+	}
+	return nil
+}
+
+// responseProto is the binary reply of request, the same fields as responseV3/responseTradeV3
+type responseProto struct {
+	ReqID   string
+	Op      string
+	Success bool
+	RetMsg  string
+	ConnID  string
+	Args    []string
+	Body    []byte
+	Header  map[string]string
+}
+
+// marshal encode response wrapped in frame
+func (r *responseProto) marshal() []byte {
+	var b []byte
+	b = appendString(b, fieldRspReqID, r.ReqID)
+	b = appendString(b, fieldRspOp, r.Op)
+	if r.Success {
+		b = protowire.AppendTag(b, fieldRspSuccess, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	b = appendString(b, fieldRspRetMsg, r.RetMsg)
+	b = appendString(b, fieldRspConnID, r.ConnID)
+	for _, arg := range r.Args {
+		b = protowire.AppendTag(b, fieldRspArgs, protowire.BytesType)
+		b = protowire.AppendString(b, arg)
+	}
+	if len(r.Body) > 0 {
+		b = protowire.AppendTag(b, fieldRspBody, protowire.BytesType)
+		b = protowire.AppendBytes(b, r.Body)
+	}
+	b = appendMap(b, fieldRspHeader, r.Header)
+
+	frame := make([]byte, 0, len(b)+8)
+	frame = protowire.AppendTag(frame, fieldFrameResponse, protowire.BytesType)
+	return protowire.AppendBytes(frame, b)
+}
+
+// marshalPushFrame encode Push of frame.proto wrapped in Frame, data is sent as it is from upstream
+func marshalPushFrame(topic string, data []byte, seq uint64) []byte {
+	size := pushSize(topic, data, seq)
+	b := make([]byte, 0, protowire.SizeTag(fieldFramePush)+protowire.SizeBytes(size))
+	b = protowire.AppendTag(b, fieldFramePush, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(size))
+	b = appendString(b, fieldPushTopic, topic)
+	b = protowire.AppendTag(b, fieldPushData, protowire.BytesType)
+	b = protowire.AppendBytes(b, data)
+	if seq > 0 {
+		b = protowire.AppendTag(b, fieldPushSeq, protowire.VarintType)
+		b = protowire.AppendVarint(b, seq)
+	}
+	return b
+}
+
+func pushSize(topic string, data []byte, seq uint64) int {
+	n := protowire.SizeTag(fieldPushData) + protowire.SizeBytes(len(data))
+	if topic != "" {
+		n += protowire.SizeTag(fieldPushTopic) + protowire.SizeBytes(len(topic))
+	}
+	if seq > 0 {
+		n += protowire.SizeTag(fieldPushSeq) + protowire.SizeVarint(seq)
+	}
+	return n
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendMap encode map<string,string> with sorted keys, so the output is stable
+func appendMap(b []byte, num protowire.Number, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var entry []byte
+		entry = appendString(entry, fieldMapKey, k)
+		entry = appendString(entry, fieldMapValue, m[k])
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+func consumeMapEntry(b []byte) (key, value string, err error) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 || typ != protowire.BytesType {
+			return "", "", errInvalidProtoFrame
+		}
+		b = b[n:]
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return "", "", errInvalidProtoFrame
+		}
+		b = b[n:]
+		switch num {
+		case fieldMapKey:
+			key = string(v)
+		case fieldMapValue:
+			value = string(v)
+		}
+	}
+	return key, value, nil
+}
@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestParseFrameFormat(t *testing.T) {
+	assert.Equal(t, frameFormatJSON, parseFrameFormat("", ""))
+	assert.Equal(t, frameFormatJSON, parseFrameFormat("json", "chat"))
+	assert.Equal(t, frameFormatProtobuf, parseFrameFormat("protobuf", ""))
+	assert.Equal(t, frameFormatProtobuf, parseFrameFormat("", "protobuf"))
+	assert.Equal(t, "protobuf", frameFormatProtobuf.String())
+}
+
+func TestRequestProto(t *testing.T) {
+	var b []byte
+	b = appendString(b, fieldReqID, "1001")
+	b = appendString(b, fieldOp, opTrade)
+	b = appendString(b, fieldArgs, "a")
+	b = appendString(b, fieldArgs, "b")
+	// unknown varint field is skipped
+	b = protowire.AppendTag(b, 15, protowire.VarintType)
+	b = protowire.AppendVarint(b, 100)
+	b = appendString(b, fieldPath, "/v5/order/create")
+	b = protowire.AppendTag(b, fieldBody, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte(`{"symbol":"BTCUSDT"}`))
+	b = appendMap(b, fieldHeader, map[string]string{"X-BAPI-TIMESTAMP": "1", "Referer": "mm"})
+
+	req := &requestProto{}
+	assert.NoError(t, req.unmarshal(b))
+	assert.Equal(t, "1001", req.ReqID)
+	assert.Equal(t, opTrade, req.Op)
+	assert.Equal(t, []string{"a", "b"}, req.Args)
+	assert.Equal(t, "/v5/order/create", req.Path)
+	assert.Equal(t, `{"symbol":"BTCUSDT"}`, string(req.Body))
+	assert.Equal(t, map[string]string{"X-BAPI-TIMESTAMP": "1", "Referer": "mm"}, req.Header)
+
+	assert.Equal(t, errInvalidProtoFrame, (&requestProto{}).unmarshal([]byte{0x0a, 0x05, 'a'}))
+}
+
+func TestResponseProto(t *testing.T) {
+	rsp := &responseProto{ReqID: "1", Op: opPong, Success: true, ConnID: "c", Args: []string{"123"}}
+	frame := rsp.marshal()
+
+	num, typ, n := protowire.ConsumeTag(frame)
+	assert.Equal(t, fieldFrameResponse, num)
+	assert.Equal(t, protowire.BytesType, typ)
+	body, m := protowire.ConsumeBytes(frame[n:])
+	assert.Equal(t, len(frame), n+m)
+
+	fields := map[protowire.Number][]string{}
+	for len(body) > 0 {
+		num, typ, n := protowire.ConsumeTag(body)
+		body = body[n:]
+		if typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(body)
+			body = body[n:]
+			assert.Equal(t, uint64(1), v)
+			fields[num] = append(fields[num], "true")
+			continue
+		}
+		v, n := protowire.ConsumeBytes(body)
+		body = body[n:]
+		fields[num] = append(fields[num], string(v))
+	}
+	assert.Equal(t, map[protowire.Number][]string{
+		fieldRspReqID:   {"1"},
+		fieldRspOp:      {opPong},
+		fieldRspSuccess: {"true"},
+		fieldRspConnID:  {"c"},
+		fieldRspArgs:    {"123"},
+	}, fields)
+}
+
+func TestMarshalPushFrame(t *testing.T) {
+	for _, topic := range []string{"order", ""} {
+		frame := marshalPushFrame(topic, []byte(`{"a":1}`), 0)
+		num, _, n := protowire.ConsumeTag(frame)
+		assert.Equal(t, fieldFramePush, num)
+		push, m := protowire.ConsumeBytes(frame[n:])
+		assert.Equal(t, len(frame), n+m)
+
+		var gotTopic, gotData string
+		for len(push) > 0 {
+			num, _, n := protowire.ConsumeTag(push)
+			push = push[n:]
+			v, n := protowire.ConsumeBytes(push)
+			push = push[n:]
+			switch num {
+			case fieldPushTopic:
+				gotTopic = string(v)
+			case fieldPushData:
+				gotData = string(v)
+			}
+		}
+		assert.Equal(t, topic, gotTopic)
+		assert.Equal(t, `{"a":1}`, gotData)
+	}
+}
+
+func TestMarshalPushFrameSeq(t *testing.T) {
+	frame := marshalPushFrame("order", []byte(`{}`), 300)
+	_, _, n := protowire.ConsumeTag(frame)
+	push, m := protowire.ConsumeBytes(frame[n:])
+	assert.Equal(t, len(frame), n+m)
+
+	var seq uint64
+	for len(push) > 0 {
+		num, typ, n := protowire.ConsumeTag(push)
+		push = push[n:]
+		n = protowire.ConsumeFieldValue(num, typ, push)
+		if num == fieldPushSeq {
+			seq, _ = protowire.ConsumeVarint(push)
+		}
+		push = push[n:]
+	}
+	assert.Equal(t, uint64(300), seq)
+}
+
+// TestFrameProtoSchema check the hand written codec against frame.proto
+func TestFrameProtoSchema(t *testing.T) {
+	fds, err := (&protoparse.Parser{}).ParseFiles("frame.proto")
+	assert.NoError(t, err)
+	fd := fds[0]
+
+	frame := dynamic.NewMessage(fd.FindMessage("bgw.ws.v5.Frame"))
+	assert.NoError(t, frame.Unmarshal(marshalPushFrame("order", []byte(`{"a":1}`), 300)))
+	push := frame.GetFieldByName("push").(*dynamic.Message)
+	assert.Equal(t, "order", push.GetFieldByName("topic"))
+	assert.Equal(t, []byte(`{"a":1}`), push.GetFieldByName("data"))
+	assert.Equal(t, uint64(300), push.GetFieldByName("seq"))
+
+	rsp := &responseProto{ReqID: "1", Op: opTrade, Success: true, RetMsg: "ok", ConnID: "c", Args: []string{"a"},
+		Body: []byte(`{}`), Header: map[string]string{"Traceid": "t"}}
+	frame = dynamic.NewMessage(fd.FindMessage("bgw.ws.v5.Frame"))
+	assert.NoError(t, frame.Unmarshal(rsp.marshal()))
+	r := frame.GetFieldByName("response").(*dynamic.Message)
+	assert.Equal(t, "1", r.GetFieldByName("req_id"))
+	assert.Equal(t, opTrade, r.GetFieldByName("op"))
+	assert.Equal(t, true, r.GetFieldByName("success"))
+	assert.Equal(t, "ok", r.GetFieldByName("ret_msg"))
+	assert.Equal(t, "c", r.GetFieldByName("conn_id"))
+	assert.Equal(t, []interface{}{"a"}, r.GetFieldByName("args"))
+	assert.Equal(t, []byte(`{}`), r.GetFieldByName("body"))
+	assert.Equal(t, map[interface{}]interface{}{"Traceid": "t"}, r.GetFieldByName("header"))
+
+	req := dynamic.NewMessage(fd.FindMessage("bgw.ws.v5.Request"))
+	req.SetFieldByName("req_id", "2")
+	req.SetFieldByName("op", opTrade)
+	req.SetFieldByName("args", []string{"x", "y"})
+	req.SetFieldByName("path", "/v5/order/create")
+	req.SetFieldByName("body", []byte(`{}`))
+	req.PutMapFieldByName("header", "Referer", "mm")
+	b, err := req.Marshal()
+	assert.NoError(t, err)
+
+	got := &requestProto{}
+	assert.NoError(t, got.unmarshal(b))
+	assert.Equal(t, &requestProto{ReqID: "2", Op: opTrade, Args: []string{"x", "y"}, Path: "/v5/order/create",
+		Body: []byte(`{}`), Header: map[string]string{"Referer": "mm"}}, got)
+}
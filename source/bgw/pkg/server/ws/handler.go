@@ -41,6 +41,12 @@ var (
 	errReqLimit         = newCodeErr(20003, "Request limit exceeded") // 调用频率受限
 	errUserBanned       = newCodeErr(20004, "User banned")            // 被拉黑
 	errNoServiceByTopic = newCodeErr(20005, "No service by topic")    // 无法通过topic找到对应的服务
+	errResumeExpired    = newCodeErr(20006, "Resume expired")         // 旧session不存在或已过期,需要重新订阅
+	errResumeGap        = newCodeErr(20007, "Resume messages lost")   // 订阅已恢复,但缓存不足以补发,需要重新查询
+	errTooManyTopics    = newCodeErr(20008, "Too many topics")        // 单个连接订阅数超过限制
+	errAPIKeyConnLimit  = newCodeErr(20009, "Exceeded apikey limit")  // 单个apikey连接数超过限制
+	errRPCNotAllowed    = newCodeErr(20010, "Route not allowed")      // 路由不存在或者不允许通过websocket调用
+	errRPCTimeout       = newCodeErr(20011, "Request timeout")        // rpc调用超时
 )
 
 const (
@@ -65,6 +71,7 @@ const (
 	version5        versionType = 5  // 格式同v3
 	versionOptionV1 versionType = 10 // 期权v1,trade/option/usdc/private/v1
 	versionOptionV3 versionType = 11 // 期权v3,/unified/private/v3
+	versionStream   versionType = 20 // grpc bidi stream bridge, stream:/path
 )
 
 func (v versionType) String() string {
@@ -79,6 +86,8 @@ func (v versionType) String() string {
 		return "option_v1"
 	case versionOptionV3:
 		return "option_v3"
+	case versionStream:
+		return "stream"
 	default:
 		return "unknown"
 	}
@@ -97,6 +106,8 @@ func parseVersion(v string) versionType {
 		return versionOptionV1
 	case "option_v3":
 		return versionOptionV3
+	case "stream":
+		return versionStream
 	default:
 		return versionNone
 	}
@@ -142,6 +153,7 @@ var (
 	gHandlerV2     = &handlerV2{}
 	gHandlerV3     = &handlerV3{}
 	gHandlerOption = &handlerOption{}
+	gHandlerProto  = &handlerProto{}
 )
 
 func getHandler(v versionType) Handler {
@@ -169,6 +181,8 @@ const (
 	opUnsubscribe = "unsubscribe"
 	opTrade       = "trade"
 	opInput       = "input"
+	opResume      = "resume"
+	opRPC         = "rpc"
 )
 
 // Handler is the interface that must be implemented by a websocket handler.
@@ -385,14 +399,16 @@ func onAuth(sess Session, apiKey string, expires int64, signature string) CodeEr
 		return errInvalidSign
 	}
 
+	// 先设置apikey,用于校验apikey连接数和更新session目录
+	sess.GetClient().SetAPIKey(apiKey)
 	err = bindUser(member.MemberId, sess)
 	if err != nil {
+		sess.GetClient().SetAPIKey("")
 		WSCounterInc("auth", "bind_user_fail")
 		glog.Info(context.Background(), "[auth] bind user failed", glog.Int64("uid", member.MemberId), glog.String("ip", sess.GetClient().GetIP()), glog.String("err", err.Error()))
 		return toCodeErr(err)
 	}
 
-	sess.GetClient().SetAPIKey(apiKey)
 	glog.Debug(context.Background(), "onAuth success", glog.Int64("uid", member.MemberId))
 	return nil
 }
@@ -429,10 +445,15 @@ func checkUserIsBanned(uid int64) (bool, error) {
 }
 
 func bindUser(uid int64, sess Session) error {
+	if err := checkAPIKeyQuota(uid, sess); err != nil {
+		return err
+	}
+
 	user, err := GetUserMgr().Bind(uid, sess)
 	if err == nil {
 		action := newAction(ActionSessionOnline, uid, sess.ID(), nil)
 		DispatchEvent(NewSyncOneUserEvent(user, action))
+		gSessionDir.OnOnline(sess)
 		glog.Info(
 			context.Background(),
 			"user login success",
@@ -472,7 +493,8 @@ func checkTopicConflict(newTopics []string, oldTopics []string) (successes []str
 	}
 
 	for _, t := range newTopics {
-		list, ok := v5TopicConflictMap[t]
+		base := topicBase(t)
+		list, ok := v5TopicConflictMap[base]
 		if !ok {
 			// 不需要冲突检测,比如wallet
 			successes = append(successes, t)
@@ -489,7 +511,7 @@ func checkTopicConflict(newTopics []string, oldTopics []string) (successes []str
 		}
 
 		if !conflict {
-			dict[t] = struct{}{}
+			dict[base] = struct{}{}
 			successes = append(successes, t)
 		} else {
 			fails = append(fails, t)
@@ -505,6 +527,18 @@ func onSubscribe(sess Session, topics []string) (successes []string, fails []str
 		return nil, nil, nil, errEmptyParameter
 	}
 
+	// 规范化带过滤参数的订阅
+	topics, fails = parseSubscribeTopics(topics)
+	if len(fails) > 0 {
+		WSCounterInc("subscribe", "invalid_filter")
+		err = newCodeErrFrom(errParamsErr, "fail_topics=%v", fails)
+	}
+
+	if !sess.AllowSubscribe() {
+		WSCounterInc("subscribe", "rate_limit")
+		return nil, nil, nil, errReqLimit
+	}
+
 	user := GetUserMgr().GetUser(sess.GetClient().GetMemberId())
 	if getConfigMgr().HasPrivateTopics(topics) {
 		// 如果含有私有推送topic,订阅前需要先鉴权
@@ -518,7 +552,9 @@ func onSubscribe(sess Session, topics []string) (successes []string, fails []str
 
 	// 校验topic是否合法
 	if !appConf.DisableSubscribeCheck {
-		successes, fails = getConfigMgr().CheckTopics(topics)
+		var tmpFails []string
+		successes, tmpFails = getConfigMgr().CheckTopics(topics)
+		fails = append(fails, tmpFails...)
 		if len(fails) > 0 {
 			WSCounterInc("subscribe", "invalid_topic")
 			err = newCodeErrFrom(errParamsErr, "fail_topics=%v", fails)
@@ -537,6 +573,14 @@ func onSubscribe(sess Session, topics []string) (successes []string, fails []str
 		}
 	}
 
+	// 校验订阅数量
+	var quotaFails []string
+	successes, quotaFails = checkTopicQuota(sess, successes)
+	if len(quotaFails) > 0 {
+		fails = append(fails, quotaFails...)
+		err = newCodeErrFrom(errTooManyTopics, "fail_topics=%v", quotaFails)
+	}
+
 	changed = sess.GetClient().Subscribe(successes)
 
 	if len(changed) == 0 {
@@ -675,15 +719,45 @@ func onTrade(s Session, args []interface{}) ([]byte, map[string]string, error) {
 		}
 	}
 
+	return doTrade(s, route, payload, header)
+}
+
+// doTrade invoke the http route with json payload, apikey of session must be checked by caller
+func doTrade(s Session, route string, payload []byte, header map[string]string) ([]byte, map[string]string, error) {
+	ctx := acquireRouteCtx(s, http.MethodPost, route, "", payload, header)
+	defer releaseRouteCtx(ctx)
+
+	chain := filter.GlobalChain()
+	chain, err := chain.AppendNames(filter.IPRateLimitFilterKey) // add default ip limiter
+	if err != nil {
+		return nil, nil, errServiceNotAvailable
+	}
+	f := func(ctx *types.Ctx) error {
+		return tradeHandle(ctx)
+	}
+	ch := chain.Finally(f)
+	if err := ch(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	body, header := routeResponse(ctx)
+	return body, header, nil
+}
+
+// acquireRouteCtx 构造调用http路由的请求,使用session的apikey和连接信息,使用完需要调用releaseRouteCtx
+func acquireRouteCtx(s Session, method string, route string, query string, payload []byte, header map[string]string) *types.Ctx {
+	cli := s.GetClient()
+	apikey := cli.GetAPIKey()
+
 	ctx := ctxBufferPool.Get()
-	defer ctxBufferPool.Put(ctx)
 
 	uri := fasthttp.AcquireURI()
 	defer fasthttp.ReleaseURI(uri)
 	uri.SetPath(route)
+	uri.SetQueryString(query)
 	uri.SetHost(cli.GetHost())
 	ctx.Request.SetURI(uri)
-	ctx.Request.Header.SetMethod(http.MethodPost)
+	ctx.Request.Header.SetMethod(method)
 	for k, v := range header {
 		ctx.Request.Header.Set(k, v)
 	}
@@ -691,11 +765,9 @@ func onTrade(s Session, args []interface{}) ([]byte, map[string]string, error) {
 	ctx.Request.SetBody(payload)
 
 	md := metadata.MDFromContext(ctx)
-	defer metadata.Release(md)
-
 	md.ReqInitTime = time.Now()
 	md.UID = cli.GetMemberId()
-	md.Method = http.MethodPost
+	md.Method = method
 	md.Path = route
 	md.WssFlag = true
 	md.BrokerID = cli.GetBrokerID()
@@ -705,25 +777,22 @@ func onTrade(s Session, args []interface{}) ([]byte, map[string]string, error) {
 	md.Extension.Referer = cli.GetReferer()
 	md.Extension.RemoteIP = cli.GetIP()
 
-	chain := filter.GlobalChain()
-	chain, err = chain.AppendNames(filter.IPRateLimitFilterKey) // add default ip limiter
-	if err != nil {
-		return nil, nil, errServiceNotAvailable
-	}
-	f := func(ctx *types.Ctx) error {
-		return tradeHandle(ctx)
-	}
-	ch := chain.Finally(f)
-	if err := ch(ctx); err != nil {
-		return nil, nil, err
-	}
+	return ctx
+}
+
+func releaseRouteCtx(ctx *types.Ctx) {
+	metadata.Release(metadata.MDFromContext(ctx))
+	ctxBufferPool.Put(ctx)
+}
 
-	header = make(map[string]string)
+// routeResponse 复制返回的body和header,ctx会被回收复用
+func routeResponse(ctx *types.Ctx) ([]byte, map[string]string) {
+	header := make(map[string]string)
 	ctx.Response.Header.VisitAll(func(key, value []byte) {
 		header[string(key)] = string(value)
 	})
 
-	return ctx.Response.Body(), header, nil
+	return append([]byte(nil), ctx.Response.Body()...), header
 }
 
 func tradeHandle(ctx *types.Ctx) error {
@@ -34,7 +34,6 @@ const (
 	// nolint
 	optionErrUID_ZONE_LIMIT = "10005"
 	// 单uid建立的ws连接数限制
-	// nolint
 	optionErrUID_WS_CONNECT_LIMIT = "3303008"
 )
 
@@ -115,6 +114,9 @@ func (h *handlerOption) Handle(ctx context.Context, sess Session, r io.Reader) (
 		glog.Debug(ctx, "handle_option fail", glog.NamedError("err", err), glog.Any("req", req))
 		cerr := toCodeErr(err)
 		rsp.RetMsg = cerr.Error()
+		if isQuotaError(cerr) {
+			rsp.RetMsg = h.mappingErr(cerr)
+		}
 		rsp.Success = false
 		WSCounterInc("handler_option", req.Op)
 	} else {
@@ -220,6 +222,10 @@ func (h *handlerOption) mappingErr(err CodeError) string {
 		return optionErrVERIFY_SIGN_FAIL
 	case errAuthFail.Code():
 		return optionErrAUTHFAIL
+	case errTooManySession.Code(), errTooManySessionPerIP.Code(), errAPIKeyConnLimit.Code():
+		return optionErrUID_WS_CONNECT_LIMIT
+	case errTooManyTopics.Code():
+		return optionErrREQ_COUNT_LIMIT
 	default:
 		return optionErrCOMMANDHANDLE_UNKNOWERROR
 	}
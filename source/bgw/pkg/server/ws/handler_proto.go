@@ -0,0 +1,152 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+)
+
+// handlerProto is handlerV3 with binary protobuf frames, the ops and results are the same as v5 json protocol
+type handlerProto struct {
+}
+
+func (h *handlerProto) Handle(ctx context.Context, sess Session, r io.Reader) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			dumpPanic("handler_proto panic", fmt.Errorf("%v", e))
+		}
+	}()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	req := &requestProto{}
+	if err = req.unmarshal(data); err != nil {
+		WSCounterInc("handler_proto", "read_fail")
+		return h.sendResponse(sess, req, errParamsErr)
+	}
+
+	if !sess.Allow() {
+		WSCounterInc("handler_proto", "session_limit")
+		return h.sendResponse(sess, req, errReqLimit)
+	}
+
+	return h.Invoke(ctx, sess, req)
+}
+
+func (h *handlerProto) Invoke(ctx context.Context, sess Session, req *requestProto) error {
+	WSCounterInc("handler_proto", req.Op)
+	args := req.Args
+	switch req.Op {
+	case opTrade:
+		body, header, err := onTradeProto(sess, req)
+		rsp := &responseProto{
+			Op:     opTrade,
+			ReqID:  req.ReqID,
+			ConnID: sess.ID(),
+		}
+		if err == nil {
+			rsp.Success = true
+			rsp.Body = body
+			rsp.Header = header
+		} else {
+			rsp.RetMsg = toCodeErr(err).Error()
+		}
+		return sendProto(sess, rsp)
+	case opPing:
+		now := time.Now().UnixNano() / 1e6
+		return sendProto(sess, &responseProto{
+			ReqID:  req.ReqID,
+			Op:     opPong,
+			ConnID: sess.ID(),
+			Args:   []string{strconv.FormatInt(now, 10)},
+		})
+	case opPong:
+		sess.OnPong(req.ReqID)
+		return nil
+	case opAuth:
+		if len(args) != 3 {
+			return h.sendResponse(sess, req, errParamsErr)
+		}
+		expires, _ := strconv.ParseInt(args[1], 10, 64)
+		err := onAuth(sess, args[0], expires, args[2])
+		return h.sendResponse(sess, req, err)
+	case opLogin:
+		if len(args) != 1 {
+			return h.sendResponse(sess, req, errParamsErr)
+		}
+		err := onLogin(ctx, sess, args[0])
+		return h.sendResponse(sess, req, err)
+	case opSubscribe:
+		_, _, changed, err := onSubscribe(sess, args)
+		sendErr := h.sendResponse(sess, req, err)
+		gPublicMgr.OnSubscribe(sess, changed)
+		return sendErr
+	case opUnsubscribe:
+		_, _, err := onUnsubscribe(sess, args)
+		return h.sendResponse(sess, req, err)
+	case opResume:
+		if len(args) != 2 {
+			return h.sendResponse(sess, req, errParamsErr)
+		}
+		seq, _ := strconv.ParseUint(args[1], 10, 64)
+		items, err := onResume(sess, args[0], seq)
+		sendErr := h.sendResponse(sess, req, err)
+		replayPushes(sess, items)
+		return sendErr
+	default:
+		return h.sendResponse(sess, req, errParamsErr)
+	}
+}
+
+// onTradeProto is onTrade with raw json body, body is not decoded in gateway
+func onTradeProto(sess Session, req *requestProto) ([]byte, map[string]string, error) {
+	if sess.GetClient().GetAPIKey() == "" {
+		glog.Debug(context.TODO(), "invalid apikey")
+		return nil, nil, errDeniedAPIKey
+	}
+	if req.Path == "" || len(req.Body) == 0 {
+		return nil, nil, errEmptyParameter
+	}
+	if req.Body[0] != '{' || !json.Valid(req.Body) {
+		glog.Debug(context.TODO(), "body not json", glog.ByteString("body", req.Body))
+		return nil, nil, errParamsErr
+	}
+
+	header := req.Header
+	if header == nil {
+		header = make(map[string]string, 1)
+	}
+	return doTrade(sess, req.Path, req.Body, header)
+}
+
+func (h *handlerProto) sendResponse(sess Session, req *requestProto, err error) error {
+	rsp := &responseProto{
+		Op:     req.Op,
+		ReqID:  req.ReqID,
+		ConnID: sess.ID(),
+	}
+
+	if err != nil {
+		glog.Debug(context.Background(), "handle_proto fail", glog.NamedError("err", err), glog.String("op", req.Op), glog.String("req_id", req.ReqID))
+		rsp.RetMsg = toCodeErr(err).Error()
+	} else {
+		rsp.Success = true
+	}
+
+	return sendProto(sess, rsp)
+}
+
+func sendProto(sess Session, rsp *responseProto) error {
+	return sess.Write(&Message{
+		Type: MsgTypeReply,
+		Data: rsp.marshal(),
+	})
+}
@@ -86,6 +86,7 @@ func TestParsePathAndVersion(t *testing.T) {
 			{"v5", "v5", version5},
 			{"option_v1", "option_v1", versionOptionV1},
 			{"option_v3", "option_v3", versionOptionV3},
+			{"stream", "stream", versionStream},
 		}
 		for _, x := range list {
 			v := parseVersion(x.Key)
@@ -129,6 +130,10 @@ func TestParsePathAndVersion(t *testing.T) {
 		p, v := parsePathAndVersion(path)
 		assert.Equal(t, version3, v)
 		assert.Equal(t, "/v5/private", p)
+
+		p, v = parsePathAndVersion("stream:/v5/stream/order")
+		assert.Equal(t, versionStream, v)
+		assert.Equal(t, "/v5/stream/order", p)
 	})
 }
 
@@ -918,6 +923,9 @@ func TestHandlerOption(t *testing.T) {
 			{errDeniedAPIKey, optionErrVERIFY_SIGN_FAIL},
 			{errAuthFail, optionErrAUTHFAIL},
 			{errUserBanned, optionErrCOMMANDHANDLE_UNKNOWERROR},
+			{errTooManySessionPerIP, optionErrUID_WS_CONNECT_LIMIT},
+			{errAPIKeyConnLimit, optionErrUID_WS_CONNECT_LIMIT},
+			{errTooManyTopics, optionErrREQ_COUNT_LIMIT},
 		}
 		for _, kv := range list {
 			r := gHandlerOption.mappingErr(kv.Err)
@@ -99,6 +99,10 @@ func (h *handlerV3) Invoke(ctx context.Context, sess Session, req *requestV3) er
 		pongRsp.ReqID = req.ReqID
 		pongRsp.ConnID = sess.ID()
 		return sendResponse(sess, pongRsp)
+	case opPong:
+		// 服务端心跳的回复,不需要返回
+		sess.OnPong(req.ReqID)
+		return nil
 	case opAuth:
 		if len(args) != 3 {
 			return h.sendResponseV3(sess, req, errParamsErr)
@@ -125,6 +129,20 @@ func (h *handlerV3) Invoke(ctx context.Context, sess Session, req *requestV3) er
 		topics := toStringList(args)
 		_, _, err := onUnsubscribe(sess, topics)
 		return h.sendResponseV3(sess, req, err)
+	case opResume:
+		if len(args) != 2 {
+			return h.sendResponseV3(sess, req, errParamsErr)
+		}
+		items, err := onResume(sess, toString(args[0]), uint64(toInt64(args[1])))
+		sendErr := h.sendResponseV3(sess, req, err)
+		replayPushes(sess, items)
+		return sendErr
+	case opRPC:
+		// 成功时异步返回结果
+		if err := onRPC(sess, req.ReqID, args); err != nil {
+			return h.sendResponseV3(sess, req, err)
+		}
+		return nil
 	case opInput:
 		if len(req.Args) < 2 {
 			return h.sendResponseV3(sess, req, errParamsErr)
@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	urlParamKeyHeartbeat = "heartbeat" // ?heartbeat=1 开启服务端心跳
+	opHeartbeat          = "heartbeat" //
+	heartbeatIDPrefix    = "hb-"       // 心跳req_id前缀,客户端回复pong时原样带回
+)
+
+// heartbeatState 服务端心跳状态,同一时间只跟踪最后一次发送的心跳
+type heartbeatState struct {
+	enable bool  // 客户端连接时通过url参数开启
+	seq    int64 // 最后一次发送的心跳序号
+	sentAt int64 // 最后一次心跳实际写入连接的时间,收到pong后清零
+	rtt    int64 // 最近一次测量的往返时间
+}
+
+func parseHeartbeatParam(v string) bool {
+	enable, _ := strconv.ParseBool(v)
+	return enable
+}
+
+func heartbeatID(seq int64) string {
+	return heartbeatIDPrefix + strconv.FormatInt(seq, 10)
+}
+
+func parseHeartbeatID(id string) int64 {
+	if !strings.HasPrefix(id, heartbeatIDPrefix) {
+		return 0
+	}
+	seq, _ := strconv.ParseInt(id[len(heartbeatIDPrefix):], 10, 64)
+	return seq
+}
+
+// sendHeartbeat 发送服务端心跳,args: [服务端时间(毫秒), 最近一次rtt(微秒), 发送队列深度]
+func (s *session) sendHeartbeat() {
+	if !s.hb.enable || !s.IsRunning() {
+		return
+	}
+
+	seq := atomic.AddInt64(&s.hb.seq, 1)
+	atomic.StoreInt64(&s.hb.sentAt, 0)
+	args := []string{
+		strconv.FormatInt(time.Now().UnixNano()/1e6, 10),
+		strconv.FormatInt(atomic.LoadInt64(&s.hb.rtt)/1e3, 10),
+		strconv.Itoa(len(s.sendCh)),
+	}
+
+	var data []byte
+	if s.format == frameFormatProtobuf {
+		rsp := &responseProto{ReqID: heartbeatID(seq), Op: opHeartbeat, ConnID: s.id, Args: args}
+		data = rsp.marshal()
+	} else {
+		rsp := &responsePongV3{ReqID: heartbeatID(seq), Op: opHeartbeat, ConnID: s.id, Args: args}
+		var err error
+		if data, err = jsonMarshal(rsp); err != nil {
+			WSCounterInc("error", "heartbeat_marshal")
+			return
+		}
+	}
+
+	if err := s.Write(&Message{Type: MsgTypeHeartbeat, Data: data}); err == nil {
+		WSCounterInc("session", "heartbeat")
+	}
+}
+
+// OnPong 收到客户端对服务端心跳的回复,只计算最后一次心跳的rtt
+func (s *session) OnPong(reqID string) {
+	seq := parseHeartbeatID(reqID)
+	if seq == 0 || seq != atomic.LoadInt64(&s.hb.seq) {
+		WSCounterInc("session", "heartbeat_stale_pong")
+		return
+	}
+
+	sentAt := atomic.SwapInt64(&s.hb.sentAt, 0)
+	if sentAt == 0 {
+		return
+	}
+
+	rtt := nowUnixNano() - sentAt
+	atomic.StoreInt64(&s.hb.rtt, rtt)
+	wsDefaultLatencyE6(time.Duration(rtt), "session_rtt", s.version.String())
+}
+
+// formatRTT 没有测量过rtt时返回空
+func formatRTT(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.String()
+}
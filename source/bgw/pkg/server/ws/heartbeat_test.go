@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerHeartbeat(t *testing.T) {
+	glog.SetLevel(glog.FatalLevel)
+
+	t.Run("disabled", func(t *testing.T) {
+		s := newSession(nil, NewClient(&ClientConfig{Params: map[string]string{urlParamKeyHeartbeat: "1"}}), version2)
+		s.running = 1
+		s.sendHeartbeat()
+		assert.Equal(t, 0, len(s.sendCh))
+	})
+
+	t.Run("rtt", func(t *testing.T) {
+		s := newSession(nil, NewClient(&ClientConfig{Params: map[string]string{urlParamKeyHeartbeat: "1"}}), version5)
+		s.running = 1
+		s.sendHeartbeat()
+		assert.Equal(t, 1, len(s.sendCh))
+
+		msg := <-s.sendCh
+		assert.Equal(t, MsgTypeHeartbeat, msg.Type)
+		rsp := responsePongV3{}
+		assert.Nil(t, json.Unmarshal(msg.Data, &rsp))
+		assert.Equal(t, opHeartbeat, rsp.Op)
+		assert.Equal(t, "hb-1", rsp.ReqID)
+		assert.Equal(t, []string{rsp.Args[0], "0", "0"}, rsp.Args)
+
+		// 模拟已经写入连接
+		atomic.StoreInt64(&s.hb.sentAt, nowUnixNano()-int64(time.Millisecond*5))
+		s.OnPong("hb-0")
+		assert.Equal(t, time.Duration(0), s.GetStatus().RTT)
+		s.OnPong("hb-1")
+		assert.GreaterOrEqual(t, s.GetStatus().RTT, time.Millisecond*5)
+
+		// 重复的pong忽略
+		rtt := s.GetStatus().RTT
+		s.OnPong("hb-1")
+		assert.Equal(t, rtt, s.GetStatus().RTT)
+	})
+
+	t.Run("queue full", func(t *testing.T) {
+		s := newSession(nil, NewClient(&ClientConfig{Params: map[string]string{urlParamKeyHeartbeat: "true"}}), version3)
+		s.running = 1
+		s.sendCh = make(chan *Message, 1)
+		s.sendHeartbeat()
+		s.sendHeartbeat()
+		assert.Equal(t, 1, len(s.sendCh))
+		assert.Equal(t, int64(0), s.GetStatus().DropCount)
+	})
+}
@@ -8,10 +8,14 @@ const (
 	MsgTypeReply MsgType = 1
 	// MsgTypePush is push message.
 	MsgTypePush MsgType = 2
+	// MsgTypeHeartbeat is server heartbeat message, discarded when queue is full.
+	MsgTypeHeartbeat MsgType = 3
 )
 
 // Message is a websocket message.
 type Message struct {
-	Type MsgType
-	Data []byte
+	Type  MsgType
+	Data  []byte
+	Topic string // topic of push, used by binary frame
+	Seq   uint64 // sequence of private push per user, used by resume
 }
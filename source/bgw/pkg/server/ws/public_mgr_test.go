@@ -259,4 +259,42 @@ func TestPublicWorker(t *testing.T) {
 		w.Broadcast(newPublicMessage(topic, messageTypeDelta, []byte("delta")), true)
 		w.Broadcast(newPublicMessage(topic, messageTypeDelta, []byte("snapshot")), false)
 	})
+
+	t.Run("delta sequence", func(t *testing.T) {
+		topic := "delta"
+		w := newPublicWorkerDelta(PublicTopicConf{Topic: topic, PushMode: "delta"})
+
+		snapshot := newPublicMessage(topic, messageTypeSnapshot, []byte(`{"type":"snapshot"}`))
+		w.sequence(snapshot)
+		w.caches = append(w.caches, snapshot.data)
+		assert.Equal(t, `{"seq":0,"prev_seq":0,"type":"snapshot"}`, string(snapshot.data))
+
+		s1 := newMockSession(1)
+		s1.GetClient().Subscribe([]string{topic})
+		ps1, _ := w.DoAddSession(s1)
+		assert.NoError(t, w.resendSnapshot(ps1))
+		assert.True(t, ps1.hasSendSnapshot)
+
+		// send queue overflow, snapshot is resent before next delta
+		s1.SetChanFull(true)
+		delta := newPublicMessage(topic, messageTypeDelta, []byte(`{"type":"delta"}`))
+		w.sequence(delta)
+		w.Broadcast(delta, true)
+		w.caches = append(w.caches, delta.data)
+		assert.Equal(t, `{"seq":1,"prev_seq":0,"type":"delta"}`, string(delta.data))
+		assert.False(t, ps1.hasSendSnapshot)
+		assert.Equal(t, 1, w.Size())
+
+		s1.SetChanFull(false)
+		delta = newPublicMessage(topic, messageTypeDelta, []byte(`{"type":"delta"}`))
+		w.sequence(delta)
+		w.Broadcast(delta, true)
+		assert.Equal(t, `{"seq":2,"prev_seq":1,"type":"delta"}`, string(s1.lastMsg.Data))
+		assert.True(t, ps1.hasSendSnapshot)
+
+		reset := newPublicMessage(topic, messageTypeReset, []byte(`{}`))
+		w.sequence(reset)
+		assert.Equal(t, `{"seq":3,"prev_seq":0}`, string(reset.data))
+		assert.Equal(t, `[]`, string(withPublicSeq([]byte(`[]`), 1, 0)))
+	})
 }
@@ -26,6 +26,15 @@ type publicMessage struct {
 	*envelopev1.PushMessage
 	appId      string
 	remoteAddr string
+	data       []byte // 带有seq的推送数据,为空时推送原始数据
+}
+
+// payload 推送给客户端的数据
+func (m *publicMessage) payload() []byte {
+	if m.data != nil {
+		return m.data
+	}
+	return m.Data
 }
 
 type publicWorker interface {
@@ -40,6 +49,7 @@ type publicWorker interface {
 
 type publicSession struct {
 	sess            Session // 连接
+	topic           string  // 订阅topic
 	hasSendSnapshot bool    // 标记是否发送过snapshot
 }
 
@@ -55,8 +65,15 @@ func (ps *publicSession) Stop() {
 	ps.sess.Stop()
 }
 
+// dropConflated 丢弃session中该topic合并中的消息
+func (ps *publicSession) dropConflated() {
+	if s, ok := ps.sess.(*session); ok {
+		s.dropConflated(ps.topic)
+	}
+}
+
 func (ps *publicSession) Write(data []byte) error {
-	return ps.sess.Write(&Message{Type: MsgTypePush, Data: data})
+	return ps.sess.Write(&Message{Type: MsgTypePush, Data: data, Topic: ps.topic})
 }
 
 // publicWorkerBase
@@ -99,7 +116,7 @@ func (w *publicWorkerBase) DoAddSession(sess Session) (*publicSession, bool) {
 		return nil, false
 	}
 
-	ps, loaded := w.sessions.LoadOrStore(sess.ID(), &publicSession{sess: sess, hasSendSnapshot: false})
+	ps, loaded := w.sessions.LoadOrStore(sess.ID(), &publicSession{sess: sess, topic: w.conf.Topic, hasSendSnapshot: false})
 	if loaded {
 		return ps.(*publicSession), false
 	}
@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"context"
 	"errors"
+	"strconv"
 	"sync"
 
 	"code.bydev.io/fbu/gateway/gway.git/glog"
@@ -33,6 +34,7 @@ type publicWorkerDelta struct {
 	caches       [][]byte           // 缓存数据
 	status       publicStatus       // 当前运行状态
 	snapshotSize int                // 当前队列中snapshot个数
+	seq          uint64             // 当前topic推送序号,只在loop中使用
 	mux          sync.RWMutex       //
 	cnd          *sync.Cond         //
 }
@@ -137,13 +139,8 @@ func (p *publicWorkerDelta) loop() {
 				// 	glog.Debugf(context.Background(), "public delta send cache, sessId=%v, cacheSize=%v", sess.ID(), len(p.caches))
 				// }
 
-				if len(p.caches) > 0 {
-					psess.SetHasSendSnapshot()
-					for _, data := range p.caches {
-						_ = psess.Write(data)
-						// glog.Debug(context.Background(), "public delta write cache", glog.String("sess_id", psess.ID()), glog.ByteString("data", data), glog.NamedError("err", err))
-					}
-				}
+				// 发送失败的在下次广播时重发
+				_ = p.resendSnapshot(psess)
 			}
 		}
 
@@ -151,16 +148,20 @@ func (p *publicWorkerDelta) loop() {
 		if msg != nil {
 			switch msg.MessageType {
 			case messageTypeSnapshot:
+				p.sequence(msg)
 				p.caches = p.caches[:0]
-				p.caches = append(p.caches, msg.Data)
+				p.caches = append(p.caches, msg.data)
+				p.sendSnapshotOnly(msg)
 			case messageTypeReset:
+				p.sequence(msg)
 				p.Broadcast(msg, false)
 				p.caches = p.caches[:0]
-				p.caches = append(p.caches, msg.Data)
+				p.caches = append(p.caches, msg.data)
 			case messageTypeDelta:
+				p.sequence(msg)
 				p.Broadcast(msg, true)
 				if len(p.caches) > 0 {
-					p.caches = append(p.caches, msg.Data)
+					p.caches = append(p.caches, msg.data)
 				} else {
 					WSErrorInc("public_delta", "invalid_cache")
 				}
@@ -174,33 +175,94 @@ func (p *publicWorkerDelta) loop() {
 	}
 }
 
+// sequence 设置seq和prev_seq,snapshot为缓存的全量数据,seq与最后一次广播相同,prev_seq为0,
+// reset为广播的全量数据,prev_seq为0,delta的prev_seq为上一次广播的seq,客户端可以据此检测丢包
+func (p *publicWorkerDelta) sequence(msg *publicMessage) {
+	var prevSeq uint64
+	switch msg.MessageType {
+	case messageTypeReset:
+		p.seq++
+	case messageTypeDelta:
+		prevSeq = p.seq
+		p.seq++
+	}
+	msg.data = withPublicSeq(msg.Data, p.seq, prevSeq)
+}
+
+// resendSnapshot 发送缓存的全量数据,发送失败时下次广播会重发
+func (p *publicWorkerDelta) resendSnapshot(psess *publicSession) error {
+	if len(p.caches) == 0 {
+		return nil
+	}
+
+	psess.hasSendSnapshot = false
+	psess.dropConflated()
+	for _, data := range p.caches {
+		if err := psess.Write(data); err != nil {
+			WSCounterInc("public_delta_resend_fail", p.conf.Topic)
+			return err
+		}
+	}
+	psess.SetHasSendSnapshot()
+	return nil
+}
+
+// sendSnapshotOnly 给降级为只推送全量数据的session发送snapshot
+func (p *publicWorkerDelta) sendSnapshotOnly(msg *publicMessage) {
+	data := msg.payload()
+	p.sessions.Range(func(key, value any) bool {
+		psess, _ := value.(*publicSession)
+		if psess.sess.GetStatus().SnapshotOnly {
+			if err := psess.Write(data); err == nil {
+				psess.SetHasSendSnapshot()
+			}
+		}
+		return true
+	})
+}
+
 func (p *publicWorkerDelta) Broadcast(msg *publicMessage, isDelta bool) {
-	data := msg.Data
+	data := msg.payload()
 	start := nowUnixNano()
 	count := 0
 	invalidSessions := make([]string, 0)
 	p.sessions.Range(func(key, value any) bool {
 		psess, _ := value.(*publicSession)
 
-		if !isDelta {
-			psess.SetHasSendSnapshot()
-		} else if !psess.hasSendSnapshot {
-			// 第一个包非snapshot
-			WSErrorInc("public_delta", "no_snapshot")
-			glog.Errorf(context.Background(), "public worker write fail, no snapshot, id=%v", psess.ID())
-			psess.Stop()
-			invalidSessions = append(invalidSessions, psess.ID())
+		if isDelta && psess.sess.GetStatus().SnapshotOnly {
+			// 消费过慢,只推送全量数据
+			psess.hasSendSnapshot = false
 			return true
 		}
 
+		if isDelta && !psess.hasSendSnapshot {
+			if len(p.caches) == 0 {
+				WSErrorInc("public_delta", "no_snapshot")
+				return true
+			}
+			// 首次或者之前发送失败,丢失了部分消息,需要重新发送全量数据
+			WSCounterInc("public_delta_resend", p.conf.Topic)
+			if err := p.resendSnapshot(psess); err != nil {
+				if !errors.Is(err, errSessionWriteChannelDiscard) {
+					invalidSessions = append(invalidSessions, psess.ID())
+				}
+				return true
+			}
+		}
+
 		err := psess.Write(data)
 		if err != nil {
 			if errors.Is(err, errSessionWriteChannelDiscard) {
+				// 队列已满,消息丢失,下次广播时重发全量数据
 				glog.Infof(context.Background(), "public worker write discard, id=%v", psess.ID())
-				psess.Stop()
+				psess.hasSendSnapshot = false
+			} else {
+				invalidSessions = append(invalidSessions, psess.ID())
 			}
-			invalidSessions = append(invalidSessions, psess.ID())
 		} else {
+			if !isDelta {
+				psess.SetHasSendSnapshot()
+			}
 			count++
 		}
 
@@ -211,3 +273,20 @@ func (p *publicWorkerDelta) Broadcast(msg *publicMessage, isDelta bool) {
 	p.DeleteSessions(invalidSessions)
 	p.recordMetric(msg, start, count)
 }
+
+// withPublicSeq 在json推送中插入seq和prev_seq字段,非json object则原样返回
+func withPublicSeq(data []byte, seq, prevSeq uint64) []byte {
+	if len(data) < 2 || data[0] != '{' {
+		return data
+	}
+
+	res := make([]byte, 0, len(data)+48)
+	res = append(res, `{"seq":`...)
+	res = strconv.AppendUint(res, seq, 10)
+	res = append(res, `,"prev_seq":`...)
+	res = strconv.AppendUint(res, prevSeq, 10)
+	if data[1] != '}' {
+		res = append(res, ',')
+	}
+	return append(res, data[1:]...)
+}
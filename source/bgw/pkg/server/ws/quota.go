@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+)
+
+// checkConnectQuota 建立连接前校验ip和uid的连接数,避免无效的upgrade,最终以AddSession和Bind的校验为准
+func checkConnectQuota(ip string, uid int64) CodeError {
+	if err := gSessionMgr.CheckIP(ip); err != nil {
+		return toCodeErr(err)
+	}
+
+	if uid <= 0 {
+		return nil
+	}
+
+	user := GetUserMgr().GetUser(uid)
+	if user != nil && len(user.GetSessions()) >= getDynamicConf().MaxSessionsPerUser {
+		WSCounterInc("quota", "max_user_limit")
+		return newCodeErrFrom(errTooManySession, "exceed user max size, uid=%v", uid)
+	}
+
+	return nil
+}
+
+// quotaHTTPStatus upgrade失败时返回的http状态码
+func quotaHTTPStatus(err CodeError) int {
+	if isError(err, errIPInBlacklist) {
+		return http.StatusForbidden
+	}
+
+	return http.StatusTooManyRequests
+}
+
+// checkAPIKeyQuota 校验apikey的连接数,开启session目录时为集群维度,查询失败或超时降级为本机
+func checkAPIKeyQuota(uid int64, sess Session) CodeError {
+	limit := getDynamicConf().MaxSessionsPerApiKey
+	apiKey := sess.GetClient().GetAPIKey()
+	if limit <= 0 || apiKey == "" {
+		return nil
+	}
+
+	count := -1
+	if gSessionDir.Enabled() {
+		ctx, cancel := context.WithTimeout(context.Background(), dirQuotaTimeout)
+		n, err := gSessionDir.CountByAPIKey(ctx, apiKey)
+		cancel()
+		if err == nil {
+			count = n
+		} else {
+			WSErrorInc("quota", "count_apikey_fail")
+		}
+	}
+
+	if count < 0 {
+		count = localAPIKeyCount(uid, apiKey, sess.ID())
+	}
+
+	if count >= limit {
+		WSCounterInc("quota", "max_apikey_limit")
+		glog.Info(context.Background(), "session exceed apikey limit", glog.Int64("uid", uid), glog.String("api_key", apiKey), glog.Int("count", count))
+		return newCodeErrFrom(errAPIKeyConnLimit, "api_key=%v, count=%v", apiKey, count)
+	}
+
+	return nil
+}
+
+// localAPIKeyCount 本机apikey连接数,apikey只属于一个用户,因此只需要遍历用户的session
+func localAPIKeyCount(uid int64, apiKey string, excludeID string) int {
+	user := GetUserMgr().GetUser(uid)
+	if user == nil {
+		return 0
+	}
+
+	count := 0
+	for _, s := range user.GetSessions() {
+		if s.ID() != excludeID && s.GetClient().GetAPIKey() == apiKey {
+			count++
+		}
+	}
+	return count
+}
+
+// checkTopicQuota 校验单个连接的订阅数,已经订阅的topic不占用新的配额
+func checkTopicQuota(sess Session, topics []string) (successes []string, fails []string) {
+	limit := getDynamicConf().MaxTopicsPerSession
+	if limit <= 0 {
+		return topics, nil
+	}
+
+	current := sess.GetClient().GetTopics()
+	size := len(current.Subscriptions())
+	successes = make([]string, 0, len(topics))
+	added := make(map[string]struct{}, len(topics))
+	for _, t := range topics {
+		if _, ok := added[t]; ok || current.HasSubscription(t) {
+			successes = append(successes, t)
+			continue
+		}
+
+		if size >= limit {
+			fails = append(fails, t)
+			continue
+		}
+		size++
+		added[t] = struct{}{}
+		successes = append(successes, t)
+	}
+
+	if len(fails) > 0 {
+		WSCounterInc("quota", "max_topic_limit")
+	}
+
+	return
+}
+
+// isQuotaError 配额相关的错误,option协议需要转换为对应的错误码
+func isQuotaError(err CodeError) bool {
+	switch err.Code() {
+	case errTooManySession.Code(), errTooManySessionPerIP.Code(), errAPIKeyConnLimit.Code(), errTooManyTopics.Code(), errReqLimit.Code():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,99 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTopicQuota(t *testing.T) {
+	sconf := getDynamicConf()
+	defer func() { sconf.MaxTopicsPerSession = 0 }()
+
+	sess := newMockSession(0)
+	sess.GetClient().Subscribe([]string{"order", "position"})
+
+	sconf.MaxTopicsPerSession = 0
+	successes, fails := checkTopicQuota(sess, []string{"wallet", "execution"})
+	assert.Equal(t, []string{"wallet", "execution"}, successes)
+	assert.Nil(t, fails)
+
+	sconf.MaxTopicsPerSession = 3
+	successes, fails = checkTopicQuota(sess, []string{"order", "wallet", "wallet", "execution"})
+	assert.Equal(t, []string{"order", "wallet", "wallet"}, successes)
+	assert.Equal(t, []string{"execution"}, fails)
+}
+
+func TestCheckAPIKeyQuota(t *testing.T) {
+	sconf := getDynamicConf()
+	defer func() { sconf.MaxSessionsPerApiKey = 0 }()
+
+	uid := int64(34567)
+	s1 := newMockSession(uid)
+	s1.GetClient().SetAPIKey("key1")
+	_, err := GetUserMgr().Bind(uid, s1)
+	assert.Nil(t, err)
+	defer GetUserMgr().Unbind(uid, s1.ID())
+
+	s2 := newMockSession(uid)
+	s2.GetClient().SetAPIKey("key1")
+	assert.Nil(t, checkAPIKeyQuota(uid, s2))
+
+	sconf.MaxSessionsPerApiKey = 1
+	cerr := checkAPIKeyQuota(uid, s2)
+	assert.NotNil(t, cerr)
+	assert.True(t, isError(cerr, errAPIKeyConnLimit))
+	assert.True(t, isQuotaError(cerr))
+
+	s2.GetClient().SetAPIKey("key2")
+	assert.Nil(t, checkAPIKeyQuota(uid, s2))
+}
+
+type slowDirStore struct {
+	*fakeDirStore
+}
+
+func (s *slowDirStore) HgetallCtx(ctx context.Context, key string) (map[string]string, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCheckAPIKeyQuotaWithSessionDir(t *testing.T) {
+	sconf := getDynamicConf()
+	sconf.MaxSessionsPerApiKey = 2
+	defer func() { sconf.MaxSessionsPerApiKey = 0 }()
+
+	old := gSessionDir
+	defer func() { gSessionDir = old }()
+
+	uid := int64(34568)
+	s1 := newMockSession(uid)
+	s1.GetClient().SetAPIKey("key3")
+
+	store := newFakeDirStore()
+	_ = store.HsetCtx(context.Background(), dirAPIKeyKey("key3"), "s2", "node2")
+	_ = store.HsetCtx(context.Background(), dirAPIKeyKey("key3"), "s3", "node3")
+	gSessionDir = &sessionDir{store: store, node: "node1"}
+	gSessionDir.running.Store(true)
+
+	// 节点缓存未加载,降级为本机
+	assert.Nil(t, checkAPIKeyQuota(uid, s1))
+
+	alive := map[string]struct{}{"node1": {}, "node2": {}, "node3": {}}
+	gSessionDir.alive.Store(&alive)
+	cerr := checkAPIKeyQuota(uid, s1)
+	assert.True(t, isError(cerr, errAPIKeyConnLimit))
+
+	// node3已下线
+	alive = map[string]struct{}{"node1": {}, "node2": {}}
+	gSessionDir.alive.Store(&alive)
+	assert.Nil(t, checkAPIKeyQuota(uid, s1))
+
+	// redis超时降级为本机,不阻塞鉴权
+	gSessionDir.store = &slowDirStore{fakeDirStore: store}
+	start := time.Now()
+	assert.Nil(t, checkAPIKeyQuota(uid, s1))
+	assert.Less(t, time.Since(start), dirRedisTimeout)
+}
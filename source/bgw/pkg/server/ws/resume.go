@@ -0,0 +1,271 @@
+package ws
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+)
+
+// replayItem 缓存的私有推送
+type replayItem struct {
+	seq         uint64
+	topic       string
+	data        []byte
+	passthrough bool   // 透传消息,不校验topic
+	sessionID   string // 指定session推送
+}
+
+// replayBuffer 用户最近推送的环形缓冲区,seq在用户维度单调递增
+// 用户离线后保留ResumeTTL,以便断线重连后补发
+type replayBuffer struct {
+	mux      sync.Mutex
+	seq      uint64
+	items    []*replayItem
+	head     int   // 最早一条的位置
+	size     int   //
+	lastTime int64 // 最后活跃时间,用于离线后过期清理
+}
+
+func newReplayBuffer(capacity int) *replayBuffer {
+	return &replayBuffer{items: make([]*replayItem, capacity), lastTime: nowUnixNano()}
+}
+
+// Append 记录推送并返回seq,容量变化时丢弃旧数据
+func (b *replayBuffer) Append(item *replayItem, capacity int) uint64 {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if capacity != len(b.items) {
+		b.items = make([]*replayItem, capacity)
+		b.head, b.size = 0, 0
+	}
+
+	b.seq++
+	item.seq = b.seq
+	b.lastTime = nowUnixNano()
+
+	if b.size < len(b.items) {
+		b.items[(b.head+b.size)%len(b.items)] = item
+		b.size++
+	} else {
+		b.items[b.head] = item
+		b.head = (b.head + 1) % len(b.items)
+	}
+
+	return item.seq
+}
+
+// Since 返回seq之后的推送,如果中间有消息已经被覆盖则返回false
+func (b *replayBuffer) Since(seq uint64) ([]*replayItem, bool) {
+	return b.Snapshot(seq, nil)
+}
+
+// Snapshot 同Since,并在持有锁时执行fn(如订阅),fn之后记录的推送不会出现在结果中,由实时推送下发
+func (b *replayBuffer) Snapshot(seq uint64, fn func()) ([]*replayItem, bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if fn != nil {
+		fn()
+	}
+
+	if seq >= b.seq {
+		return nil, true
+	}
+
+	missing := int(b.seq - seq)
+	if missing > b.size {
+		return nil, false
+	}
+
+	res := make([]*replayItem, 0, missing)
+	for i := b.size - missing; i < b.size; i++ {
+		res = append(res, b.items[(b.head+i)%len(b.items)])
+	}
+	return res, true
+}
+
+func (b *replayBuffer) touch() {
+	b.mux.Lock()
+	b.lastTime = nowUnixNano()
+	b.mux.Unlock()
+}
+
+func (b *replayBuffer) expired(now, ttl int64) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return now-b.lastTime > ttl
+}
+
+// resumeState 断开session的状态,通过旧的conn_id恢复
+type resumeState struct {
+	uid        int64
+	topics     []string
+	expireTime int64
+}
+
+// recordPush 记录私有推送,返回seq,未开启resume时返回0
+// 用户离线后只要缓冲区未过期仍然会记录,重连后补发
+func (um *userMgr) recordPush(uid int64, topic string, data []byte, passthrough bool, sessionID string) uint64 {
+	capacity := getDynamicConf().ResumeBufferSize
+	if capacity <= 0 || uid <= 0 {
+		return 0
+	}
+
+	v, ok := um.replays.Load(uid)
+	if !ok {
+		if um.GetUser(uid) == nil {
+			return 0
+		}
+		v, _ = um.replays.LoadOrStore(uid, newReplayBuffer(capacity))
+	}
+
+	item := &replayItem{topic: topic, data: data, passthrough: passthrough, sessionID: sessionID}
+	return v.(*replayBuffer).Append(item, capacity)
+}
+
+// saveResume 保存断开session的订阅,用于重连后resume
+func (um *userMgr) saveResume(sess Session) {
+	dconf := getDynamicConf()
+	uid := sess.GetClient().GetMemberId()
+	if dconf.ResumeBufferSize <= 0 || uid <= 0 {
+		return
+	}
+
+	if v, ok := um.replays.Load(uid); ok {
+		v.(*replayBuffer).touch()
+	}
+	um.resumes.Store(sess.ID(), &resumeState{
+		uid:        uid,
+		topics:     sess.GetClient().GetTopics().Subscriptions(),
+		expireTime: nowUnixNano() + int64(dconf.ResumeTTL),
+	})
+}
+
+// takeResume 取出旧session的状态,只能被同一个用户使用一次
+func (um *userMgr) takeResume(sessID string, uid int64) *resumeState {
+	v, ok := um.resumes.Load(sessID)
+	if !ok {
+		return nil
+	}
+
+	state := v.(*resumeState)
+	if state.uid != uid {
+		return nil
+	}
+	um.resumes.Delete(sessID)
+	if nowUnixNano() > state.expireTime {
+		return nil
+	}
+	return state
+}
+
+// replay 执行订阅并返回seq之后的推送,订阅与快照相对记录推送是原子的,订阅之前记录的推送都在快照中补发
+func (um *userMgr) replay(uid int64, seq uint64, subscribe func()) ([]*replayItem, bool) {
+	v, ok := um.replays.Load(uid)
+	if !ok {
+		subscribe()
+		return nil, false
+	}
+	return v.(*replayBuffer).Snapshot(seq, subscribe)
+}
+
+// gcResumes 清理过期的resume状态和离线用户的缓冲区
+func (um *userMgr) gcResumes() {
+	now := nowUnixNano()
+	ttl := int64(getDynamicConf().ResumeTTL)
+	um.resumes.Range(func(key, value any) bool {
+		if now > value.(*resumeState).expireTime {
+			um.resumes.Delete(key)
+		}
+		return true
+	})
+
+	count := 0
+	um.replays.Range(func(key, value any) bool {
+		uid := key.(int64)
+		if um.GetUser(uid) == nil && value.(*replayBuffer).expired(now, ttl) {
+			um.replays.Delete(key)
+			return true
+		}
+		count++
+		return true
+	})
+	WSGauge(float64(count), "user_manager", "replay_buffers")
+}
+
+// onResume 恢复旧session的订阅,并返回需要补发的推送,需要先鉴权
+func onResume(sess Session, prevID string, seq uint64) ([]*replayItem, CodeError) {
+	uid := sess.GetClient().GetMemberId()
+	if uid <= 0 {
+		WSCounterInc("resume", "not_auth")
+		return nil, errNotAuthorized
+	}
+
+	state := gUserMgr.takeResume(prevID, uid)
+	if state == nil {
+		WSCounterInc("resume", "expired")
+		return nil, errResumeExpired
+	}
+
+	items, ok := gUserMgr.replay(uid, seq, func() {
+		if len(state.topics) > 0 {
+			_, _, changed, _ := onSubscribe(sess, state.topics)
+			gPublicMgr.OnSubscribe(sess, changed)
+		}
+	})
+	if !ok {
+		WSCounterInc("resume", "gap")
+		return nil, errResumeGap
+	}
+
+	filtered := items[:0:0]
+	for _, item := range items {
+		switch {
+		case item.sessionID != "":
+			if item.sessionID == prevID {
+				filtered = append(filtered, item)
+			}
+		case item.passthrough || sess.GetClient().MatchTopic(item.topic, item.data):
+			filtered = append(filtered, item)
+		}
+	}
+
+	WSCounterInc("resume", "success")
+	glog.Info(context.Background(), "session resume",
+		glog.String("sess_id", sess.ID()),
+		glog.String("prev_id", prevID),
+		glog.Int64("uid", uid),
+		glog.String("seq", strconv.FormatUint(seq, 10)),
+		glog.Int("replay", len(filtered)),
+	)
+	return filtered, nil
+}
+
+// withSeq 在json推送中插入seq字段,非json object则原样返回
+func withSeq(data []byte, seq uint64) []byte {
+	if len(data) < 2 || data[0] != '{' {
+		return data
+	}
+
+	res := make([]byte, 0, len(data)+32)
+	res = append(res, `{"seq":`...)
+	res = strconv.AppendUint(res, seq, 10)
+	if data[1] != '}' {
+		res = append(res, ',')
+	}
+	return append(res, data[1:]...)
+}
+
+// replayPushes 补发推送,需要在resume返回之后发送
+func replayPushes(sess Session, items []*replayItem) {
+	for _, item := range items {
+		err := sess.Write(&Message{Type: MsgTypePush, Data: item.data, Topic: item.topic, Seq: item.seq})
+		if err != nil {
+			WSCounterInc("resume", "replay_fail")
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayBuffer(t *testing.T) {
+	b := newReplayBuffer(3)
+	for i := 1; i <= 5; i++ {
+		assert.Equal(t, uint64(i), b.Append(&replayItem{topic: "order"}, 3))
+	}
+
+	items, ok := b.Since(5)
+	assert.True(t, ok)
+	assert.Empty(t, items)
+
+	items, ok = b.Since(2)
+	assert.True(t, ok)
+	assert.Len(t, items, 3)
+	assert.Equal(t, uint64(3), items[0].seq)
+	assert.Equal(t, uint64(5), items[2].seq)
+
+	// seq 2 has been overwritten
+	_, ok = b.Since(1)
+	assert.False(t, ok)
+
+	// capacity changed, old items are dropped but seq goes on
+	assert.Equal(t, uint64(6), b.Append(&replayItem{}, 4))
+	_, ok = b.Since(4)
+	assert.False(t, ok)
+	items, ok = b.Since(5)
+	assert.True(t, ok)
+	assert.Len(t, items, 1)
+
+	assert.False(t, b.expired(nowUnixNano(), int64(defaultResumeTTL)))
+
+	// fn runs before any later push is recorded
+	called := false
+	items, ok = b.Snapshot(5, func() { called = true })
+	assert.True(t, called)
+	assert.True(t, ok)
+	assert.Len(t, items, 1)
+	assert.Equal(t, uint64(6), items[0].seq)
+}
+
+func TestWithSeq(t *testing.T) {
+	assert.Equal(t, `{"seq":7,"topic":"order"}`, string(withSeq([]byte(`{"topic":"order"}`), 7)))
+	assert.Equal(t, `{"seq":7}`, string(withSeq([]byte(`{}`), 7)))
+	assert.Equal(t, `[1]`, string(withSeq([]byte(`[1]`), 7)))
+}
@@ -0,0 +1,234 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+
+	"bgw/pkg/common/types"
+	"bgw/pkg/server/core"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/metadata"
+)
+
+// rpcRequest op=rpc的请求参数, args: [method, path, params, headers]
+// GET请求params作为query参数, POST请求params作为json body
+type rpcRequest struct {
+	method  string
+	path    string
+	query   string
+	payload []byte
+	header  map[string]string
+}
+
+// parseRPCArgs 解析rpc参数
+func parseRPCArgs(args []interface{}) (*rpcRequest, CodeError) {
+	if len(args) < 2 {
+		return nil, errEmptyParameter
+	}
+
+	req := &rpcRequest{
+		method: strings.ToUpper(toString(args[0])),
+		path:   toString(args[1]),
+		header: make(map[string]string),
+	}
+	if req.method != http.MethodGet && req.method != http.MethodPost {
+		return nil, newCodeErrFrom(errParamsErr, "invalid method, %v", req.method)
+	}
+	if !strings.HasPrefix(req.path, "/") {
+		return nil, newCodeErrFrom(errParamsErr, "invalid path, %v", req.path)
+	}
+
+	if len(args) >= 3 && args[2] != nil {
+		params, ok := args[2].(map[string]interface{})
+		if !ok {
+			return nil, newCodeErrFrom(errParamsErr, "params not json")
+		}
+
+		if req.method == http.MethodGet {
+			query := make(url.Values, len(params))
+			for k, v := range params {
+				query.Set(k, toString(v))
+			}
+			req.query = query.Encode()
+		} else {
+			payload, err := jsonMarshal(params)
+			if err != nil {
+				return nil, newCodeErrFrom(errParamsErr, "params marshal fail, %v", err)
+			}
+			req.payload = payload
+		}
+	}
+
+	if len(args) >= 4 && args[3] != nil {
+		headers, ok := args[3].(map[string]interface{})
+		if !ok {
+			return nil, newCodeErrFrom(errParamsErr, "headers not json")
+		}
+		for k, v := range headers {
+			if vs, ok := v.(string); ok {
+				req.header[k] = vs
+			}
+		}
+	}
+
+	return req, nil
+}
+
+var gRPCLimiter = &rpcLimiter{inflight: make(map[string]int)}
+
+// rpcLimiter 限制单个连接同时处理的rpc请求数
+type rpcLimiter struct {
+	mux      sync.Mutex
+	inflight map[string]int // session id -> 处理中的请求数
+}
+
+func (l *rpcLimiter) Acquire(sessID string, limit int) bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.inflight[sessID] >= limit {
+		return false
+	}
+	l.inflight[sessID]++
+	return true
+}
+
+func (l *rpcLimiter) Release(sessID string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if n := l.inflight[sessID] - 1; n > 0 {
+		l.inflight[sessID] = n
+	} else {
+		delete(l.inflight, sessID)
+	}
+}
+
+// onRPC 调用允许websocket访问(allow_wss)的路由,异步处理,通过req_id对应返回结果
+func onRPC(sess Session, reqID string, args []interface{}) CodeError {
+	if sess.GetClient().GetAPIKey() == "" {
+		return errDeniedAPIKey
+	}
+
+	req, err := parseRPCArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if !gRPCLimiter.Acquire(sess.ID(), getDynamicConf().MaxRPCPerSession) {
+		WSCounterInc("rpc", "concurrency_limit")
+		return errReqLimit
+	}
+
+	// 超时返回后处理协程仍在执行,由处理协程结束时释放并发数
+	var once sync.Once
+	release := func() {
+		once.Do(func() { gRPCLimiter.Release(sess.ID()) })
+	}
+
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				release()
+				dumpPanic("rpc panic", fmt.Errorf("%v", e))
+			}
+		}()
+
+		start := time.Now()
+		body, header, err := doRPC(sess, req, release)
+		WSHistogram(start, "rpc", req.method)
+		rsp := &responseTradeV3{
+			OP:     opRPC,
+			ReqID:  reqID,
+			ConnID: sess.ID(),
+		}
+		if err == nil {
+			rsp.Success = true
+			rsp.Body = body
+			rsp.Header = header
+		} else {
+			WSCounterInc("rpc", "fail")
+			rsp.RetMsg = toCodeErr(err).Error()
+		}
+		_ = sendResponse(sess, rsp)
+	}()
+
+	return nil
+}
+
+type rpcResult struct {
+	body   []byte
+	header map[string]string
+	err    error
+}
+
+// doRPC 查找路由并校验allow_wss,超时时间使用路由配置的timeout,
+// done在调用真正结束时执行,超时返回时由处理协程结束后执行
+func doRPC(sess Session, req *rpcRequest, done func()) ([]byte, map[string]string, error) {
+	ctx := acquireRouteCtx(sess, req.method, req.path, req.query, req.payload, req.header)
+
+	ctrl := core.GetController(ctx)
+	route, err := ctrl.GetRouteManager().FindRoute(ctx, core.NewCtxRouteDataProvider(ctx, nil, nil))
+	if err != nil {
+		releaseRouteCtx(ctx)
+		done()
+		return nil, nil, err
+	}
+	mc := ctrl.GetRouteManager().GetMethodConfig(route)
+	if route == nil || mc == nil || !mc.GetAllowWSS() {
+		releaseRouteCtx(ctx)
+		done()
+		WSCounterInc("rpc", "not_allowed")
+		return nil, nil, newCodeErrFrom(errRPCNotAllowed, "%v %v", req.method, req.path)
+	}
+	handler, _ := route.Handler.(types.Handler)
+	if handler == nil {
+		releaseRouteCtx(ctx)
+		done()
+		return nil, nil, errServiceNotAvailable
+	}
+
+	chain, err := filter.GlobalChain().AppendNames(filter.IPRateLimitFilterKey)
+	if err != nil {
+		releaseRouteCtx(ctx)
+		done()
+		return nil, nil, errServiceNotAvailable
+	}
+
+	// 超时后直接返回,ctx由处理协程回收
+	resCh := make(chan *rpcResult, 1)
+	go func() {
+		defer done()
+		defer releaseRouteCtx(ctx)
+		defer func() {
+			if e := recover(); e != nil {
+				dumpPanic("rpc invoke panic", fmt.Errorf("%v", e))
+				resCh <- &rpcResult{err: errServiceNotAvailable}
+			}
+		}()
+
+		metadata.MDFromContext(ctx).StaticRoutePath = route.Path
+		if err := chain.Finally(handler)(ctx); err != nil {
+			resCh <- &rpcResult{err: err}
+			return
+		}
+		body, header := routeResponse(ctx)
+		resCh <- &rpcResult{body: body, header: header}
+	}()
+
+	timer := time.NewTimer(mc.GetTimeout())
+	defer timer.Stop()
+	select {
+	case res := <-resCh:
+		return res.body, res.header, res.err
+	case <-timer.C:
+		WSCounterInc("rpc", "timeout")
+		glog.Info(context.Background(), "rpc timeout", glog.String("sess_id", sess.ID()), glog.String("path", req.path), glog.Duration("timeout", mc.GetTimeout()))
+		return nil, nil, errRPCTimeout
+	}
+}
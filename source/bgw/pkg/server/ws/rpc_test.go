@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRPCArgs(t *testing.T) {
+	_, err := parseRPCArgs([]interface{}{"POST"})
+	assert.Equal(t, errEmptyParameter, err)
+
+	_, err = parseRPCArgs([]interface{}{"PUT", "/v5/order/amend"})
+	assert.True(t, isError(err, errParamsErr))
+
+	_, err = parseRPCArgs([]interface{}{"POST", "v5/order/amend"})
+	assert.True(t, isError(err, errParamsErr))
+
+	_, err = parseRPCArgs([]interface{}{"POST", "/v5/order/amend", "body"})
+	assert.True(t, isError(err, errParamsErr))
+
+	req, err := parseRPCArgs([]interface{}{"post", "/v5/order/amend", map[string]interface{}{"symbol": "BTCUSDT"}, map[string]interface{}{"X-Referer": "test", "ignored": 1}})
+	assert.Nil(t, err)
+	assert.Equal(t, "POST", req.method)
+	assert.Equal(t, `{"symbol":"BTCUSDT"}`, string(req.payload))
+	assert.Equal(t, "", req.query)
+	assert.Equal(t, map[string]string{"X-Referer": "test"}, req.header)
+
+	req, err = parseRPCArgs([]interface{}{"GET", "/v5/order/realtime", map[string]interface{}{"category": "linear", "limit": 10}})
+	assert.Nil(t, err)
+	assert.Equal(t, "category=linear&limit=10", req.query)
+	assert.Nil(t, req.payload)
+}
+
+func TestRPCLimiter(t *testing.T) {
+	l := &rpcLimiter{inflight: make(map[string]int)}
+	assert.True(t, l.Acquire("s1", 2))
+	assert.True(t, l.Acquire("s1", 2))
+	assert.False(t, l.Acquire("s1", 2))
+	assert.True(t, l.Acquire("s2", 2))
+
+	l.Release("s1")
+	assert.True(t, l.Acquire("s1", 2))
+	l.Release("s1")
+	l.Release("s1")
+	l.Release("s2")
+	assert.Equal(t, 0, len(l.inflight))
+}
@@ -70,6 +70,7 @@ func (s *Server) Start() error {
 	initExchange()
 	gTickerMgr.Start()
 	gMetricsMgr.Start()
+	gSessionDir.Start()
 
 	if err := s.grpc.Start(); err != nil {
 		return err
@@ -98,6 +99,7 @@ func (s *Server) Stop() error {
 	glog.Info(context.Background(), "start to stop websocket server")
 	s.ws.Stop()
 	gSessionMgr.Close()
+	gSessionDir.Stop()
 
 	glog.Info(context.Background(), "start to stop grpc server")
 	s.grpc.Stop()
@@ -62,6 +62,7 @@ func (s *wsServer) Start() error {
 		WriteBufferSize:   wsConf.WriteBufferSize,
 		WriteBufferPool:   &sync.Pool{},
 		EnableCompression: wsConf.Compression,
+		Subprotocols:      []string{subprotocolProtobuf},
 		CheckOrigin: func(ctx *fasthttp.RequestCtx) bool {
 			return true
 		},
@@ -141,7 +142,11 @@ func (s *wsServer) RequestHandler() RequestHandler {
 	for _, p := range paths {
 		path, version := parsePathAndVersion(p)
 		glog.Info(context.Background(), "ws server register new path", glog.String("path", p), glog.String("version", version.String()))
-		if version != versionNone {
+		switch version {
+		case versionNone:
+		case versionStream:
+			r.GET(path, s.stream())
+		default:
 			r.GET(path, s.upgrade(version))
 		}
 	}
@@ -190,6 +195,13 @@ func (s *wsServer) upgrade(vt versionType) RequestHandler {
 			}
 		}
 
+		// 连接数配额
+		if cerr := checkConnectQuota(bhttp.GetRemoteIP(ctx), uid); cerr != nil {
+			WSCounterInc("ws_server", "quota_limit")
+			ctx.Error("upgrade error: "+cerr.Error(), quotaHTTPStatus(cerr))
+			return
+		}
+
 		err = s.upgrader.Upgrade(ctx, func(conn *WSConn) {
 			s.doUpgrade(ctx, conn, vt, uid)
 		})
@@ -269,6 +281,7 @@ func (s *wsServer) doUpgrade(ctx *types.Ctx, conn *WSConn, vt versionType, uid i
 		glog.String("id", sess.ID()),
 		glog.String("ip", ip),
 		glog.String("protocol", vt.String()),
+		glog.String("format", sess.format.String()),
 		glog.String("host", string(ctx.Host())),
 		glog.String("path", string(ctx.Path())),
 		glog.String("query", ctx.QueryArgs().String()),
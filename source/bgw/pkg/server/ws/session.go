@@ -7,6 +7,7 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -38,9 +39,13 @@ func newSessionID() (string, string) {
 }
 
 type SessionStatus struct {
-	WriteCount  int64
-	DropCount   int64
-	MaxIdleTime time.Duration
+	WriteCount   int64
+	DropCount    int64
+	MaxIdleTime  time.Duration
+	RTT          time.Duration
+	QueueSize    int   // 发送队列当前深度
+	MaxQueueSize int64 // 发送队列最大深度
+	SnapshotOnly bool  // 消费过慢,公有增量topic只推送全量数据
 }
 
 // Session write message to client.
@@ -49,6 +54,8 @@ type Session interface {
 	ShortID() string
 	// Allow check command rate limit
 	Allow() bool
+	// AllowSubscribe check subscribe rate limit
+	AllowSubscribe() bool
 	IsAuthed() bool
 	SetMember(uid int64) error
 	ProtocolVersion() versionType
@@ -58,6 +65,8 @@ type Session interface {
 	Write(msg *Message) error
 	Stop()
 	OnTick()
+	// OnPong client reply of server heartbeat
+	OnPong(reqID string)
 }
 
 type session struct {
@@ -65,6 +74,7 @@ type session struct {
 	id          string
 	shortId     string
 	version     versionType
+	format      frameFormat // frame encoding negotiated on connect
 	handler     Handler
 	client      Client
 	running     int32
@@ -72,18 +82,50 @@ type session struct {
 	sendCh      chan *Message
 	closeCh     chan struct{}
 	limit       rateLimit     //
+	subLimit    rateLimit     // 订阅限频
 	writeCount  int64         // 总发送量
 	dropCount   int64         // 丢弃数量
 	startTime   time.Time     // 起始时间
 	maxIdleTime time.Duration // 超过此时间则会强制断开连接,误差取决于ticker的执行周期
+
+	policy          slowConsumerPolicy  // 发送队列满时的处理策略
+	maxQueueSize    int64               // 发送队列最大深度
+	snapshotOnly    int32               // 是否降级为只推送全量数据
+	kicked          int32               // 是否因消费过慢被断开
+	conflateCh      chan struct{}       // 有合并的消息待发送
+	conflateMux     sync.Mutex          //
+	conflated       map[string]*Message // topic->最新消息
+	conflatedTopics []string            // 保证发送顺序
+	conflatedSize   int32               //
+
+	hb heartbeatState // 服务端心跳
 }
 
 func newSession(conn *WSConn, client Client, version versionType) *session {
 	sconf := getDynamicConf()
 
 	maxActiveTime := ""
+	formatParam := ""
+	heartbeatParam := ""
+	path := ""
 	if client != nil {
 		maxActiveTime = client.GetParams()[paramKeyMaxActiveTime]
+		formatParam = client.GetParams()[urlParamKeyFormat]
+		heartbeatParam = client.GetParams()[urlParamKeyHeartbeat]
+		path = client.GetPath()
+	}
+
+	subprotocol := ""
+	if conn != nil {
+		subprotocol = conn.Subprotocol()
+	}
+	format := parseFrameFormat(formatParam, subprotocol)
+	handler := getHandler(version)
+	if format == frameFormatProtobuf && handler == gHandlerV3 {
+		handler = gHandlerProto
+	} else {
+		// binary frame is only supported by v3/v5
+		format = frameFormatJSON
 	}
 
 	id, shortId := newSessionID()
@@ -92,7 +134,8 @@ func newSession(conn *WSConn, client Client, version versionType) *session {
 		id:          id,
 		shortId:     shortId,
 		version:     version,
-		handler:     getHandler(version),
+		format:      format,
+		handler:     handler,
 		sendCh:      make(chan *Message, sconf.SessionBufferSize),
 		closeCh:     make(chan struct{}),
 		running:     0,
@@ -100,8 +143,15 @@ func newSession(conn *WSConn, client Client, version versionType) *session {
 		client:      client,
 		startTime:   time.Now(),
 		maxIdleTime: parseMaxActiveTime(maxActiveTime),
+		policy:      getSlowConsumerPolicy(path),
+	}
+	if s.policy == policyConflate {
+		s.conflateCh = make(chan struct{}, 1)
 	}
+	// 服务端心跳只支持v3/v5协议
+	s.hb.enable = parseHeartbeatParam(heartbeatParam) && s.supportResume()
 	s.limit.Set(int64(sconf.SessionCmdRateLimit), sconf.SessionCmdRatePeriod)
+	s.subLimit.Set(int64(sconf.MaxSubscribePerMinute), time.Minute)
 
 	return s
 }
@@ -132,9 +182,13 @@ func (s *session) ShortID() string {
 
 func (s *session) GetStatus() SessionStatus {
 	return SessionStatus{
-		WriteCount:  atomic.LoadInt64(&s.writeCount),
-		DropCount:   atomic.LoadInt64(&s.dropCount),
-		MaxIdleTime: s.maxIdleTime,
+		WriteCount:   atomic.LoadInt64(&s.writeCount),
+		DropCount:    atomic.LoadInt64(&s.dropCount),
+		MaxIdleTime:  s.maxIdleTime,
+		RTT:          time.Duration(atomic.LoadInt64(&s.hb.rtt)),
+		QueueSize:    len(s.sendCh),
+		MaxQueueSize: atomic.LoadInt64(&s.maxQueueSize),
+		SnapshotOnly: atomic.LoadInt32(&s.snapshotOnly) == 1,
 	}
 }
 
@@ -150,6 +204,10 @@ func (s *session) Allow() bool {
 	return s.limit.Allow()
 }
 
+func (s *session) AllowSubscribe() bool {
+	return s.subLimit.Allow()
+}
+
 func (s *session) ProtocolVersion() versionType {
 	return s.version
 }
@@ -185,22 +243,44 @@ func (s *session) Write(msg *Message) error {
 
 	if msg.Type == MsgTypeReply {
 		s.sendCh <- msg
+		s.updateQueueSize()
+		return nil
+	}
+
+	if msg.Type == MsgTypeHeartbeat {
+		select {
+		case s.sendCh <- msg:
+			return nil
+		default:
+			WSCounterInc("session", "heartbeat_discard")
+			return errSessionWriteChannelDiscard
+		}
+	}
+
+	if s.policy == policyConflate && s.tryConflate(msg) {
 		return nil
 	}
 
 	select {
 	case s.sendCh <- msg:
+		s.updateQueueSize()
 		return nil
 	default:
-		atomic.AddInt64(&s.dropCount, 1)
-		WSErrorInc("session", "write_discard")
-		return errSessionWriteChannelDiscard
+		return s.onQueueFull(msg)
 	}
 }
 
+// supportResume only v3/v5 protocol support resume and push seq
+func (s *session) supportResume() bool {
+	return s.handler == gHandlerV3 || s.handler == gHandlerProto
+}
+
 func (s *session) Stop() {
 	if atomic.CompareAndSwapInt32(&s.running, 1, 0) {
 		glog.Info(context.Background(), "session stop", glog.String("sess_id", s.id), glog.Int64("uid", s.client.GetMemberId()), glog.String("client", s.client.String()))
+		if s.supportResume() {
+			gUserMgr.saveResume(s)
+		}
 		s.client.Close()
 		gSessionMgr.DelSession(s.id)
 		gPublicMgr.OnSessionStop(s)
@@ -248,6 +328,18 @@ func (s *session) loopSend() {
 			if err := s.write(msg); err != nil {
 				return
 			}
+			if len(s.sendCh) == 0 {
+				if err := s.flushConflated(); err != nil {
+					return
+				}
+				s.restoreDelta()
+			}
+		case <-s.conflateCh:
+			if len(s.sendCh) == 0 {
+				if err := s.flushConflated(); err != nil {
+					return
+				}
+			}
 		case <-s.closeCh:
 			return
 		}
@@ -265,10 +357,29 @@ func (s *session) write(msg *Message) (err error) {
 
 	start := time.Now()
 	startUnixNano := start.UnixNano()
-	atomic.StoreInt64(&s.lastTime, startUnixNano)
+	if msg.Type == MsgTypeHeartbeat {
+		// 心跳不更新活跃时间,避免客户端无响应时无法按空闲时间断开
+		atomic.StoreInt64(&s.hb.sentAt, startUnixNano)
+	} else {
+		atomic.StoreInt64(&s.lastTime, startUnixNano)
+	}
+
+	mt, data := websocket.TextMessage, msg.Data
+	seq := msg.Seq
+	if !s.supportResume() {
+		seq = 0
+	}
+	if s.format == frameFormatProtobuf {
+		mt = websocket.BinaryMessage
+		if msg.Type == MsgTypePush {
+			data = marshalPushFrame(msg.Topic, msg.Data, seq)
+		}
+	} else if seq > 0 {
+		data = withSeq(msg.Data, seq)
+	}
 
 	_ = s.conn.SetWriteDeadline(start.Add(time.Second))
-	err = s.conn.WriteMessage(websocket.TextMessage, msg.Data)
+	err = s.conn.WriteMessage(mt, data)
 	if err != nil {
 		if e, ok := err.(net.Error); ok && e.Timeout() {
 			WSCounterInc("session", "write_timeout")
@@ -317,6 +428,9 @@ func (s *session) OnTick() {
 			)
 			WSCounterInc("session", "kick_by_idle")
 			s.Stop()
+			return
 		}
+
+		s.sendHeartbeat()
 	}
 }
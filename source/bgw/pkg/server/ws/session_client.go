@@ -32,6 +32,8 @@ type Client interface {
 	// 返回新增的取消订阅topics
 	Unsubscribe(topics []string) []string
 	HasTopic(topic string) bool
+	// MatchTopic 是否订阅了topic,并且数据满足订阅的过滤条件
+	MatchTopic(topic string, data []byte) bool
 	Close()
 	fmt.Stringer
 }
@@ -210,6 +212,11 @@ func (c *client) HasTopic(topic string) bool {
 	return c.GetTopics().Contains(topic)
 }
 
+// MatchTopic has topic and data matches the filters
+func (c *client) MatchTopic(topic string, data []byte) bool {
+	return c.GetTopics().Match(topic, data)
+}
+
 // Close close
 func (c *client) Close() {
 	topics := c.GetTopics().Values()
@@ -0,0 +1,647 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+
+	"bgw/pkg/remoting/redis"
+)
+
+const (
+	dirKeyPrefix        = "bgws:dir:"
+	dirKeyNodes         = dirKeyPrefix + "nodes" // node->nodeInfo
+	dirHeartbeatTime    = time.Second * 30       // 心跳间隔,同时全量刷新本节点的session
+	dirNodeExpireTime   = dirHeartbeatTime * 4   // 节点超过此时间没有心跳则认为已经下线
+	dirCommandPollTime  = time.Second            // 拉取其他节点发送的指令间隔
+	dirOpChannelSize    = 40960                  //
+	dirRedisTimeout     = time.Second * 3        //
+	dirQuotaTimeout     = time.Millisecond * 100 // 鉴权时查询apikey连接数的超时,超时降级为本机
+	dirCommandKick      = "kick"                 // 踢出用户
+	dirCommandKeyExpire = int(time.Minute / time.Second)
+)
+
+var (
+	errSessionDirDisabled = errors.New("session directory is disabled")
+	errSessionDirNoNodes  = errors.New("session directory nodes not loaded")
+)
+
+// dirStore 目录存储,默认使用redis
+type dirStore interface {
+	HsetCtx(ctx context.Context, key, field, value string) error
+	HdelCtx(ctx context.Context, key string, fields ...string) (bool, error)
+	HgetallCtx(ctx context.Context, key string) (map[string]string, error)
+	ExpireCtx(ctx context.Context, key string, seconds int) error
+	LpushCtx(ctx context.Context, key string, values ...any) (int, error)
+	LpopCtx(ctx context.Context, key string) (string, error)
+	HmsetCtx(ctx context.Context, key string, fieldsAndValues map[string]string) error
+	DelCtx(ctx context.Context, keys ...string) (int, error)
+}
+
+// dirSession 目录中记录的session信息
+type dirSession struct {
+	Node      string   `json:"node"`
+	SessionID string   `json:"session_id"`
+	UID       int64    `json:"uid"`
+	APIKey    string   `json:"api_key,omitempty"`
+	IP        string   `json:"ip"`
+	Path      string   `json:"path"`
+	Topics    []string `json:"topics,omitempty"`
+	StartTime int64    `json:"start_time"`
+}
+
+// dirNode 节点心跳信息
+type dirNode struct {
+	Node       string               `json:"node"`
+	UpdateTime int64                `json:"update_time"`
+	Sessions   int                  `json:"sessions"`
+	Users      int                  `json:"users"`
+	Topics     map[string]topicType `json:"topics,omitempty"`
+}
+
+// dirAPIKeyStat apikey连接数统计
+type dirAPIKeyStat struct {
+	UID   int64 `json:"uid"`
+	Count int64 `json:"count"`
+}
+
+// dirCommand 跨节点指令
+type dirCommand struct {
+	Cmd string `json:"cmd"`
+	UID int64  `json:"uid"`
+}
+
+type dirOp struct {
+	online bool
+	sess   *dirSession
+}
+
+var gSessionDir = &sessionDir{}
+
+// sessionDir 集群session目录,每个节点在用户上下线时更新,用于跨节点的admin操作和全局连接数限制
+// user:{uid}     session_id->dirSession
+// apikey:{key}   session_id->node
+// nodes          node->dirNode
+// stat:{node}    apikey->dirAPIKeyStat,节点上的apikey连接数
+// cmd:{node}     发送给节点的指令
+type sessionDir struct {
+	store    dirStore
+	node     string
+	opCh     chan *dirOp // 上线操作,队列满时丢弃,由心跳全量刷新补齐
+	running  atomic.Bool
+	quit     chan struct{}
+	offMux   sync.Mutex                          //
+	offlines []*dirSession                       // 下线操作,不能丢弃,否则会残留在目录中被计入连接数
+	offCh    chan struct{}                       // 有待处理的下线操作
+	alive    atomic.Pointer[map[string]struct{}] // 存活节点缓存,心跳时刷新,避免鉴权时查询nodes
+}
+
+// Start 开启session目录,使用remoting中的redis客户端(middleware.toml中的Redis配置),redis不可用则不开启
+func (d *sessionDir) Start() {
+	if !getAppConf().EnableSessionDirectory {
+		return
+	}
+
+	client := redis.NewClient()
+	if client == nil {
+		glog.Error(context.Background(), "session directory start fail, redis client not initialized")
+		WSErrorInc("session_dir", "invalid_config")
+		return
+	}
+
+	d.start(client, globalNodeID)
+}
+
+func (d *sessionDir) start(store dirStore, node string) {
+	if !d.running.CompareAndSwap(false, true) {
+		return
+	}
+
+	d.store = store
+	d.node = node
+	d.opCh = make(chan *dirOp, dirOpChannelSize)
+	d.offCh = make(chan struct{}, 1)
+	d.quit = make(chan struct{})
+	go d.loopOps()
+	go d.loopTick()
+	glog.Info(context.Background(), "session directory start", glog.String("node", node))
+}
+
+func (d *sessionDir) Stop() {
+	if !d.running.CompareAndSwap(true, false) {
+		return
+	}
+
+	close(d.quit)
+	ctx, cancel := context.WithTimeout(context.Background(), dirRedisTimeout)
+	defer cancel()
+	_, _ = d.store.HdelCtx(ctx, dirKeyNodes, d.node)
+	_, _ = d.store.DelCtx(ctx, dirStatKey(d.node))
+}
+
+func (d *sessionDir) Enabled() bool {
+	return d.running.Load()
+}
+
+// OnOnline 用户session上线,异步更新
+func (d *sessionDir) OnOnline(sess Session) {
+	d.dispatch(&dirOp{online: true, sess: d.toDirSession(sess)})
+}
+
+// OnOffline 用户session下线,异步更新,不会丢弃
+func (d *sessionDir) OnOffline(sess Session) {
+	ds := d.toDirSession(sess)
+	if !d.Enabled() || ds.UID <= 0 {
+		return
+	}
+
+	d.offMux.Lock()
+	d.offlines = append(d.offlines, ds)
+	d.offMux.Unlock()
+
+	select {
+	case d.offCh <- struct{}{}:
+	default:
+	}
+}
+
+func (d *sessionDir) dispatch(op *dirOp) {
+	if !d.Enabled() || op.sess.UID <= 0 {
+		return
+	}
+
+	select {
+	case d.opCh <- op:
+	default:
+		WSErrorInc("session_dir", "op_discard")
+	}
+}
+
+func (d *sessionDir) takeOfflines() []*dirSession {
+	d.offMux.Lock()
+	defer d.offMux.Unlock()
+	res := d.offlines
+	d.offlines = nil
+	return res
+}
+
+func (d *sessionDir) toDirSession(sess Session) *dirSession {
+	cli := sess.GetClient()
+	return &dirSession{
+		Node:      d.node,
+		SessionID: sess.ID(),
+		UID:       cli.GetMemberId(),
+		APIKey:    cli.GetAPIKey(),
+		IP:        cli.GetIP(),
+		Path:      cli.GetPath(),
+		Topics:    cli.GetTopics().Subscriptions(),
+		StartTime: sess.GetStartTime().Unix(),
+	}
+}
+
+func (d *sessionDir) loopOps() {
+	for {
+		select {
+		case op := <-d.opCh:
+			// 上线操作处理前session已经下线,不再写入
+			if op.online && gSessionMgr.GetSession(op.sess.SessionID) == nil {
+				continue
+			}
+			d.apply(op)
+		case <-d.offCh:
+			for _, sess := range d.takeOfflines() {
+				d.apply(&dirOp{online: false, sess: sess})
+			}
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+func (d *sessionDir) apply(op *dirOp) {
+	ctx, cancel := context.WithTimeout(context.Background(), dirRedisTimeout)
+	defer cancel()
+
+	var err error
+	if op.online {
+		err = d.put(ctx, op.sess)
+	} else {
+		err = d.del(ctx, op.sess)
+	}
+	if err != nil {
+		WSErrorInc("session_dir", "update_fail")
+		glog.Info(context.Background(), "session directory update fail", glog.String("sess_id", op.sess.SessionID), glog.NamedError("err", err))
+	}
+}
+
+func (d *sessionDir) put(ctx context.Context, sess *dirSession) error {
+	data, _ := json.Marshal(sess)
+	key := dirUserKey(sess.UID)
+	if err := d.store.HsetCtx(ctx, key, sess.SessionID, string(data)); err != nil {
+		return err
+	}
+	_ = d.store.ExpireCtx(ctx, key, int(dirNodeExpireTime/time.Second))
+
+	if sess.APIKey == "" {
+		return nil
+	}
+
+	key = dirAPIKeyKey(sess.APIKey)
+	if err := d.store.HsetCtx(ctx, key, sess.SessionID, sess.Node); err != nil {
+		return err
+	}
+	return d.store.ExpireCtx(ctx, key, int(dirNodeExpireTime/time.Second))
+}
+
+func (d *sessionDir) del(ctx context.Context, sess *dirSession) error {
+	if _, err := d.store.HdelCtx(ctx, dirUserKey(sess.UID), sess.SessionID); err != nil {
+		return err
+	}
+
+	if sess.APIKey != "" {
+		_, err := d.store.HdelCtx(ctx, dirAPIKeyKey(sess.APIKey), sess.SessionID)
+		return err
+	}
+	return nil
+}
+
+func (d *sessionDir) loopTick() {
+	heartbeat := time.NewTicker(dirHeartbeatTime)
+	poll := time.NewTicker(dirCommandPollTime)
+	defer heartbeat.Stop()
+	defer poll.Stop()
+
+	d.heartbeat()
+	for {
+		select {
+		case <-heartbeat.C:
+			d.heartbeat()
+		case <-poll.C:
+			d.pollCommands()
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// heartbeat 更新节点信息,并全量刷新本节点的session,防止异步更新丢失或者过期
+func (d *sessionDir) heartbeat() {
+	users := GetUserMgr().GetAllUsers()
+	count := d.refresh(users)
+
+	node := &dirNode{
+		Node:       d.node,
+		UpdateTime: time.Now().Unix(),
+		Sessions:   count,
+		Users:      len(users),
+		Topics:     getConfigMgr().GetAllTopics(),
+	}
+	data, _ := json.Marshal(node)
+
+	ctx, cancel := context.WithTimeout(context.Background(), dirRedisTimeout)
+	defer cancel()
+	if err := d.store.HsetCtx(ctx, dirKeyNodes, d.node, string(data)); err != nil {
+		WSErrorInc("session_dir", "heartbeat_fail")
+		glog.Info(ctx, "session directory heartbeat fail", glog.NamedError("err", err))
+		return
+	}
+
+	// 刷新存活节点缓存
+	if _, err := d.Nodes(ctx); err != nil {
+		WSErrorInc("session_dir", "load_nodes_fail")
+	}
+
+	// apikey统计全量覆盖
+	stats := localAPIKeyStats()
+	values := make(map[string]string, len(stats))
+	for k, v := range stats {
+		data, _ := json.Marshal(v)
+		values[k] = string(data)
+	}
+	key := dirStatKey(d.node)
+	_, _ = d.store.DelCtx(ctx, key)
+	if len(values) == 0 {
+		return
+	}
+	if err := d.store.HmsetCtx(ctx, key, values); err != nil {
+		WSErrorInc("session_dir", "heartbeat_fail")
+		return
+	}
+	_ = d.store.ExpireCtx(ctx, key, int(dirNodeExpireTime/time.Second))
+}
+
+// refresh 按key批量写入本节点的session,不经过opCh,避免挤占上下线操作,返回session数
+func (d *sessionDir) refresh(users []User) int {
+	count := 0
+	expire := int(dirNodeExpireTime / time.Second)
+	apiKeys := make(map[string]map[string]string)
+	for _, u := range users {
+		values := make(map[string]string)
+		for _, s := range u.GetSessions() {
+			if gSessionMgr.GetSession(s.ID()) == nil {
+				continue
+			}
+			ds := d.toDirSession(s)
+			data, _ := json.Marshal(ds)
+			values[ds.SessionID] = string(data)
+			if ds.APIKey != "" {
+				if apiKeys[ds.APIKey] == nil {
+					apiKeys[ds.APIKey] = make(map[string]string)
+				}
+				apiKeys[ds.APIKey][ds.SessionID] = d.node
+			}
+		}
+		count += len(values)
+		if len(values) == 0 {
+			continue
+		}
+		d.hmset(dirUserKey(u.GetMemberID()), values, expire)
+	}
+
+	for apiKey, values := range apiKeys {
+		d.hmset(dirAPIKeyKey(apiKey), values, expire)
+	}
+	return count
+}
+
+func (d *sessionDir) hmset(key string, values map[string]string, expire int) {
+	ctx, cancel := context.WithTimeout(context.Background(), dirRedisTimeout)
+	defer cancel()
+	if err := d.store.HmsetCtx(ctx, key, values); err != nil {
+		WSErrorInc("session_dir", "refresh_fail")
+		return
+	}
+	_ = d.store.ExpireCtx(ctx, key, expire)
+}
+
+// localAPIKeyStats 本节点apikey连接数
+func localAPIKeyStats() map[string]*dirAPIKeyStat {
+	res := make(map[string]*dirAPIKeyStat)
+	for _, u := range GetUserMgr().GetAllUsers() {
+		for _, s := range u.GetSessions() {
+			cli := s.GetClient()
+			if cli == nil || cli.GetAPIKey() == "" {
+				continue
+			}
+			st, ok := res[cli.GetAPIKey()]
+			if !ok {
+				st = &dirAPIKeyStat{UID: u.GetMemberID()}
+				res[cli.GetAPIKey()] = st
+			}
+			st.Count++
+		}
+	}
+	return res
+}
+
+// pollCommands 拉取并执行其他节点发送的指令
+func (d *sessionDir) pollCommands() {
+	ctx, cancel := context.WithTimeout(context.Background(), dirRedisTimeout)
+	defer cancel()
+
+	for {
+		data, err := d.store.LpopCtx(ctx, dirCommandKey(d.node))
+		if err != nil || data == "" {
+			return
+		}
+
+		cmd := &dirCommand{}
+		if err := json.Unmarshal([]byte(data), cmd); err != nil {
+			WSErrorInc("session_dir", "invalid_cmd")
+			continue
+		}
+
+		switch cmd.Cmd {
+		case dirCommandKick:
+			n := kickLocalUser(cmd.UID)
+			glog.Info(ctx, "session directory kick user", glog.Int64("uid", cmd.UID), glog.Int("size", n))
+		default:
+			WSErrorInc("session_dir", "unknown_cmd")
+		}
+	}
+}
+
+// Nodes 存活的节点
+func (d *sessionDir) Nodes(ctx context.Context) ([]*dirNode, error) {
+	if !d.Enabled() {
+		return nil, errSessionDirDisabled
+	}
+
+	values, err := d.store.HgetallCtx(ctx, dirKeyNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	res := make([]*dirNode, 0, len(values))
+	for k, v := range values {
+		node := &dirNode{}
+		if err := json.Unmarshal([]byte(v), node); err != nil || now-node.UpdateTime > int64(dirNodeExpireTime/time.Second) {
+			// 节点已经下线
+			_, _ = d.store.HdelCtx(ctx, dirKeyNodes, k)
+			continue
+		}
+		res = append(res, node)
+	}
+
+	alive := make(map[string]struct{}, len(res))
+	for _, n := range res {
+		alive[n.Node] = struct{}{}
+	}
+	d.alive.Store(&alive)
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Node < res[j].Node })
+	return res, nil
+}
+
+func (d *sessionDir) aliveNodes(ctx context.Context) (map[string]struct{}, error) {
+	nodes, err := d.Nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]struct{}, len(nodes))
+	for _, n := range nodes {
+		res[n.Node] = struct{}{}
+	}
+	return res, nil
+}
+
+// UserSessions 用户在所有节点上的session,已下线节点的session会被清理
+func (d *sessionDir) UserSessions(ctx context.Context, uid int64) ([]*dirSession, error) {
+	alive, err := d.aliveNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := dirUserKey(uid)
+	values, err := d.store.HgetallCtx(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*dirSession, 0, len(values))
+	for id, v := range values {
+		sess := &dirSession{}
+		if err := json.Unmarshal([]byte(v), sess); err != nil {
+			continue
+		}
+		if _, ok := alive[sess.Node]; !ok {
+			_, _ = d.store.HdelCtx(ctx, key, id)
+			continue
+		}
+		res = append(res, sess)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].SessionID < res[j].SessionID })
+	return res, nil
+}
+
+// CountByAPIKey apikey在所有节点上的连接数,在鉴权流程中调用,只查询一次redis,存活节点使用心跳时的缓存
+func (d *sessionDir) CountByAPIKey(ctx context.Context, apiKey string) (int, error) {
+	if !d.Enabled() {
+		return 0, errSessionDirDisabled
+	}
+
+	alive := d.alive.Load()
+	if alive == nil {
+		return 0, errSessionDirNoNodes
+	}
+
+	key := dirAPIKeyKey(apiKey)
+	values, err := d.store.HgetallCtx(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var stales []string
+	for id, node := range values {
+		if _, ok := (*alive)[node]; !ok {
+			stales = append(stales, id)
+			continue
+		}
+		count++
+	}
+
+	// 已下线节点的session异步清理,不占用鉴权时间
+	if len(stales) > 0 {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), dirRedisTimeout)
+			defer cancel()
+			_, _ = d.store.HdelCtx(ctx, key, stales...)
+		}()
+	}
+	return count, nil
+}
+
+// APIKeyStats 所有节点的apikey连接数
+func (d *sessionDir) APIKeyStats(ctx context.Context) (map[string]*dirAPIKeyStat, error) {
+	nodes, err := d.Nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]*dirAPIKeyStat)
+	for _, n := range nodes {
+		values, err := d.store.HgetallCtx(ctx, dirStatKey(n.Node))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range values {
+			st := &dirAPIKeyStat{}
+			if err := json.Unmarshal([]byte(v), st); err != nil {
+				continue
+			}
+			if old, ok := res[k]; ok {
+				old.Count += st.Count
+			} else {
+				res[k] = st
+			}
+		}
+	}
+	return res, nil
+}
+
+// Topics 所有节点的topic
+func (d *sessionDir) Topics(ctx context.Context) (map[string]topicType, error) {
+	nodes, err := d.Nodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]topicType)
+	for _, n := range nodes {
+		for k, v := range n.Topics {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+// Kick 踢出用户在所有节点上的连接,返回每个节点的连接数,其他节点异步执行
+func (d *sessionDir) Kick(ctx context.Context, uid int64) (map[string]int, error) {
+	sessions, err := d.UserSessions(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]int)
+	for _, s := range sessions {
+		res[s.Node]++
+	}
+
+	data, _ := json.Marshal(&dirCommand{Cmd: dirCommandKick, UID: uid})
+	for node := range res {
+		if node == d.node {
+			res[node] = kickLocalUser(uid)
+			continue
+		}
+
+		key := dirCommandKey(node)
+		if _, err := d.store.LpushCtx(ctx, key, string(data)); err != nil {
+			return res, fmt.Errorf("send kick to %v fail, %w", node, err)
+		}
+		_ = d.store.ExpireCtx(ctx, key, dirCommandKeyExpire)
+	}
+
+	return res, nil
+}
+
+// kickLocalUser 踢出本节点上的用户连接
+func kickLocalUser(uid int64) int {
+	user := GetUserMgr().GetUser(uid)
+	if user == nil {
+		return 0
+	}
+
+	sessions := user.GetSessions()
+	for _, sess := range sessions {
+		sess.Stop()
+	}
+	return len(sessions)
+}
+
+func dirUserKey(uid int64) string {
+	return dirKeyPrefix + "user:" + strconv.FormatInt(uid, 10)
+}
+
+func dirAPIKeyKey(apiKey string) string {
+	return dirKeyPrefix + "apikey:" + apiKey
+}
+
+func dirStatKey(node string) string {
+	return dirKeyPrefix + "stat:" + node
+}
+
+func dirCommandKey(node string) string {
+	return dirKeyPrefix + "cmd:" + node
+}
@@ -0,0 +1,185 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDirStore struct {
+	mux    sync.Mutex
+	hashes map[string]map[string]string
+	lists  map[string][]string
+}
+
+func newFakeDirStore() *fakeDirStore {
+	return &fakeDirStore{hashes: make(map[string]map[string]string), lists: make(map[string][]string)}
+}
+
+func (f *fakeDirStore) HsetCtx(ctx context.Context, key, field, value string) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.hashes[key] == nil {
+		f.hashes[key] = make(map[string]string)
+	}
+	f.hashes[key][field] = value
+	return nil
+}
+
+func (f *fakeDirStore) HdelCtx(ctx context.Context, key string, fields ...string) (bool, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for _, field := range fields {
+		delete(f.hashes[key], field)
+	}
+	return true, nil
+}
+
+func (f *fakeDirStore) HgetallCtx(ctx context.Context, key string) (map[string]string, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	res := make(map[string]string)
+	for k, v := range f.hashes[key] {
+		res[k] = v
+	}
+	return res, nil
+}
+
+func (f *fakeDirStore) HmsetCtx(ctx context.Context, key string, fieldsAndValues map[string]string) error {
+	for k, v := range fieldsAndValues {
+		_ = f.HsetCtx(ctx, key, k, v)
+	}
+	return nil
+}
+
+func (f *fakeDirStore) ExpireCtx(ctx context.Context, key string, seconds int) error {
+	return nil
+}
+
+func (f *fakeDirStore) DelCtx(ctx context.Context, keys ...string) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for _, k := range keys {
+		delete(f.hashes, k)
+		delete(f.lists, k)
+	}
+	return len(keys), nil
+}
+
+func (f *fakeDirStore) LpushCtx(ctx context.Context, key string, values ...any) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for _, v := range values {
+		f.lists[key] = append([]string{v.(string)}, f.lists[key]...)
+	}
+	return len(f.lists[key]), nil
+}
+
+func (f *fakeDirStore) LpopCtx(ctx context.Context, key string) (string, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	l := f.lists[key]
+	if len(l) == 0 {
+		return "", nil
+	}
+	f.lists[key] = l[1:]
+	return l[0], nil
+}
+
+func TestSessionDir(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeDirStore()
+	d := &sessionDir{store: store, node: "node1"}
+	d.running.Store(true)
+
+	setNode := func(node string, updateTime time.Time) {
+		data, _ := json.Marshal(&dirNode{Node: node, UpdateTime: updateTime.Unix(), Topics: map[string]topicType{node + ".topic": topicTypePrivate}})
+		_ = store.HsetCtx(ctx, dirKeyNodes, node, string(data))
+	}
+	setNode("node1", time.Now())
+	setNode("node2", time.Now())
+	setNode("node3", time.Now().Add(-dirNodeExpireTime*2))
+
+	_ = d.put(ctx, &dirSession{Node: "node1", SessionID: "s1", UID: 100, APIKey: "key"})
+	_ = d.put(ctx, &dirSession{Node: "node2", SessionID: "s2", UID: 100, APIKey: "key"})
+	_ = d.put(ctx, &dirSession{Node: "node3", SessionID: "s3", UID: 100, APIKey: "key"})
+
+	t.Run("nodes", func(t *testing.T) {
+		_, err := d.CountByAPIKey(ctx, "key")
+		assert.Equal(t, errSessionDirNoNodes, err)
+
+		nodes, err := d.Nodes(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(nodes))
+		assert.Equal(t, "node1", nodes[0].Node)
+
+		topics, err := d.Topics(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(topics))
+	})
+
+	t.Run("user sessions", func(t *testing.T) {
+		sessions, err := d.UserSessions(ctx, 100)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(sessions))
+		assert.Equal(t, "s1", sessions[0].SessionID)
+		assert.Equal(t, "s2", sessions[1].SessionID)
+
+		count, err := d.CountByAPIKey(ctx, "key")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, count)
+
+		_ = d.del(ctx, &dirSession{Node: "node2", SessionID: "s2", UID: 100, APIKey: "key"})
+		count, _ = d.CountByAPIKey(ctx, "key")
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("kick", func(t *testing.T) {
+		_ = d.put(ctx, &dirSession{Node: "node2", SessionID: "s4", UID: 200})
+		nodes, err := d.Kick(ctx, 200)
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int{"node2": 1}, nodes)
+
+		data, _ := store.LpopCtx(ctx, dirCommandKey("node2"))
+		cmd := &dirCommand{}
+		assert.Nil(t, json.Unmarshal([]byte(data), cmd))
+		assert.Equal(t, dirCommandKick, cmd.Cmd)
+		assert.Equal(t, int64(200), cmd.UID)
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		s1, s2 := newMockSession(300), newMockSession(300)
+		u := newUser(300)
+		_ = u.Add(s1)
+		_ = u.Add(s2)
+		// s2 is offline
+		assert.Nil(t, gSessionMgr.AddSession(s1))
+		defer gSessionMgr.DelSession(s1.ID())
+
+		assert.Equal(t, 1, d.refresh([]User{u}))
+		sessions, err := d.UserSessions(ctx, 300)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(sessions))
+		assert.Equal(t, s1.ID(), sessions[0].SessionID)
+	})
+
+	t.Run("offline is not dropped", func(t *testing.T) {
+		d := &sessionDir{store: store, node: "node1", opCh: make(chan *dirOp), offCh: make(chan struct{}, 1)}
+		d.running.Store(true)
+		d.OnOnline(newMockSession(400))
+		d.OnOffline(newMockSession(400))
+		d.OnOffline(newMockSession(400))
+		assert.Equal(t, 2, len(d.takeOfflines()))
+		assert.Equal(t, 1, len(d.offCh))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		d := &sessionDir{}
+		_, err := d.CountByAPIKey(ctx, "key")
+		assert.Equal(t, errSessionDirDisabled, err)
+	})
+}
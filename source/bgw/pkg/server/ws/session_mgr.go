@@ -112,6 +112,31 @@ func (m *sessionMgr) doAddSession(s Session) error {
 	}
 
 	ip := s.GetClient().GetIP()
+	if err := m.checkIP(sconf, ip); err != nil {
+		return err
+	}
+
+	m.ips[ip]++
+	m.sessions[s.ID()] = s
+	WSGauge(float64(len(m.sessions)), "session_manager", "sessions")
+	glog.Info(
+		context.Background(),
+		"add new session",
+		glog.String("clientInfo", s.GetClient().String()),
+		glog.String("sessionID", s.ID()),
+		glog.String("protocol", s.ProtocolVersion().String()),
+	)
+	return nil
+}
+
+// CheckIP 建立连接前校验ip是否超过配额,避免无效的upgrade
+func (m *sessionMgr) CheckIP(ip string) error {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	return m.checkIP(getDynamicConf(), ip)
+}
+
+func (m *sessionMgr) checkIP(sconf *dynamicConf, ip string) error {
 	if _, ok := sconf.IpBlackList[ip]; ok {
 		WSCounterInc("session_manager", "blacklist_limit")
 		glog.Info(context.Background(), "session ip in blacklist", glog.String("ip", ip))
@@ -126,16 +151,6 @@ func (m *sessionMgr) doAddSession(s Session) error {
 		}
 	}
 
-	m.ips[ip]++
-	m.sessions[s.ID()] = s
-	WSGauge(float64(len(m.sessions)), "session_manager", "sessions")
-	glog.Info(
-		context.Background(),
-		"add new session",
-		glog.String("clientInfo", s.GetClient().String()),
-		glog.String("sessionID", s.ID()),
-		glog.String("protocol", s.ProtocolVersion().String()),
-	)
 	return nil
 }
 
@@ -168,6 +183,7 @@ func (m *sessionMgr) DelSession(sessId string) {
 		glog.String("sess_id", s.ID()),
 		glog.Int64("sess_write_count", status.WriteCount),
 		glog.Int64("sess_drop_count", status.DropCount),
+		glog.Int64("sess_max_queue_size", status.MaxQueueSize),
 		glog.String("sess_duration", time.Since(s.GetStartTime()).String()),
 		glog.String("ip", ip),
 		glog.Int("ip_count", ipCount),
@@ -175,6 +191,7 @@ func (m *sessionMgr) DelSession(sessId string) {
 
 	// 从对应user中删除session
 	uid = s.GetClient().GetMemberId()
+	gSessionDir.OnOffline(s)
 	user := GetUserMgr().Unbind(uid, sessId)
 	if user != nil {
 		action := newAction(ActionSessionOffline, uid, sessId, nil)
@@ -39,6 +39,10 @@ func (s *MockSession) Allow() bool {
 	return true
 }
 
+func (s *MockSession) AllowSubscribe() bool {
+	return true
+}
+
 func (s *MockSession) IsAuthed() bool {
 	return s.client.GetMemberId() > 0
 }
@@ -60,6 +64,9 @@ func (s *MockSession) GetStatus() SessionStatus {
 	return SessionStatus{}
 }
 
+func (s *MockSession) OnPong(reqID string) {
+}
+
 func (s *MockSession) GetStartTime() time.Time {
 	return s.startTime
 }
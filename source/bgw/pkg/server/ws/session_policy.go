@@ -0,0 +1,189 @@
+package ws
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"github.com/fasthttp/websocket"
+)
+
+// slowConsumerPolicy 发送队列满时的处理策略
+type slowConsumerPolicy string
+
+const (
+	policyDropNewest   slowConsumerPolicy = ""              // 默认,丢弃新消息
+	policyDisconnect   slowConsumerPolicy = "disconnect"    // 断开连接
+	policyDropOldest   slowConsumerPolicy = "drop_oldest"   // 丢弃最早的消息
+	policyConflate     slowConsumerPolicy = "conflate"      // 按topic合并,只保留最新的消息
+	policySnapshotOnly slowConsumerPolicy = "snapshot_only" // 公有增量topic降级为只推送全量数据
+)
+
+// closeCodeTryAgainLater websocket close code 1013, 通知客户端因为消费过慢被断开
+const closeCodeTryAgainLater = 1013
+
+// SlowConsumerConf 慢消费者策略,按连接路径配置
+// nolint
+type SlowConsumerConf struct {
+	Route  string `json:"route"`                                                                             // 连接路径,同routes中的路径
+	Policy string `json:"policy,default=disconnect,options=[disconnect,drop_oldest,conflate,snapshot_only]"` // 处理策略
+}
+
+// getSlowConsumerPolicy 根据连接路径获取策略,未配置则丢弃新消息
+func getSlowConsumerPolicy(path string) slowConsumerPolicy {
+	conf := getStaticConf()
+	if conf == nil || path == "" {
+		return policyDropNewest
+	}
+
+	for _, c := range conf.WS.SlowConsumers {
+		if p, _ := parsePathAndVersion(c.Route); p == path {
+			return slowConsumerPolicy(c.Policy)
+		}
+	}
+
+	return policyDropNewest
+}
+
+// onQueueFull 发送队列已满,根据策略处理,有消息丢失时返回errSessionWriteChannelDiscard
+func (s *session) onQueueFull(msg *Message) error {
+	atomic.AddInt64(&s.dropCount, 1)
+
+	switch s.policy {
+	case policyDisconnect:
+		// 调用方是广播协程,关闭帧可能阻塞1s,异步发送
+		if atomic.CompareAndSwapInt32(&s.kicked, 0, 1) {
+			WSCounterInc("slow_consumer", string(s.policy))
+			glog.Info(context.Background(), "kick slow consumer", glog.String("sess_id", s.id), glog.Int64("uid", s.client.GetMemberId()), glog.String("client", s.client.String()))
+			go s.kickSlowConsumer()
+		}
+	case policyDropOldest:
+		WSCounterInc("slow_consumer", string(s.policy))
+		select {
+		case <-s.sendCh:
+		default:
+		}
+		select {
+		case s.sendCh <- msg:
+		default:
+		}
+	case policyConflate:
+		if msg.Topic == "" {
+			WSErrorInc("session", "write_discard")
+			break
+		}
+		WSCounterInc("slow_consumer", string(s.policy))
+		s.conflate(msg)
+	case policySnapshotOnly:
+		if atomic.CompareAndSwapInt32(&s.snapshotOnly, 0, 1) {
+			WSCounterInc("slow_consumer", string(s.policy))
+			glog.Info(context.Background(), "slow consumer downgrade to snapshot only", glog.String("sess_id", s.id), glog.Int64("uid", s.client.GetMemberId()))
+		}
+	default:
+		WSErrorInc("session", "write_discard")
+	}
+
+	return errSessionWriteChannelDiscard
+}
+
+// kickSlowConsumer 发送关闭帧并断开连接
+func (s *session) kickSlowConsumer() {
+	if s.conn != nil {
+		_ = s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCodeTryAgainLater, "slow consumer"), time.Now().Add(time.Second))
+	}
+	s.Stop()
+}
+
+// restoreDelta 发送队列已清空,恢复推送增量数据,下次广播增量时会先重发全量数据
+func (s *session) restoreDelta() {
+	if atomic.LoadInt32(&s.snapshotOnly) == 1 && atomic.CompareAndSwapInt32(&s.snapshotOnly, 1, 0) {
+		WSCounterInc("slow_consumer", "restore_delta")
+		glog.Info(context.Background(), "slow consumer restore delta", glog.String("sess_id", s.id), glog.Int64("uid", s.client.GetMemberId()))
+	}
+}
+
+// conflate 缓存消息,同一个topic只保留最新的一条,等发送队列空闲时发送
+func (s *session) conflate(msg *Message) {
+	s.conflateMux.Lock()
+	if s.conflated == nil {
+		s.conflated = make(map[string]*Message)
+	}
+	if _, ok := s.conflated[msg.Topic]; !ok {
+		s.conflatedTopics = append(s.conflatedTopics, msg.Topic)
+	}
+	s.conflated[msg.Topic] = msg
+	atomic.StoreInt32(&s.conflatedSize, int32(len(s.conflatedTopics)))
+	s.conflateMux.Unlock()
+
+	select {
+	case s.conflateCh <- struct{}{}:
+	default:
+	}
+}
+
+// tryConflate 如果topic已经有合并中的消息,则直接合并,避免新消息先于旧消息发送
+func (s *session) tryConflate(msg *Message) bool {
+	if msg.Topic == "" || atomic.LoadInt32(&s.conflatedSize) == 0 {
+		return false
+	}
+
+	s.conflateMux.Lock()
+	_, ok := s.conflated[msg.Topic]
+	if ok {
+		s.conflated[msg.Topic] = msg
+	}
+	s.conflateMux.Unlock()
+	if ok {
+		atomic.AddInt64(&s.dropCount, 1)
+	}
+	return ok
+}
+
+// dropConflated 丢弃topic合并中的消息,重发全量数据前调用,避免只发送合并后的增量数据
+func (s *session) dropConflated(topic string) {
+	if atomic.LoadInt32(&s.conflatedSize) == 0 {
+		return
+	}
+
+	s.conflateMux.Lock()
+	if _, ok := s.conflated[topic]; ok {
+		delete(s.conflated, topic)
+		for i, t := range s.conflatedTopics {
+			if t == topic {
+				s.conflatedTopics = append(s.conflatedTopics[:i], s.conflatedTopics[i+1:]...)
+				break
+			}
+		}
+		atomic.StoreInt32(&s.conflatedSize, int32(len(s.conflatedTopics)))
+	}
+	s.conflateMux.Unlock()
+}
+
+// flushConflated 发送合并后的消息,需要在发送队列为空时调用
+func (s *session) flushConflated() error {
+	if atomic.LoadInt32(&s.conflatedSize) == 0 {
+		return nil
+	}
+
+	s.conflateMux.Lock()
+	topics, conflated := s.conflatedTopics, s.conflated
+	s.conflatedTopics, s.conflated = nil, nil
+	atomic.StoreInt32(&s.conflatedSize, 0)
+	s.conflateMux.Unlock()
+
+	for _, topic := range topics {
+		if err := s.write(conflated[topic]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateQueueSize 记录发送队列最大深度
+func (s *session) updateQueueSize() {
+	size := int64(len(s.sendCh))
+	if size > atomic.LoadInt64(&s.maxQueueSize) {
+		atomic.StoreInt64(&s.maxQueueSize, size)
+	}
+}
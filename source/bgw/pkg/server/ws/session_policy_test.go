@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPolicySession(policy slowConsumerPolicy) *session {
+	s := newSession(nil, NewClient(&ClientConfig{}), version2)
+	s.running = 1
+	s.policy = policy
+	s.sendCh = make(chan *Message, 1)
+	s.conflateCh = make(chan struct{}, 1)
+	return s
+}
+
+func TestSlowConsumerPolicy(t *testing.T) {
+	glog.SetLevel(glog.FatalLevel)
+
+	t.Run("drop_newest", func(t *testing.T) {
+		s := newPolicySession(policyDropNewest)
+		assert.NoError(t, s.Write(&Message{Type: MsgTypePush, Data: []byte("1")}))
+		assert.Equal(t, errSessionWriteChannelDiscard, s.Write(&Message{Type: MsgTypePush, Data: []byte("2")}))
+		assert.Equal(t, "1", string((<-s.sendCh).Data))
+		assert.Equal(t, SessionStatus{DropCount: 1, MaxIdleTime: sessionMaxIdleTime, MaxQueueSize: 1}, s.GetStatus())
+	})
+
+	t.Run("drop_oldest", func(t *testing.T) {
+		s := newPolicySession(policyDropOldest)
+		assert.NoError(t, s.Write(&Message{Type: MsgTypePush, Data: []byte("1")}))
+		assert.Equal(t, errSessionWriteChannelDiscard, s.Write(&Message{Type: MsgTypePush, Data: []byte("2")}))
+		assert.Equal(t, "2", string((<-s.sendCh).Data))
+	})
+
+	t.Run("conflate", func(t *testing.T) {
+		s := newPolicySession(policyConflate)
+		assert.NoError(t, s.Write(&Message{Type: MsgTypePush, Topic: "a", Data: []byte("1")}))
+		assert.Error(t, s.Write(&Message{Type: MsgTypePush, Topic: "b", Data: []byte("2")}))
+		assert.Error(t, s.Write(&Message{Type: MsgTypePush, Topic: "c", Data: []byte("3")}))
+		<-s.sendCh
+		// b is pending, newer message of b is conflated even if queue is not full
+		assert.NoError(t, s.Write(&Message{Type: MsgTypePush, Topic: "b", Data: []byte("4")}))
+		assert.Equal(t, 0, len(s.sendCh))
+		assert.Equal(t, []string{"b", "c"}, s.conflatedTopics)
+		assert.Equal(t, "4", string(s.conflated["b"].Data))
+		assert.Equal(t, int64(3), s.GetStatus().DropCount)
+
+		// snapshot of b is resent, conflated message of b is dropped
+		s.dropConflated("b")
+		assert.Equal(t, []string{"c"}, s.conflatedTopics)
+		assert.Nil(t, s.conflated["b"])
+		assert.Equal(t, int32(1), s.conflatedSize)
+	})
+
+	t.Run("snapshot_only", func(t *testing.T) {
+		s := newPolicySession(policySnapshotOnly)
+		assert.NoError(t, s.Write(&Message{Type: MsgTypePush}))
+		assert.False(t, s.GetStatus().SnapshotOnly)
+		assert.Error(t, s.Write(&Message{Type: MsgTypePush}))
+		assert.True(t, s.GetStatus().SnapshotOnly)
+
+		// queue drained
+		<-s.sendCh
+		s.restoreDelta()
+		assert.False(t, s.GetStatus().SnapshotOnly)
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		s := newPolicySession(policyDisconnect)
+		assert.NoError(t, s.Write(&Message{Type: MsgTypePush}))
+		assert.Error(t, s.Write(&Message{Type: MsgTypePush}))
+		assert.Error(t, s.Write(&Message{Type: MsgTypePush}))
+		assert.Eventually(t, func() bool { return !s.IsRunning() }, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestGetSlowConsumerPolicy(t *testing.T) {
+	conf := getStaticConf()
+	old := conf.WS.SlowConsumers
+	conf.WS.SlowConsumers = []SlowConsumerConf{{Route: "v5:/v5/public/linear", Policy: "snapshot_only"}}
+	defer func() { conf.WS.SlowConsumers = old }()
+
+	assert.Equal(t, policySnapshotOnly, getSlowConsumerPolicy("/v5/public/linear"))
+	assert.Equal(t, policyDropNewest, getSlowConsumerPolicy("/v5/private"))
+}
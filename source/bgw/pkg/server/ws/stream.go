@@ -0,0 +1,133 @@
+package ws
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"code.bydev.io/fbu/gateway/gway.git/generic"
+	"code.bydev.io/fbu/gateway/gway.git/glog"
+	"github.com/fasthttp/websocket"
+
+	"bgw/pkg/common/bhttp"
+	"bgw/pkg/common/constant"
+	"bgw/pkg/common/types"
+	"bgw/pkg/common/util"
+	"bgw/pkg/server/core"
+	"bgw/pkg/server/filter"
+	"bgw/pkg/server/metadata"
+)
+
+// stream bridge websocket to grpc bidi stream of the route with the same path,
+// route filters (auth, limiter...) run once on handshake, then each text frame is a request message
+// and each upstream message is sent back as a text frame. Closing the websocket cancels the stream.
+func (s *wsServer) stream() RequestHandler {
+	return func(ctx *types.Ctx) {
+		if s.srv == nil || s.controller == nil {
+			ctx.Error("stream not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		md := metadata.MDFromContext(ctx)
+		defer metadata.Release(md)
+		md.WssFlag = true
+
+		var upgraded bool
+		var handler core.BidiHandler = func(stream core.BidiStream) error {
+			path := string(ctx.Path())
+			ip := bhttp.GetRemoteIP(ctx)
+			err := s.upgrader.Upgrade(ctx, func(conn *WSConn) {
+				glog.Info(ctx, "new stream", glog.String("ip", ip), glog.String("path", path))
+				WSCounterInc("ws_stream", "open")
+				pumpStream(conn, stream)
+			})
+			upgraded = err == nil
+			return err
+		}
+		ctx.SetUserValue(constant.CtxBidiHandler, handler)
+
+		chain, err := filter.GlobalChain().AppendNames(filter.IPRateLimitFilterKey)
+		if err != nil {
+			ctx.Error(errServiceNotAvailable.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		err = chain.Finally(tradeHandle)(ctx)
+		if err == nil || upgraded {
+			return
+		}
+
+		WSCounterInc("ws_stream", "open_fail")
+		glog.Info(ctx, "ws_stream open fail",
+			glog.String("err", err.Error()),
+			glog.String("ip", bhttp.GetRemoteIP(ctx)),
+			glog.String("path", string(ctx.Path())),
+		)
+		if len(ctx.Response.Body()) == 0 {
+			ctx.Error(err.Error(), http.StatusBadRequest)
+		}
+	}
+}
+
+// pumpStream forward frames between websocket and upstream until either side finished
+func pumpStream(conn *WSConn, stream core.BidiStream) {
+	var mu sync.Mutex
+	write := func(mt int, data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return conn.WriteMessage(mt, data)
+	}
+
+	done := make(chan struct{})
+	// upstream -> client
+	go func() {
+		defer close(done)
+		defer func() { _ = conn.Close() }()
+
+		buf := &bytes.Buffer{}
+		for {
+			buf.Reset()
+			err := stream.Recv(buf)
+			if err == io.EOF {
+				_ = write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err != nil {
+				WSCounterInc("ws_stream", "upstream_error")
+				_ = write(websocket.TextMessage, []byte(util.ToJSONString(core.NewStreamError(err))))
+				_ = write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, ""))
+				return
+			}
+			if err = write(websocket.TextMessage, buf.Bytes()); err != nil {
+				return
+			}
+		}
+	}()
+
+	// client -> upstream
+	for {
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if mt != websocket.TextMessage && mt != websocket.BinaryMessage {
+			continue
+		}
+		err = stream.Send(bytes.NewReader(data))
+		if errors.Is(err, generic.ErrReqUnmarshalFailed) {
+			// invalid message is dropped, stream is kept
+			_ = write(websocket.TextMessage, []byte(util.ToJSONString(core.NewStreamError(err))))
+			continue
+		}
+		if err != nil {
+			// stream is finished, the reason is sent by Recv
+			break
+		}
+	}
+
+	// cancel the stream so that Recv returns, then wait for writer
+	stream.Close()
+	<-done
+	WSCounterInc("ws_stream", "close")
+}
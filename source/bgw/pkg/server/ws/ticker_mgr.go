@@ -61,6 +61,7 @@ func (tm *tickerMgr) loopUserTick() {
 		}
 
 		tm.updateUsages()
+		gUserMgr.gcResumes()
 		tm.checkSyncUsers()
 		tm.checkSyncAcceptors()
 		tm.checkSyncRegular()
@@ -174,8 +175,16 @@ func (tm *tickerMgr) loopSessionsTick() {
 		}
 
 		sessions := GetSessionMgr().GetSessions()
+		total, maxSize := 0, 0
 		for _, sess := range sessions {
 			sess.OnTick()
+			size := sess.GetStatus().QueueSize
+			total += size
+			if size > maxSize {
+				maxSize = size
+			}
 		}
+		WSGauge(float64(total), "session_queue", "total")
+		WSGauge(float64(maxSize), "session_queue", "max")
 	}
 }
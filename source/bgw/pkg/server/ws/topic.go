@@ -2,11 +2,12 @@ package ws
 
 // Topics is topics collection
 type Topics struct {
-	items map[string]struct{}
+	items   map[string]struct{}                // base topic
+	filters map[string]map[string]*topicFilter // base topic -> subscription -> filter, filter is nil if not filtered
 }
 
 func newTopics() Topics {
-	return Topics{items: make(map[string]struct{})}
+	return Topics{items: make(map[string]struct{}), filters: make(map[string]map[string]*topicFilter)}
 }
 
 // Size get topics size
@@ -14,7 +15,7 @@ func (t Topics) Size() int {
 	return len(t.items)
 }
 
-// Values is topics collection
+// Values is topics collection, filter params are not included
 func (t Topics) Values() []string {
 	ss := make([]string, 0, t.Size())
 	for k := range t.items {
@@ -24,31 +25,74 @@ func (t Topics) Values() []string {
 	return ss
 }
 
+// Subscriptions is topics with filter params, used to restore subscriptions
+func (t Topics) Subscriptions() []string {
+	ss := make([]string, 0, t.Size())
+	for _, subs := range t.filters {
+		for k := range subs {
+			ss = append(ss, k)
+		}
+	}
+
+	return ss
+}
+
 // Clear clear all topics
 func (t *Topics) Clear() {
 	t.items = make(map[string]struct{})
+	t.filters = make(map[string]map[string]*topicFilter)
 }
 
-// Add add topic
+// Add add topic, topic may have filter params like order?symbol=BTCUSDT,
+// the result is new base topics
 func (t *Topics) Add(items ...string) []string {
 	res := make([]string, 0, len(items))
 	for _, s := range items {
-		if _, ok := t.items[s]; !ok {
-			t.items[s] = struct{}{}
-			res = append(res, s)
+		sub, filter, err := parseTopicFilter(s)
+		if err != nil {
+			continue
+		}
+
+		base := topicBase(sub)
+		subs, ok := t.filters[base]
+		if !ok {
+			subs = make(map[string]*topicFilter)
+			t.filters[base] = subs
+		}
+		subs[sub] = filter
+
+		if _, ok := t.items[base]; !ok {
+			t.items[base] = struct{}{}
+			res = append(res, base)
 		}
 	}
 
 	return res
 }
 
-// Remove remove topic
+// Remove remove topic, the result is removed base topics which have no subscriptions
 func (t *Topics) Remove(items ...string) []string {
 	res := make([]string, 0, len(items))
 	for _, s := range items {
-		if _, ok := t.items[s]; ok {
-			delete(t.items, s)
-			res = append(res, s)
+		sub, _, err := parseTopicFilter(s)
+		if err != nil {
+			continue
+		}
+
+		base := topicBase(sub)
+		subs, ok := t.filters[base]
+		if !ok {
+			continue
+		}
+		delete(subs, sub)
+		if len(subs) > 0 {
+			continue
+		}
+
+		delete(t.filters, base)
+		if _, ok := t.items[base]; ok {
+			delete(t.items, base)
+			res = append(res, base)
 		}
 	}
 
@@ -60,6 +104,14 @@ func (t *Topics) Merge(other Topics) {
 	for k := range other.items {
 		t.items[k] = struct{}{}
 	}
+	for k, subs := range other.filters {
+		if _, ok := t.filters[k]; !ok {
+			t.filters[k] = make(map[string]*topicFilter, len(subs))
+		}
+		for sub, filter := range subs {
+			t.filters[k][sub] = filter
+		}
+	}
 }
 
 // Contains check if the topic is in the collection
@@ -68,6 +120,28 @@ func (t Topics) Contains(topic string) bool {
 	return ok
 }
 
+// HasSubscription check if the subscription is in the collection, sub must be normalized by parseTopicFilter
+func (t Topics) HasSubscription(sub string) bool {
+	_, ok := t.filters[topicBase(sub)][sub]
+	return ok
+}
+
+// Match check if the topic is in the collection and the data matches any filter of the topic
+func (t Topics) Match(topic string, data []byte) bool {
+	subs, ok := t.filters[topic]
+	if !ok {
+		return false
+	}
+
+	for _, filter := range subs {
+		if filter.Match(data) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // ContainsAny check if any of the topics is in the collection
 func (t Topics) ContainsAny(topics []string) bool {
 	for _, v := range topics {
@@ -81,9 +155,7 @@ func (t Topics) ContainsAny(topics []string) bool {
 }
 
 func (t Topics) Clone() Topics {
-	res := Topics{items: make(map[string]struct{})}
-	for k, v := range t.items {
-		res.items[k] = v
-	}
+	res := newTopics()
+	res.Merge(t)
 	return res
 }
@@ -0,0 +1,163 @@
+package ws
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/buger/jsonparser"
+)
+
+const (
+	topicFilterSep       = '?' // order?symbol=BTCUSDT&category=linear
+	maxTopicFilterFields = 4   // 单个订阅最多过滤字段数
+	topicFilterDataKey   = "data"
+)
+
+var errInvalidTopicFilter = errors.New("invalid topic filter")
+
+// topicFilter 带参数的订阅,推送数据中的字段满足所有条件才推送,同一个字段多个值满足任一即可
+type topicFilter struct {
+	fields []topicFilterField
+}
+
+type topicFilterField struct {
+	key    string
+	values []string
+}
+
+// topicBase 去掉过滤参数的topic
+func topicBase(topic string) string {
+	if idx := strings.IndexByte(topic, topicFilterSep); idx != -1 {
+		return topic[:idx]
+	}
+	return topic
+}
+
+// topicBases 去掉过滤参数并去重
+func topicBases(topics []string) []string {
+	res := make([]string, 0, len(topics))
+	dict := make(map[string]struct{}, len(topics))
+	for _, t := range topics {
+		base := topicBase(t)
+		if _, ok := dict[base]; !ok {
+			dict[base] = struct{}{}
+			res = append(res, base)
+		}
+	}
+	return res
+}
+
+// parseTopicFilter 解析带参数的订阅,返回规范化后的订阅,参数按key排序,无参数时filter为nil
+func parseTopicFilter(topic string) (string, *topicFilter, error) {
+	idx := strings.IndexByte(topic, topicFilterSep)
+	if idx == -1 {
+		return topic, nil, nil
+	}
+
+	base := topic[:idx]
+	query, err := url.ParseQuery(topic[idx+1:])
+	if base == "" || err != nil || len(query) == 0 || len(query) > maxTopicFilterFields {
+		return "", nil, errInvalidTopicFilter
+	}
+
+	f := &topicFilter{fields: make([]topicFilterField, 0, len(query))}
+	for key, values := range query {
+		if key == "" {
+			return "", nil, errInvalidTopicFilter
+		}
+		values = distinctString(values)
+		for _, v := range values {
+			if v == "" {
+				return "", nil, errInvalidTopicFilter
+			}
+		}
+		sort.Strings(values)
+		f.fields = append(f.fields, topicFilterField{key: key, values: values})
+	}
+	sort.Slice(f.fields, func(i, j int) bool { return f.fields[i].key < f.fields[j].key })
+
+	return base + string(topicFilterSep) + f.encode(), f, nil
+}
+
+func (f *topicFilter) encode() string {
+	b := strings.Builder{}
+	for _, field := range f.fields {
+		for _, v := range field.values {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(url.QueryEscape(field.key))
+			b.WriteByte('=')
+			b.WriteString(url.QueryEscape(v))
+		}
+	}
+	return b.String()
+}
+
+// Match 推送数据是否满足过滤条件,优先匹配data中的字段,如果data是数组则任一元素满足即可
+func (f *topicFilter) Match(data []byte) bool {
+	if f == nil {
+		return true
+	}
+
+	value, typ, _, err := jsonparser.Get(data, topicFilterDataKey)
+	if err != nil {
+		return f.matchObject(nil, data)
+	}
+
+	switch typ {
+	case jsonparser.Array:
+		matched := false
+		_, _ = jsonparser.ArrayEach(value, func(item []byte, typ jsonparser.ValueType, _ int, _ error) {
+			if !matched && typ == jsonparser.Object {
+				matched = f.matchObject(item, data)
+			}
+		})
+		return matched
+	case jsonparser.Object:
+		return f.matchObject(value, data)
+	default:
+		return f.matchObject(nil, data)
+	}
+}
+
+// matchObject 字段在item中不存在时使用外层数据
+func (f *topicFilter) matchObject(item []byte, parent []byte) bool {
+	for _, field := range f.fields {
+		var value []byte
+		var err error = jsonparser.KeyPathNotFoundError
+		if item != nil {
+			value, _, _, err = jsonparser.Get(item, field.key)
+		}
+		if err != nil {
+			value, _, _, err = jsonparser.Get(parent, field.key)
+		}
+		if err != nil || !containsInOrderedList(field.values, string(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSubscribeTopics 规范化订阅参数,过滤参数不合法或者公有topic带有过滤参数时返回失败
+func parseSubscribeTopics(topics []string) (successes []string, fails []string) {
+	successes = make([]string, 0, len(topics))
+	for _, t := range topics {
+		sub, filter, err := parseTopicFilter(t)
+		if err != nil {
+			fails = append(fails, t)
+			continue
+		}
+
+		// 公有推送是广播的,不支持过滤
+		if filter != nil && len(getConfigMgr().IgnorePublicTopics([]string{topicBase(sub)})) == 0 {
+			fails = append(fails, t)
+			continue
+		}
+		successes = append(successes, sub)
+	}
+
+	return
+}
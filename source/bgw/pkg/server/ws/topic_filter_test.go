@@ -0,0 +1,68 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTopicFilter(t *testing.T) {
+	sub, f, err := parseTopicFilter("order")
+	assert.NoError(t, err)
+	assert.Equal(t, "order", sub)
+	assert.Nil(t, f)
+
+	sub, f, err = parseTopicFilter("order?symbol=ETHUSDT&category=linear&symbol=BTCUSDT")
+	assert.NoError(t, err)
+	assert.Equal(t, "order?category=linear&symbol=BTCUSDT&symbol=ETHUSDT", sub)
+	assert.Len(t, f.fields, 2)
+
+	for _, topic := range []string{"?symbol=BTCUSDT", "order?", "order?symbol=", "order?=1", "order?a=1&b=1&c=1&d=1&e=1"} {
+		_, _, err = parseTopicFilter(topic)
+		assert.Equal(t, errInvalidTopicFilter, err, topic)
+	}
+
+	assert.Equal(t, "order", topicBase("order?symbol=BTCUSDT"))
+	assert.Equal(t, []string{"order", "wallet"}, topicBases([]string{"order?side=Buy", "order", "wallet"}))
+}
+
+func TestTopicFilterMatch(t *testing.T) {
+	_, f, _ := parseTopicFilter("order?symbol=BTCUSDT&category=linear")
+	assert.True(t, f.Match([]byte(`{"topic":"order","data":[{"symbol":"ETHUSDT","category":"linear"},{"symbol":"BTCUSDT","category":"linear"}]}`)))
+	assert.False(t, f.Match([]byte(`{"topic":"order","data":[{"symbol":"BTCUSDT","category":"spot"}]}`)))
+	// field not in data item is matched with outer data
+	assert.True(t, f.Match([]byte(`{"category":"linear","data":{"symbol":"BTCUSDT"}}`)))
+	assert.True(t, f.Match([]byte(`{"category":"linear","symbol":"BTCUSDT"}`)))
+	assert.False(t, f.Match([]byte(`{"data":[]}`)))
+	assert.False(t, f.Match([]byte(`invalid`)))
+
+	var nilFilter *topicFilter
+	assert.True(t, nilFilter.Match(nil))
+}
+
+func TestTopicsWithFilter(t *testing.T) {
+	tt := newTopics()
+	assert.Equal(t, []string{"order"}, tt.Add("order?symbol=BTCUSDT"))
+	assert.Empty(t, tt.Add("order?symbol=ETHUSDT", "order?symbol=BTCUSDT"))
+	assert.Equal(t, []string{"order"}, tt.Values())
+	assert.ElementsMatch(t, []string{"order?symbol=BTCUSDT", "order?symbol=ETHUSDT"}, tt.Subscriptions())
+
+	btc := []byte(`{"data":[{"symbol":"BTCUSDT"}]}`)
+	sol := []byte(`{"data":[{"symbol":"SOLUSDT"}]}`)
+	assert.True(t, tt.Match("order", btc))
+	assert.False(t, tt.Match("order", sol))
+	assert.False(t, tt.Match("execution", btc))
+
+	// clone is not affected by changes
+	cloned := tt.Clone()
+	assert.Empty(t, tt.Remove("order?symbol=BTCUSDT"))
+	assert.False(t, tt.Match("order", btc))
+	assert.True(t, cloned.Match("order", btc))
+
+	// unfiltered subscription matches all
+	tt.Add("order")
+	assert.True(t, tt.Match("order", sol))
+	assert.Empty(t, tt.Remove("order"))
+	assert.Equal(t, []string{"order"}, tt.Remove("order?symbol=ETHUSDT"))
+	assert.Equal(t, 0, tt.Size())
+}
@@ -31,7 +31,9 @@ type User interface {
 	GetTopics() []string
 	GetParams() Params
 	GetSessions() []Session
-	FilterSessions(all bool, topic string, sessionID string) []Session
+	FilterSessions(all bool, topic string, sessionID string, data []byte) []Session
+	// HasTopic 是否有session订阅了topic,不校验过滤参数
+	HasTopic(topic string) bool
 	Add(s Session) error
 	Remove(id string) bool
 	// Build 构建消息
@@ -277,7 +279,18 @@ func (u *authedUser) GetSessions() []Session {
 	return nil
 }
 
-func (u *authedUser) FilterSessions(all bool, topic string, sessionID string) []Session {
+// HasTopic 是否有session订阅了topic,不校验过滤参数
+func (u *authedUser) HasTopic(topic string) bool {
+	for _, s := range u.GetSessions() {
+		if s.GetClient().HasTopic(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterSessions 过滤需要推送的session,订阅带有过滤参数时需要校验数据
+func (u *authedUser) FilterSessions(all bool, topic string, sessionID string, data []byte) []Session {
 	sessions := u.GetSessions()
 	switch {
 	case sessionID != "":
@@ -292,7 +305,7 @@ func (u *authedUser) FilterSessions(all bool, topic string, sessionID string) []
 	default:
 		res := make([]Session, 0, len(sessions))
 		for _, s := range sessions {
-			if s.GetClient().HasTopic(topic) {
+			if s.GetClient().MatchTopic(topic, data) {
 				res = append(res, s)
 			}
 		}
@@ -34,8 +34,10 @@ func GetUserMgr() UserMgr {
 var gUserMgr = newUserMgr()
 
 type userMgr struct {
-	users sync.Map
-	size  int32
+	users   sync.Map
+	size    int32
+	replays sync.Map // uid -> *replayBuffer
+	resumes sync.Map // session id -> *resumeState
 }
 
 func newUserMgr() *userMgr {
@@ -55,22 +55,24 @@ func TestUser(t *testing.T) {
 
 	// test filter session
 	t.Run("filter by session id", func(t *testing.T) {
-		fs2 := u.FilterSessions(false, "", s2.ID())
+		fs2 := u.FilterSessions(false, "", s2.ID(), nil)
 		assert.Equal(t, 1, len(fs2))
 		assert.Equal(t, s2.ID(), fs2[0].ID())
 
-		notFind := u.FilterSessions(false, "", "not_exist_id")
+		notFind := u.FilterSessions(false, "", "not_exist_id", nil)
 		assert.Nil(t, notFind)
 	})
 
 	t.Run("filter by all", func(t *testing.T) {
-		assert.Equal(t, 2, len(u.FilterSessions(true, "", "")))
+		assert.Equal(t, 2, len(u.FilterSessions(true, "", "", nil)))
 	})
 
 	t.Run("filter by topic", func(t *testing.T) {
-		fs1 := u.FilterSessions(false, "t1", "")
+		fs1 := u.FilterSessions(false, "t1", "", nil)
 		assert.Equal(t, 1, len(fs1))
 		assert.Equal(t, s1.ID(), fs1[0].ID())
+		assert.True(t, u.HasTopic("t1"))
+		assert.False(t, u.HasTopic("not_exist_topic"))
 	})
 
 	// remove session
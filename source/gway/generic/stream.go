@@ -0,0 +1,214 @@
+package generic
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MethodType streaming type of grpc method
+type MethodType int
+
+const (
+	MethodUnary MethodType = iota
+	MethodServerStream
+	MethodClientStream
+	MethodBidiStream
+)
+
+// String method type name
+func (t MethodType) String() string {
+	switch t {
+	case MethodServerStream:
+		return "server_stream"
+	case MethodClientStream:
+		return "client_stream"
+	case MethodBidiStream:
+		return "bidi_stream"
+	default:
+		return "unary"
+	}
+}
+
+// ServerStream server streaming call, messages are marshaled into json by the codec of namespace
+type ServerStream struct {
+	stream *grpcdynamic.ServerStream
+	codec  Codec
+}
+
+// Header get header metadata of stream, block until header is received
+func (s *ServerStream) Header() (metadata.MD, error) {
+	return s.stream.Header()
+}
+
+// Trailer get trailer metadata, only valid after Recv returns error
+func (s *ServerStream) Trailer() metadata.MD {
+	return s.stream.Trailer()
+}
+
+// Recv receive next message and marshal it into w, io.EOF is returned when stream is finished
+func (s *ServerStream) Recv(w io.Writer) error {
+	msg, err := s.stream.RecvMsg()
+	if err != nil {
+		return err
+	}
+	return s.codec.Marshal(w, msg)
+}
+
+// BidiStream bidi streaming call, messages are unmarshaled from and marshaled into json by the codec of namespace
+type BidiStream struct {
+	stream  *grpcdynamic.BidiStream
+	codec   Codec
+	factory *dynamic.MessageFactory
+	entry   *protoTypeEntry
+}
+
+// Header get header metadata of stream, block until header is received
+func (s *BidiStream) Header() (metadata.MD, error) {
+	return s.stream.Header()
+}
+
+// Trailer get trailer metadata, only valid after Recv returns error
+func (s *BidiStream) Trailer() metadata.MD {
+	return s.stream.Trailer()
+}
+
+// Send unmarshal json payload into request message and send it, not safe to call concurrently
+func (s *BidiStream) Send(payload io.Reader) error {
+	msg := s.factory.NewMessage(s.entry.md.GetInputType())
+	if err := s.codec.Unmarshal(payload, msg); err != nil && err != io.EOF {
+		return fmt.Errorf("%w, request body unmarshal error, field name: %s, err:%s", ErrReqUnmarshalFailed, s.entry.md.GetFullyQualifiedName(), err.Error())
+	}
+	return s.stream.SendMsg(msg)
+}
+
+// CloseSend half-close the stream, server is notified that no more message will be sent
+func (s *BidiStream) CloseSend() error {
+	return s.stream.CloseSend()
+}
+
+// Recv receive next message and marshal it into w, io.EOF is returned when stream is finished
+func (s *BidiStream) Recv(w io.Writer) error {
+	msg, err := s.stream.RecvMsg()
+	if err != nil {
+		return err
+	}
+	return s.codec.Marshal(w, msg)
+}
+
+// getController get controller of namespace
+func (e *Engine) getController(namespace string) (*controller, error) {
+	e.RLock()
+	defer e.RUnlock()
+
+	c, ok := e.controllers[namespace]
+	if !ok {
+		return nil, fmt.Errorf("controller not found of namespace: %s", namespace)
+	}
+	return c, nil
+}
+
+// GetMethodType get streaming type of method
+func (e *Engine) GetMethodType(namespace, service, method string) (MethodType, error) {
+	c, err := e.getController(namespace)
+	if err != nil {
+		return MethodUnary, err
+	}
+
+	protoType, err := c.extractProtoType(service, method)
+	if err != nil {
+		return MethodUnary, err
+	}
+
+	return methodType(protoType), nil
+}
+
+// InvokeServerStream start a server streaming call, the stream is closed when ctx is canceled
+func (e *Engine) InvokeServerStream(ctx context.Context, conn grpc.ClientConnInterface, request Request) (*ServerStream, error) {
+	c, err := e.getController(request.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	return c.invokeServerStream(ctx, conn, request)
+}
+
+// InvokeBidiStream start a bidi streaming call, the stream is closed when ctx is canceled
+func (e *Engine) InvokeBidiStream(ctx context.Context, conn grpc.ClientConnInterface, request Request) (*BidiStream, error) {
+	c, err := e.getController(request.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	return c.invokeBidiStream(ctx, conn, request)
+}
+
+func methodType(entry *protoTypeEntry) MethodType {
+	switch {
+	case entry.md.IsClientStreaming() && entry.md.IsServerStreaming():
+		return MethodBidiStream
+	case entry.md.IsServerStreaming():
+		return MethodServerStream
+	case entry.md.IsClientStreaming():
+		return MethodClientStream
+	default:
+		return MethodUnary
+	}
+}
+
+// invokeServerStream uses the given gRPC channel to start a server streaming call of the given method.
+func (ct *controller) invokeServerStream(ctx context.Context, ch grpcdynamic.Channel, request Request) (*ServerStream, error) {
+	protoType, err := ct.extractProtoType(request.GetService(), request.GetMethod())
+	if err != nil || protoType.md == nil {
+		return nil, fmt.Errorf("extract proto type failed: %w", err)
+	}
+	if methodType(protoType) != MethodServerStream {
+		return nil, fmt.Errorf("method %s is not server streaming", protoType.md.GetFullyQualifiedName())
+	}
+
+	factory := dynamic.NewMessageFactoryWithExtensionRegistry(protoType.ext)
+	msg := factory.NewMessage(protoType.md.GetInputType())
+	stub := grpcdynamic.NewStubWithMessageFactory(ch, factory)
+
+	md := protoType.md
+	err = ct.codec.Unmarshal(request.PayLoad(), msg)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%w, request body unmarshal error, field name: %s, err:%s", ErrReqUnmarshalFailed, md.GetFullyQualifiedName(), err.Error())
+	}
+
+	stream, err := stub.InvokeRpcServerStream(ctx, md, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ServerStream{stream: stream, codec: ct.codec}, nil
+}
+
+// invokeBidiStream uses the given gRPC channel to start a bidi streaming call of the given method.
+func (ct *controller) invokeBidiStream(ctx context.Context, ch grpcdynamic.Channel, request Request) (*BidiStream, error) {
+	protoType, err := ct.extractProtoType(request.GetService(), request.GetMethod())
+	if err != nil || protoType.md == nil {
+		return nil, fmt.Errorf("extract proto type failed: %w", err)
+	}
+	if methodType(protoType) != MethodBidiStream {
+		return nil, fmt.Errorf("method %s is not bidi streaming", protoType.md.GetFullyQualifiedName())
+	}
+
+	factory := dynamic.NewMessageFactoryWithExtensionRegistry(protoType.ext)
+	stub := grpcdynamic.NewStubWithMessageFactory(ch, factory)
+
+	stream, err := stub.InvokeRpcBidiStream(ctx, protoType.md)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BidiStream{
+		stream:  stream,
+		codec:   ct.codec,
+		factory: factory,
+		entry:   protoType,
+	}, nil
+}
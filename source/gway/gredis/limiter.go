@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"code.bydev.io/frameworks/byone/core/stores/redis"
@@ -101,27 +102,9 @@ func (l Limiter) AllowN(ctx context.Context, key string, limit Limit, n int) (*R
 		return nil, err
 	}
 
-	values, ok := v.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("redis AllowN: unexpected result type %T", v)
-	}
-
-	retryAfter, err := strconv.ParseFloat(values[2].(string), 64)
+	res, err := parseGCRAResult(v, limit)
 	if err != nil {
-		return nil, err
-	}
-
-	resetAfter, err := strconv.ParseFloat(values[3].(string), 64)
-	if err != nil {
-		return nil, err
-	}
-
-	res := &Result{
-		Limit:      limit,
-		Allowed:    int(values[0].(int64)),
-		Remaining:  int(values[1].(int64)),
-		RetryAfter: dur(retryAfter),
-		ResetAfter: dur(resetAfter),
+		return nil, fmt.Errorf("redis AllowN: %w", err)
 	}
 	return res, nil
 }
@@ -162,6 +145,111 @@ func (l Limiter) AllowM(ctx context.Context, key string, limit Limit, n int) (*R
 	return res, nil
 }
 
+// MultiLimit is one rule evaluated by AllowMulti.
+type MultiLimit struct {
+	Key   string
+	Limit Limit
+	N     int
+}
+
+// AllowMulti reports whether n events may happen at time now for all rules, in one EVALSHA.
+// The events are counted only when every rule is allowed, the results are in the same order as limits.
+// A rule passed but not counted because another rule is rejected has Allowed 0 and RetryAfter -1,
+// the rejected rule has RetryAfter >= 0.
+// Keys are the same as the keys of AllowN, so a rule can be switched between them without losing state.
+// When redis is a cluster, all keys must be in the same hash slot, see KeySlot.
+func (l Limiter) AllowMulti(ctx context.Context, limits []MultiLimit) ([]*Result, error) {
+	if len(limits) == 0 {
+		return nil, nil
+	}
+
+	var cancel context.CancelFunc
+	if _, ok := ctx.Deadline(); !ok {
+		ctx, cancel = context.WithTimeout(ctx, 3*time.Second)
+		defer cancel()
+	}
+
+	keys := make([]string, 0, len(limits))
+	values := make([]interface{}, 0, len(limits)*4)
+	for _, ml := range limits {
+		keys = append(keys, redisPrefix+ml.Key)
+		values = append(values, ml.Limit.Burst, ml.Limit.Rate, ml.Limit.Period.Seconds(), ml.N)
+	}
+
+	v, err := EvalSha(ctx, l.rdb, allowMulti, keys, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := v.([]interface{})
+	if !ok || len(items) != len(limits) {
+		return nil, fmt.Errorf("redis AllowMulti: unexpected result type %T", v)
+	}
+
+	results := make([]*Result, 0, len(limits))
+	for i, item := range items {
+		res, err := parseGCRAResult(item, limits[i].Limit)
+		if err != nil {
+			return nil, fmt.Errorf("redis AllowMulti: %w", err)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// KeySlot returns the redis cluster hash slot of the redis key of AllowN and AllowMulti.
+// Rules with the same slot can be evaluated by one AllowMulti.
+func KeySlot(key string) int {
+	key = redisPrefix + key
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % 16384)
+}
+
+// crc16 is CRC16-CCITT (XMODEM) used by redis cluster
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func parseGCRAResult(v interface{}, limit Limit) (*Result, error) {
+	values, ok := v.([]interface{})
+	if !ok || len(values) != 4 {
+		return nil, fmt.Errorf("unexpected result type %T", v)
+	}
+
+	retryAfter, err := strconv.ParseFloat(values[2].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	resetAfter, err := strconv.ParseFloat(values[3].(string), 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Limit:      limit,
+		Allowed:    int(values[0].(int64)),
+		Remaining:  int(values[1].(int64)),
+		RetryAfter: dur(retryAfter),
+		ResetAfter: dur(resetAfter),
+	}, nil
+}
+
 // Reset gets a key and reset all limitations and previous usages
 func (l *Limiter) Reset(ctx context.Context, key string, rateType string) error {
 	var cancel context.CancelFunc
@@ -3,6 +3,7 @@ package gredis
 import (
 	"context"
 	"testing"
+	"time"
 
 	"code.bydev.io/frameworks/byone/core/stores/redis"
 	"github.com/smartystreets/goconvey/convey"
@@ -81,11 +82,76 @@ func TestLimiter(t *testing.T) {
 				err = limiter.Reset(context.Background(), key, counterRateType)
 				convey.So(err, convey.ShouldBeNil)
 			})
+
+			convey.Convey("Should evaluate multi rules atomically", func() {
+				// Test data, keys are in the same hash slot
+				limits := []MultiLimit{
+					{Key: "{100}:test-key", Limit: PerMinute(10), N: 1},
+					{Key: "{100}:test-key:BTCUSDT", Limit: PerMinute(1), N: 1},
+				}
+				convey.So(KeySlot(limits[0].Key), convey.ShouldEqual, KeySlot(limits[1].Key))
+
+				results, err := limiter.AllowMulti(context.Background(), limits)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(len(results), convey.ShouldEqual, 2)
+				convey.So(results[0].Allowed, convey.ShouldEqual, 1)
+				convey.So(results[1].Allowed, convey.ShouldEqual, 1)
+
+				// the second rule is exceeded, the first rule should not be counted
+				results, err = limiter.AllowMulti(context.Background(), limits)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(results[0].Allowed, convey.ShouldEqual, 0)
+				convey.So(results[0].Remaining, convey.ShouldEqual, 9)
+				convey.So(results[1].Allowed, convey.ShouldEqual, 0)
+				convey.So(results[1].RetryAfter, convey.ShouldBeGreaterThan, 0)
+
+				// keys are shared with AllowN
+				res, err := limiter.AllowN(context.Background(), limits[0].Key, limits[0].Limit, 0)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(res.Remaining, convey.ShouldEqual, 9)
+
+				// peek without counting
+				limits[0].N, limits[1].N = 0, 0
+				results, err = limiter.AllowMulti(context.Background(), limits)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(results[0].Remaining, convey.ShouldEqual, 9)
+
+				for _, l := range limits {
+					err = limiter.Reset(context.Background(), l.Key, defaultRateType)
+					convey.So(err, convey.ShouldBeNil)
+				}
+			})
 		})
 
 	})
 }
 
+func TestKeySlot(t *testing.T) {
+	convey.Convey("KeySlot", t, func() {
+		convey.So(crc16("123456789"), convey.ShouldEqual, 0x31C3)
+		convey.So(KeySlot("{foo}:a"), convey.ShouldEqual, 12182)
+		convey.So(KeySlot("{foo}:a"), convey.ShouldEqual, KeySlot("{foo}:b"))
+		convey.So(KeySlot("a:{}"), convey.ShouldEqual, int(crc16(redisPrefix+"a:{}")%16384))
+	})
+}
+
+func TestParseGCRAResult(t *testing.T) {
+	convey.Convey("parseGCRAResult", t, func() {
+		res, err := parseGCRAResult([]interface{}{int64(1), int64(9), "-1", "6"}, PerMinute(10))
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(res.Allowed, convey.ShouldEqual, 1)
+		convey.So(res.Remaining, convey.ShouldEqual, 9)
+		convey.So(res.RetryAfter, convey.ShouldEqual, time.Duration(-1))
+		convey.So(res.ResetAfter, convey.ShouldEqual, 6*time.Second)
+
+		_, err = parseGCRAResult([]interface{}{int64(1)}, PerMinute(10))
+		convey.So(err, convey.ShouldNotBeNil)
+
+		_, err = parseGCRAResult([]interface{}{int64(0), int64(0), "x", "0"}, PerMinute(10))
+		convey.So(err, convey.ShouldNotBeNil)
+	})
+}
+
 func newClient() *redis.Redis {
 	cfg := redis.RedisConf{
 		//bybit-test-1 redis-cluster
@@ -69,6 +69,72 @@ remaining = remaining + 0.01
 return {cost, remaining, tostring(retry_after), tostring(reset_after)}
 `)
 
+// allowMulti is the multi-key version of allowN, all keys are evaluated atomically and
+// the events are only counted when every key is allowed.
+// ARGV is a list of (burst, rate, period, cost) for each key.
+var allowMulti = redis.NewScript(`
+-- this script has side-effects, so it requires replicate commands mode
+redis.replicate_commands()
+
+local jan_1_2017 = 1483228800
+local now = redis.call("TIME")
+now = (now[1] - jan_1_2017) + (now[2] / 1000000)
+
+local results = {}
+local passed = {}
+local allowed = true
+
+for i = 1, #KEYS do
+  local base = (i - 1) * 4
+  local burst = tonumber(ARGV[base + 1])
+  local rate = tonumber(ARGV[base + 2])
+  local period = tonumber(ARGV[base + 3])
+  local cost = tonumber(ARGV[base + 4])
+
+  local emission_interval = period / rate
+  local increment = emission_interval * cost
+  local burst_offset = emission_interval * burst
+
+  local tat = redis.call("GET", KEYS[i])
+  if not tat then
+    tat = now
+  else
+    tat = tonumber(tat)
+  end
+  tat = math.max(tat, now)
+
+  local new_tat = tat + increment
+  local diff = now - (new_tat - burst_offset)
+
+  if diff < 0 then
+    allowed = false
+    results[i] = {0, 0, tostring(diff * -1), tostring(tat - now)}
+  else
+    passed[i] = {cost, diff / emission_interval, tat, new_tat}
+  end
+end
+
+-- every result has 4 elements as allowN, cost may be 0 when only peeking the remaining
+for i = 1, #KEYS do
+  local p = passed[i]
+  if p then
+    local cost, remaining, tat, new_tat = p[1], p[2], p[3], p[4]
+    if allowed then
+      local reset_after = new_tat - now
+      if reset_after > 0 then
+        redis.call("SET", KEYS[i], new_tat, "EX", math.ceil(reset_after))
+      end
+      results[i] = {cost, remaining + 0.01, tostring(-1), tostring(reset_after)}
+    else
+      -- not counted, remaining is the value before this request
+      results[i] = {0, remaining + cost + 0.01, tostring(-1), tostring(tat - now)}
+    end
+  end
+end
+
+return results
+`)
+
 var incrbyEX = redis.NewScript(`
 local current
 current = redis.call("INCRBY", KEYS[1], ARGV[1])